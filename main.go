@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 	"github.com/gonum/matrix/mat64"
 	"github.com/vstoianovici/nngoclassify/neural"
@@ -32,6 +35,8 @@ var (
 	train string
 	// path to the test data set
 	test string
+	// path to the validation data set monitored during training, defaults to the test data set
+	valid string
 	// path to png picture to predict
 	predict string
 	// is the data set labeled
@@ -42,25 +47,64 @@ var (
 	resume bool
 	// manifest contains neural net config
 	manifest string
+	// path to the data set used for gradient checking
+	gradcheck string
+	// number of samples and weights per layer used for gradient checking
+	checkSamples int
+	checkWeights int
+	// seed of the network random generator, overrides the manifest seed
+	seed int64
+	// stream training data from disk instead of loading it into memory
+	stream bool
+	// size of the shuffle buffer of streamed training data
+	buffer int
+	// tune decision thresholds of multilabel network on the validation data set
+	tune bool
 
 	isTraining bool
 	isTesting bool
 	isPredicting bool
+	isGradChecking bool
 	keptManifest string = "./trainingdata/trainedManifest.yml"
 )
 
 func init() {
 	flag.StringVar(&train, "train", "", "Path to training data set")
 	flag.StringVar(&test, "test", "", "Path to test data set")
+	flag.StringVar(&valid, "valid", "", "Path to validation data set monitored during training, test data set is used if not set")
 	flag.StringVar(&predict, "predict", "", "Path to png file used for prediction")
 	flag.BoolVar(&labeled, "labeled", false, "Is the data set labeled")
 	//flag.BoolVar(&scale, "scale", false, "Require data scaling")
 	flag.BoolVar(&resume, "resume", false, "Resume training based on previously existing training data")
 	flag.StringVar(&manifest, "manifest", "", "Path to the neural net manifest file")
+	flag.StringVar(&gradcheck, "gradcheck", "", "Path to data set used to check the network gradient against finite differences")
+	flag.IntVar(&checkSamples, "checksamples", 10, "Number of data samples used for gradient checking")
+	flag.IntVar(&checkWeights, "checkweights", 20, "Number of weights per layer used for gradient checking")
+	flag.Int64Var(&seed, "seed", 0, "Seed of the random generator, overrides the seed in manifest file")
+	flag.BoolVar(&stream, "stream", false, "Stream labeled training data set from disk in mini-batches instead of loading it into memory")
+	flag.IntVar(&buffer, "buffer", 0, "Size of the shuffle buffer of streamed training data set, 0 disables shuffling")
+	flag.BoolVar(&tune, "tune", false, "Tune decision thresholds of multilabel network on validation data set at the end of training")
 }
 
 func parseCliFlags() error {
 	flag.Parse()
+	// gradient check is performed on its own
+	if gradcheck != "" {
+		if manifest == "" {
+			return errors.New("You must specify path to manifest file")
+		}
+		fmt.Printf("Gradient check will be performed.\n\n")
+		isGradChecking = true
+		return nil
+	}
+	// validation data set is only used by training
+	if valid != "" && train == "" {
+		return errors.New("You must specify path to training data set to use validation data set")
+	}
+	// thresholds are only tuned by training
+	if tune && train == "" {
+		return errors.New("You must specify path to training data set to tune thresholds")
+	}
 	// path to training data is mandatory
 	if train == "" {	
 		fmt.Println("No training will be performed.")
@@ -96,6 +140,10 @@ func parseCliFlags() error {
 		if manifest == "" && !resume {
 			return errors.New("You must specify path to manifest file")
 		}
+		// resumed training continues with the saved random generator
+		if resume && seed != 0 {
+			return errors.New("Random seed can't be changed when resuming training")
+		}
 		if test == "" {
 			fmt.Println("No testing will be performed.")
 			isTesting = false
@@ -135,6 +183,148 @@ func loadNN() (*neural.Network){
 	return net
 }
 
+// gradCheckTolerance is the largest relative error of a correctly calculated gradient
+const gradCheckTolerance = 1e-6
+
+func checkGradient() {
+	fmt.Println("--------------------------------------------------------------------------------")
+	configuration, err := config.New(manifest)
+	if err != nil {
+		fmt.Printf("Error reading manifest file: %s\n", err)
+		os.Exit(1)
+	}
+	ds, err := dataset.NewDataSet(gradcheck, labeled)
+	if err != nil {
+		fmt.Printf("Unable to load Gradient Check Data Set: %s\n", err)
+		os.Exit(1)
+	}
+	if seed != 0 {
+		configuration.Network.Seed = seed
+	}
+	net, err := neural.NewNetwork(configuration.Network)
+	if err != nil {
+		fmt.Printf("Error creating neural network: %s\n", err)
+		os.Exit(1)
+	}
+	features, labels := taskData(ds, net)
+	if labels == nil {
+		fmt.Println("Data set does not contain any labels")
+		os.Exit(1)
+	}
+	fmt.Printf("Random seed: %d\n", net.Seed())
+	fmt.Printf("Checking gradient of %s cost on %d samples and %d weights per layer ...\n\n",
+		configuration.Training.Cost, checkSamples, checkWeights)
+	checks, err := net.CheckGradient(configuration.Training, features.(*mat64.Dense),
+		labels, checkSamples, checkWeights)
+	if err != nil {
+		fmt.Printf("Error checking gradient: %s\n", err)
+		os.Exit(1)
+	}
+	failed := false
+	for _, check := range checks {
+		status := "OK"
+		if check.RelError > gradCheckTolerance {
+			status = "FAILED"
+			failed = true
+		}
+		fmt.Printf("%v ... %s\n", check, status)
+	}
+	if failed {
+		fmt.Printf("\nGradient check failed: relative error exceeds %e\n", gradCheckTolerance)
+		os.Exit(1)
+	}
+	fmt.Println("\nGradient check passed.")
+}
+
+// taskData returns features and labels of the data set for the network task.
+// Targets of PREDICT network and label indicators of MULTILABEL network are stored
+// in the first columns of the data set, one column per network output, and the inputs
+// which follow them are not scaled.
+func taskData(ds *dataset.DataSet, net *neural.Network) (mat64.Matrix, mat64.Matrix) {
+	if net.Task() == neural.CLASS {
+		return ds.Features(), ds.Labels()
+	}
+	layers := net.Layers()
+	outputs, _ := layers[len(layers)-1].Weights().Dims()
+	return ds.Inputs(outputs), ds.Targets(outputs)
+}
+
+// loadTrainData loads the whole training data set into memory and returns its features
+// and labels for the network task. It exits if the data set can't be loaded or is not labeled.
+func loadTrainData(net *neural.Network) (mat64.Matrix, mat64.Matrix) {
+	// load new training data set from provided file
+	ds, err := dataset.NewDataSet(train, labeled)
+	if err != nil {
+		fmt.Printf("Unable to load Traininig Data Set: %s\n", err)
+		os.Exit(1)
+	}
+	// extract features and labels from data set
+	features, labels := taskData(ds, net)
+	// if we require features scaling, scale data
+	//if scale {
+	//	features = dataset.Scale(features)
+	//}
+	//fmt.Println(mat64.Formatted(features))
+
+	if labels == nil {
+		fmt.Println("Data set does not contain any labels")
+		os.Exit(1)
+	}
+	return features, labels
+}
+
+// loadTestData loads labeled data set used to validate the network and returns its features
+// and labels for the network task. It exits if the data set can't be loaded or is not labeled.
+func loadTestData(path string, net *neural.Network) (mat64.Matrix, mat64.Matrix) {
+	dsV, err := dataset.NewDataSet(path, labeled)
+	if err != nil {
+		fmt.Printf("Unable to load Test Data Set: %s \n\n", err)
+		os.Exit(1)
+	}
+	// extract features and labels from data set
+	featuresV, labelsV := taskData(dsV, net)
+	// if we require features scaling, scale data
+	//	if scale {
+	//		featuresV = dataset.Scale(featuresV)
+	//	}
+
+	if labelsV == nil {
+		fmt.Println("Validation Data set does not contain any labels")
+		os.Exit(1)
+	}
+	return featuresV, labelsV
+}
+
+// validate prints classification accuracy of CLASS network, regression metrics
+// of PREDICT network or multilabel metrics of MULTILABEL network on the supplied data set.
+func validate(net *neural.Network, in *mat64.Dense, labels mat64.Matrix) {
+	if net.Task() == neural.MULTILABEL {
+		metrics, err := net.EvaluateLabels(in, labels)
+		if err != nil {
+			fmt.Printf("Could not evaluate tags: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nNeural net %s\n", metrics)
+		return
+	}
+	if net.Task() == neural.PREDICT {
+		metrics, err := net.Evaluate(in, labels)
+		if err != nil {
+			fmt.Printf("Could not evaluate predictions: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nNeural net %s\n", metrics)
+		return
+	}
+	// check the success rate i.e. successful number of classifications
+	success, err := net.Validate(in, labels)
+	if err != nil {
+		fmt.Printf("Could not calculate success rate: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("\nNeural net accuracy: %f\n", success)
+}
+
 func main() {
 	fmt.Println(welcomeMsg)
 
@@ -143,6 +333,11 @@ func main() {
 		fmt.Printf("Error parsing cli flags: %s\n", err)
 		os.Exit(1)
 	}
+
+	if isGradChecking {
+		checkGradient()
+		return
+	}
 	
 	var net *neural.Network
 	var features mat64.Matrix
@@ -170,31 +365,22 @@ func main() {
 			fmt.Printf("Error reading manifest file: %s\n", err)
 			os.Exit(1)
 		}	
-	
-		// load new training data set from provided file
-		ds, err := dataset.NewDataSet(train, labeled)
-		if err != nil {
-			fmt.Printf("Unable to load Traininig Data Set: %s\n", err)
-			os.Exit(1)
+		// decision thresholds are tuned on the validation data set and saved with the network
+		if tune {
+			configuration.Training.Tune = true
 		}
-		// extract features from data set
-		features = ds.Features()
-		// if we require features scaling, scale data
-		//if scale {
-		//	features = dataset.Scale(features)
-		//}
-		//fmt.Println(mat64.Formatted(features))
-
-		// extract data labels
-		labels = ds.Labels()
-		if labels == nil {
-			fmt.Println("Data set does not contain any labels")
+		// thresholds are tuned on validation data set kept apart from the test data set
+		if configuration.Training.Tune && valid == "" {
+			fmt.Println("You must specify path to validation data set to tune thresholds")
 			os.Exit(1)
 		}
-
+	
 		if resume {
 			net = loadNN()
 		}else{
+		if seed != 0 {
+			configuration.Network.Seed = seed
+		}
 		// Create new FEEDFWD network
 		net, err = neural.NewNetwork(configuration.Network)
 		if err != nil {
@@ -203,39 +389,81 @@ func main() {
 			}
 		}
 
-		// Run neural network training
-		timesToTrain := configuration.Training.Epochs
-		for i := 1; i < timesToTrain+1; i++ {
-			fmt.Printf("\nEpoch %v...\n", i)
-			if i > 1 {
-				net = loadNN()
-			}
-			err = net.Train(configuration.Training, features.(*mat64.Dense), labels.(*mat64.Vector), manifest)
-			if err != nil {
-				fmt.Printf("Error training network: %s\n", err)
-				os.Exit(1)
-			}
-
-			dsV, err := dataset.NewDataSet(test, labeled)
-			if err != nil {
-				fmt.Printf("Unable to load Test Data Set: %s \n\n", err)
+		// streamed training data set is read from disk in mini-batches during training
+		var src *dataset.Stream
+		if stream {
+			if !labeled {
+				fmt.Println("Streamed training requires labeled data set")
 				os.Exit(1)
 			}
-			// extract features from data set
-			featuresV = dsV.Features()
-			// extract data labels
-			labelsV = dsV.Labels()
-			if labelsV == nil {
-				fmt.Println("Validation Data set does not contain any labels")
+			// streamed features are scaled images labeled by a single column
+			if net.Task() != neural.CLASS {
+				fmt.Printf("Streamed training not supported by %s task\n", net.Task())
 				os.Exit(1)
 			}
-			// check the success rate i.e. successful number of classifications
-			success, err := net.Validate(featuresV.(*mat64.Dense), labelsV.(*mat64.Vector))
+			src, err = dataset.NewStream(train, buffer)
 			if err != nil {
-				fmt.Printf("Could not calculate success rate: %s\n", err)
+				fmt.Printf("Unable to open Traininig Data Set: %s\n", err)
 				os.Exit(1)
 			}
-			fmt.Printf("\n\nNeural net accuracy: %f\n", success)
+			defer src.Close()
+		} else {
+			features, labels = loadTrainData(net)
+		}
+
+		// the seed is saved along with the trained network
+		fmt.Printf("Random seed: %d\n\n", net.Seed())
+
+		// validation data set is used to monitor the training after every epoch.
+		// Test data set is used unless a separate validation data set is supplied
+		var valIn *mat64.Dense
+		var valLabels mat64.Matrix
+		if isTesting {
+			featuresV, labelsV = loadTestData(test, net)
+			valIn, valLabels = featuresV.(*mat64.Dense), labelsV
+		}
+		if valid != "" {
+			validIn, validLabels := loadTestData(valid, net)
+			valIn, valLabels = validIn.(*mat64.Dense), validLabels
+		}
+
+		// interrupting the training saves a checkpoint which can be resumed later
+		ctx, cancel := context.WithCancel(context.Background())
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			fmt.Println("\nInterrupted, stopping training at the next iteration ...")
+			// a second signal terminates the program immediately
+			signal.Stop(sigs)
+			cancel()
+		}()
+
+		// Run neural network training for all configured epochs
+		if stream {
+			err = net.TrainStream(ctx, configuration.Training, src, valIn, valLabels, manifest,
+				neural.NewPrinter(os.Stdout, true))
+		} else {
+			err = net.Train(ctx, configuration.Training, features.(*mat64.Dense), labels, valIn, valLabels, manifest,
+				neural.NewPrinter(os.Stdout, true))
+		}
+		signal.Stop(sigs)
+		if err == context.Canceled {
+			fmt.Println("Checkpoint saved into ./trainingdata, use \"-resume\" to continue training.")
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Printf("Error training network: %s\n", err)
+			os.Exit(1)
+		}
+		if configuration.Training.Tune {
+			fmt.Printf("\nTuned output thresholds: %v\n", net.Thresholds())
+		}
+
+		// the trained network is evaluated on the test data set
+		if isTesting {
+			fmt.Println()
+			validate(net, featuresV.(*mat64.Dense), labelsV)
 		}
 		secs = time.Now().Unix()
 		fmt.Printf("\nTraining completed successfully at %s.\n\n", time.Unix(secs, 0))
@@ -250,32 +478,9 @@ func main() {
 				net = loadNN()
 			}
 
-			dsV, err := dataset.NewDataSet(test, labeled)
-			if err != nil {
-				fmt.Printf("Unable to load Test Data Set: %s \n\n", err)
-				os.Exit(1)
-			}
-			// extract features from data set
-			featuresV = dsV.Features()
-			// if we require features scaling, scale data
-		//	if scale {
-		//		featuresV = dataset.Scale(featuresV)
-		//	}
-
-			// extract data labels
-			labelsV = dsV.Labels()
-			if labelsV == nil {
-				fmt.Println("Validation Data set does not contain any labels")
-				os.Exit(1)
-			}
+			featuresV, labelsV = loadTestData(test, net)
 
-			// check the success rate i.e. successful number of classifications
-			success, err := net.Validate(featuresV.(*mat64.Dense), labelsV.(*mat64.Vector))
-			if err != nil {
-				fmt.Printf("Could not calculate success rate: %s\n", err)
-				os.Exit(1)
-			}
-			fmt.Printf("\nNeural net accuracy: %f\n", success)
+			validate(net, featuresV.(*mat64.Dense), labelsV)
 		}
 
 		secs = time.Now().Unix()
@@ -284,7 +489,26 @@ func main() {
 		}
 		// Example of sample classification: in this case it's 1st data sample
 		sample := (featuresV.(*mat64.Dense)).RowView(0).T()
-		sampleLabel := int(labelsV.(*mat64.Vector).At(0,0))
+		if net.Task() == neural.PREDICT {
+			predMx, err := net.Predict(sample)
+			if err != nil {
+				fmt.Printf("Could not predict sample: %s\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("--------------------------------------------------------------------------------")
+			fmt.Printf("\nExample (prediction for the first sample in dataset):\n\nFor known targets of the sample %v ...\n...the predicted values are: %v\n",
+				mat64.Row(nil, 0, labelsV), predMx.RawRowView(0))
+		} else if net.Task() == neural.MULTILABEL {
+			tagMx, err := net.Tag(sample)
+			if err != nil {
+				fmt.Printf("Could not tag sample: %s\n", err)
+				os.Exit(1)
+			}
+			fmt.Println("--------------------------------------------------------------------------------")
+			fmt.Printf("\nExample (tags of the first sample in dataset):\n\nFor known labels of the sample %v ...\n...the tagged labels are: %v\n",
+				mat64.Row(nil, 0, labelsV), tagMx.RawRowView(0))
+		} else {
+		sampleLabel := int(labelsV.At(0,0))
 		classMx, err := net.Classify(sample)
 		if err != nil {
 			fmt.Printf("Could not classify sample: %s\n", err)
@@ -293,6 +517,7 @@ func main() {
 		fa := mat64.Formatted(classMx.T(), mat64.Prefix(""))
 		fmt.Println("--------------------------------------------------------------------------------")
 		fmt.Printf("\nExample (classification for the first sample in dataset):\n\nFor known value of the sample \"%v\" ...\n...the predction vector is: \n%v\n", sampleLabel, fa)
+		}
 	}
 
 	if isPredicting {
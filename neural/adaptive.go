@@ -0,0 +1,137 @@
+package neural
+
+import (
+	"math"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+const (
+	// default exponential decay rate of the first moment estimates
+	defaultBeta1 = 0.9
+	// default exponential decay rate of the second moment estimates
+	defaultBeta2 = 0.999
+	// default decay rate of RMSProp squared gradient average
+	defaultRho = 0.9
+	// default numerical stability constant
+	defaultEpsilon = 1e-8
+)
+
+// Adam implements Adam optimizer. If WeightDecay is not zero it implements AdamW
+// i.e. Adam with decoupled weight decay. Neither bias units nor batch normalization parameters are decayed.
+// Decay rates are used as they are: zero Beta1 disables momentum.
+// m = beta1 * m + (1 - beta1) * grad
+// v = beta2 * v + (1 - beta2) * grad^2
+// w = w - lr * (m / (1 - beta1^t)) / (sqrt(v / (1 - beta2^t)) + epsilon) - lr * decay * w
+type Adam struct {
+	// Beta1 is exponential decay rate of the first moment estimates
+	Beta1 float64
+	// Beta2 is exponential decay rate of the second moment estimates
+	Beta2 float64
+	// Epsilon is numerical stability constant. Defaults to 1e-8
+	Epsilon float64
+	// WeightDecay is decoupled weight decay coefficient
+	WeightDecay float64
+}
+
+// Update implements Optimizer interface
+func (a *Adam) Update(layers []*Layer, grads []*mat64.Dense, lr float64) {
+	beta1, beta2 := a.Beta1, a.Beta2
+	eps := withDefault(a.Epsilon, defaultEpsilon)
+	for i, layer := range layers {
+		moment := layer.optimState(&layer.moment)
+		variance := layer.optimState(&layer.variance)
+		layer.steps++
+		// bias corrections
+		corr1 := 1 - math.Pow(beta1, float64(layer.steps))
+		corr2 := 1 - math.Pow(beta2, float64(layer.steps))
+		rows, cols := layer.weights.Dims()
+		for r := 0; r < rows; r++ {
+			for c := 0; c < cols; c++ {
+				g := grads[i].At(r, c)
+				m := beta1*moment.At(r, c) + (1-beta1)*g
+				v := beta2*variance.At(r, c) + (1-beta2)*g*g
+				moment.Set(r, c, m)
+				variance.Set(r, c, v)
+				w := layer.weights.At(r, c)
+				step := lr * (m / corr1) / (math.Sqrt(v/corr2) + eps)
+				// don't decay bias units and batch normalization parameters
+				if c > 0 && !layer.isNorm {
+					step += lr * a.WeightDecay * w
+				}
+				layer.weights.Set(r, c, w-step)
+			}
+		}
+	}
+}
+
+// RMSProp implements RMSProp optimizer
+// v = rho * v + (1 - rho) * grad^2
+// w = w - lr * grad / (sqrt(v) + epsilon)
+type RMSProp struct {
+	// Rho is decay rate of squared gradient moving average
+	Rho float64
+	// Epsilon is numerical stability constant. Defaults to 1e-8
+	Epsilon float64
+}
+
+// Update implements Optimizer interface
+func (r *RMSProp) Update(layers []*Layer, grads []*mat64.Dense, lr float64) {
+	rho := r.Rho
+	eps := withDefault(r.Epsilon, defaultEpsilon)
+	for i, layer := range layers {
+		variance := layer.optimState(&layer.variance)
+		layer.steps++
+		rows, cols := layer.weights.Dims()
+		for j := 0; j < rows; j++ {
+			for k := 0; k < cols; k++ {
+				g := grads[i].At(j, k)
+				v := rho*variance.At(j, k) + (1-rho)*g*g
+				variance.Set(j, k, v)
+				layer.weights.Set(j, k, layer.weights.At(j, k)-lr*g/(math.Sqrt(v)+eps))
+			}
+		}
+	}
+}
+
+// Adagrad implements Adagrad optimizer
+// v = v + grad^2
+// w = w - lr * grad / (sqrt(v) + epsilon)
+type Adagrad struct {
+	// Epsilon is numerical stability constant. Defaults to 1e-8
+	Epsilon float64
+}
+
+// Update implements Optimizer interface
+func (a *Adagrad) Update(layers []*Layer, grads []*mat64.Dense, lr float64) {
+	eps := withDefault(a.Epsilon, defaultEpsilon)
+	for i, layer := range layers {
+		variance := layer.optimState(&layer.variance)
+		layer.steps++
+		rows, cols := layer.weights.Dims()
+		for j := 0; j < rows; j++ {
+			for k := 0; k < cols; k++ {
+				g := grads[i].At(j, k)
+				v := variance.At(j, k) + g*g
+				variance.Set(j, k, v)
+				layer.weights.Set(j, k, layer.weights.At(j, k)-lr*g/(math.Sqrt(v)+eps))
+			}
+		}
+	}
+}
+
+// withDefault returns val or def if val is zero
+func withDefault(val, def float64) float64 {
+	if val == 0 {
+		return def
+	}
+	return val
+}
+
+// valueOr returns the value val points to or def if val is nil
+func valueOr(val *float64, def float64) float64 {
+	if val == nil {
+		return def
+	}
+	return *val
+}
@@ -0,0 +1,42 @@
+package neural
+
+import (
+	"fmt"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+	"github.com/vstoianovici/nngoclassify/pkg/dataset"
+)
+
+// validateAugment validates data augmentation configuration. Nil configuration disables augmentation.
+func validateAugment(c *config.TrainConfig) error {
+	a := c.Augment
+	if a == nil {
+		return nil
+	}
+	// training data is augmented for every mini-batch
+	if c.Kind != "sgd" {
+		return fmt.Errorf("Data augmentation not supported by %s training\n", c.Kind)
+	}
+	if a.Translate < 0 || a.Noise < 0 || a.Rotate < 0 || a.Rotate > 180 || a.Scale < 0 || a.Scale >= 1 ||
+		a.Alpha < 0 || a.Sigma < 0 || (a.Alpha > 0 && a.Sigma == 0) {
+		return fmt.Errorf("Incorrect augmentation parameters: %+v\n", *a)
+	}
+	return nil
+}
+
+// augment randomly transforms training images of the mini-batch in place using the network random generator
+func (n *Network) augment(c *config.AugmentConfig, batchMx *mat64.Dense) error {
+	if c == nil {
+		return nil
+	}
+	augmenter := &dataset.Augmenter{
+		Translate: c.Translate,
+		Rotate:    c.Rotate,
+		Scale:     c.Scale,
+		Alpha:     c.Alpha,
+		Sigma:     c.Sigma,
+		Noise:     c.Noise,
+	}
+	return augmenter.Augment(batchMx, n.rng)
+}
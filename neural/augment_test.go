@@ -0,0 +1,62 @@
+package neural
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+func TestTrainAugment(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "loglike",
+		Learningrate: 0.1,
+		Epochs:       2,
+		Optimize:     &config.OptimConfig{Method: "sgd", Batchsize: 2},
+		// 4 input features are 2x2 images
+		Augment: &config.AugmentConfig{Translate: 0.5, Rotate: 10, Noise: 0.05},
+	}
+	assert.NoError(ValidateTrainConfig(c))
+	// training data is augmented reproducibly without modifying the data set
+	orig := mat64.DenseCopyOf(inMx)
+	var weights []*mat64.Dense
+	for i := 0; i < 2; i++ {
+		conf.Network.Seed = 7
+		n, err := NewNetwork(conf.Network)
+		assert.NoError(err)
+		assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+		weights = append(weights, n.Layers()[1].Weights())
+	}
+	assert.True(mat64.Equal(orig, inMx))
+	assert.True(mat64.Equal(weights[0], weights[1]))
+	// augmented training differs from the plain one
+	conf.Network.Seed = 7
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	augment := c.Augment
+	c.Augment = nil
+	assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+	assert.False(mat64.Equal(weights[0], n.Layers()[1].Weights()))
+	// images must be square
+	c.Augment = augment
+	_, cols := inMx.Dims()
+	assert.Error(n.Train(context.Background(), c, inMx.View(0, 0, 5, cols-1).(*mat64.Dense), labelsVec, nil, nil, "", nil))
+	// incorrect augmentation configuration
+	c.Augment = &config.AugmentConfig{Alpha: 1.0}
+	assert.Error(ValidateTrainConfig(c))
+	c.Augment = &config.AugmentConfig{Scale: 1.0}
+	assert.Error(ValidateTrainConfig(c))
+	c.Augment = &config.AugmentConfig{Noise: 0.1}
+	c.Kind = "backprop"
+	c.Optimize = &config.OptimConfig{Method: "bfgs", Iterations: 2}
+	assert.Error(ValidateTrainConfig(c))
+}
@@ -0,0 +1,174 @@
+package neural
+
+import (
+	"github.com/gonum/matrix/mat64"
+)
+
+// fwdCache holds the results of a single forward pass of a batch of samples through the network
+type fwdCache struct {
+	// outs holds the output of every layer, outs[0] is the network input
+	outs []mat64.Matrix
+	// actIns holds activation function inputs of every layer but INPUT layer
+	actIns []*mat64.Dense
+	// masks holds dropout masks of layer outputs. Nil mask means no outputs are dropped
+	masks []*mat64.Dense
+	// norms holds batch normalization results of every batch normalized layer
+	norms []*normCache
+}
+
+// forwardShards runs forward propagation of the input matrix through the whole network. Input rows
+// are split into shards processed by the given number of workers and the activation function inputs
+// and outputs of all layers of every shard are kept for backpropagation. Layers are processed one
+// at a time by all workers so that batch normalized layers normalize their activation inputs
+// with the statistics of the whole batch. Layer outputs are multiplied by the supplied dropout
+// masks which can be nil.
+func (n *Network) forwardShards(inMx *mat64.Dense, workers int, masks []*mat64.Dense) ([]*fwdCache, error) {
+	layers := n.Layers()
+	samples, cols := inMx.Dims()
+	caches := make([]*fwdCache, workers)
+	for w := range caches {
+		from, to := shard(samples, workers, w)
+		caches[w] = &fwdCache{
+			outs:   make([]mat64.Matrix, len(layers)),
+			actIns: make([]*mat64.Dense, len(layers)),
+			masks:  make([]*mat64.Dense, len(layers)),
+			norms:  make([]*normCache, len(layers)),
+		}
+		if masks != nil {
+			copy(caches[w].masks, maskRows(masks, from, to))
+		}
+		caches[w].outs[0] = inMx.View(from, 0, to-from, cols)
+	}
+	for i := 1; i < len(layers); i++ {
+		layer := layers[i]
+		// batch statistics are calculated from activation inputs of all the shards
+		var mean, variance []float64
+		if layer.norm != nil && layer.mode == TRAINING {
+			err := parallel(samples, workers, func(w, from, to int) error {
+				var err error
+				caches[w].actIns[i], err = layer.linear(caches[w].outs[i-1])
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			actIns := make([]*mat64.Dense, workers)
+			for w, cache := range caches {
+				actIns[w] = cache.actIns[i]
+			}
+			mean, variance = batchStats(actIns)
+		}
+		err := parallel(samples, workers, func(w, from, to int) error {
+			cache := caches[w]
+			var actIn, out *mat64.Dense
+			var nc *normCache
+			var err error
+			if mean != nil {
+				actIn, nc = layer.normalize(cache.actIns[i], mean, variance)
+				out = layer.output(actIn)
+			} else if actIn, out, nc, err = layer.activate(cache.outs[i-1]); err != nil {
+				return err
+			}
+			if mask := cache.masks[i]; mask != nil {
+				out.MulElem(out, mask)
+			}
+			cache.actIns[i], cache.outs[i], cache.norms[i] = actIn, out, nc
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return caches, nil
+}
+
+// dropMasks generates dropout masks of all network layers for the given number of samples.
+// Masks are generated upfront so that the forward pass does not depend on the number of workers.
+// It returns nil if no outputs are dropped i.e. in INFERENCE mode.
+func (n *Network) dropMasks(samples int) []*mat64.Dense {
+	var masks []*mat64.Dense
+	layers := n.Layers()
+	for i := 1; i < len(layers); i++ {
+		if mask := layers[i].dropMask(samples, layers[i].size()); mask != nil {
+			if masks == nil {
+				masks = make([]*mat64.Dense, len(layers))
+			}
+			masks[i] = mask
+		}
+	}
+	return masks
+}
+
+// maskRows returns the rows [from, to) of all dropout masks
+func maskRows(masks []*mat64.Dense, from, to int) []*mat64.Dense {
+	if masks == nil {
+		return nil
+	}
+	rows := make([]*mat64.Dense, len(masks))
+	for i, mask := range masks {
+		if mask != nil {
+			_, cols := mask.Dims()
+			rows[i] = mask.View(from, 0, to-from, cols).(*mat64.Dense)
+		}
+	}
+	return rows
+}
+
+// backPropShards backpropagates the output errors of a batch of samples through the network
+// using the activations cached by forwardShards. Output errors of every shard hold a row per sample.
+// Every worker sums the deltas of every layer over the samples of its shard and accumulates them
+// into the matrix with the same index in its deltas. Deltas of batch normalization parameters
+// are summed over the whole batch and accumulated into the matrix with the same index in normDeltas.
+func (n *Network) backPropShards(caches []*fwdCache, errMxs []mat64.Matrix, deltas [][]*mat64.Dense,
+	normDeltas []*mat64.Dense) error {
+	layers := n.Layers()
+	workers := len(caches)
+	samples := 0
+	for _, errMx := range errMxs {
+		rows, _ := errMx.Dims()
+		samples += rows
+	}
+	for i := len(layers) - 1; i > 0; i-- {
+		// errors of normalized activation inputs depend on the errors of the whole batch
+		if layers[i].norm != nil {
+			size, _ := layers[i].Weights().Dims()
+			errSum, errNormSum := make([]float64, size), make([]float64, size)
+			for w, cache := range caches {
+				normSums(cache.norms[i], errMxs[w], errSum, errNormSum)
+			}
+			for j := 0; j < size; j++ {
+				normDeltas[i].Set(j, 0, normDeltas[i].At(j, 0)+errSum[j])
+				normDeltas[i].Set(j, 1, normDeltas[i].At(j, 1)+errNormSum[j])
+			}
+			for w, cache := range caches {
+				errMxs[w] = layers[i].normBackProp(cache.norms[i], errMxs[w], errSum, errNormSum, samples)
+			}
+		}
+		err := parallel(samples, workers, func(w, from, to int) error {
+			cache := caches[w]
+			// compute deltas update and errors of the layer input
+			layerErr := layers[i].backward(cache.outs[i-1], errMxs[w], deltas[w][i], i > 1)
+			// If we reach the 1st hidden layer we return
+			if i == 1 {
+				return nil
+			}
+			// dropped outputs don't contribute to the error
+			if mask := cache.masks[i-1]; mask != nil {
+				layerErr.MulElem(layerErr, mask)
+			}
+			// multiply by activation gradient of the previous layer.
+			// Pooling and flatten layers have no activation function
+			if actGrad := layers[i-1].ActGrad(); actGrad != nil {
+				gradMx := new(mat64.Dense)
+				gradMx.Apply(actGrad, cache.actIns[i-1])
+				layerErr.MulElem(layerErr, gradMx)
+			}
+			errMxs[w] = layerErr
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package neural
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+	"github.com/vstoianovici/nngoclassify/pkg/dataset"
+	"github.com/vstoianovici/nngoclassify/pkg/matrix"
+)
+
+// perSampleGradient calculates unregularized network gradient by backpropagating
+// the samples one by one using BackProp
+func perSampleGradient(n *Network, c *config.TrainConfig, inMx *mat64.Dense, labelsVec *mat64.Vector) ([]float64, error) {
+	layers := n.Layers()
+	outMx, err := n.ForwardProp(inMx, len(layers)-1)
+	if err != nil {
+		return nil, err
+	}
+	_, labelCount := outMx.Dims()
+	labelsMx, err := matrix.MakeLabelsMx(labelsVec, labelCount)
+	if err != nil {
+		return nil, err
+	}
+	for _, layer := range layers[1:] {
+		layer.Deltas().Scale(0.0, layer.Deltas())
+	}
+	samples, _ := inMx.Dims()
+	tc := trainCost[c.Cost](c, nil)
+	for i := 0; i < samples; i++ {
+		deltaVec := tc.Delta(outMx.(*mat64.Dense).RowView(i), labelsMx.RowView(i))
+		if err := n.BackProp(inMx.RowView(i).T(), deltaVec.T(), len(layers)-1); err != nil {
+			return nil, err
+		}
+	}
+	var gradient []float64
+	for _, layer := range layers[1:] {
+		deltas := layer.Deltas()
+		deltas.Scale(1/float64(samples), deltas)
+		gradient = append(gradient, matrix.Mx2Vec(deltas, false)...)
+	}
+	return gradient, nil
+}
+
+// newBenchNetwork creates network with a single hidden layer
+func newBenchNetwork(in, hidden, out int, activation string) (*Network, error) {
+	return NewNetwork(&config.NetConfig{
+		Kind: "feedfwd",
+		Arch: &config.NetArch{
+			Input: &config.LayerConfig{Kind: "input", Size: in},
+			Hidden: []*config.LayerConfig{
+				{Kind: "hidden", Size: hidden, NeurFn: &config.NeuronConfig{Activation: activation}},
+			},
+			Output: &config.LayerConfig{Kind: "output", Size: out, NeurFn: &config.NeuronConfig{Activation: "softmax"}},
+		},
+	})
+}
+
+// randData generates random input matrix and labels vector
+func randData(samples, features, labels int) (*mat64.Dense, *mat64.Vector) {
+	inMx := mat64.NewDense(samples, features, nil)
+	labelsVec := mat64.NewVector(samples, nil)
+	for i := 0; i < samples; i++ {
+		for j := 0; j < features; j++ {
+			inMx.Set(i, j, rand.Float64())
+		}
+		labelsVec.SetVec(i, float64(rand.Intn(labels)))
+	}
+	return inMx, labelsVec
+}
+
+func TestBackPropBatch(t *testing.T) {
+	assert := assert.New(t)
+	for _, activation := range []string{"sigmoid", "tanh", "relu"} {
+		// two hidden layers
+		n, err := NewNetwork(&config.NetConfig{
+			Kind: "feedfwd",
+			Arch: &config.NetArch{
+				Input: &config.LayerConfig{Kind: "input", Size: 6},
+				Hidden: []*config.LayerConfig{
+					{Kind: "hidden", Size: 5, NeurFn: &config.NeuronConfig{Activation: activation}},
+					{Kind: "hidden", Size: 4, NeurFn: &config.NeuronConfig{Activation: activation}},
+				},
+				Output: &config.LayerConfig{Kind: "output", Size: 3, NeurFn: &config.NeuronConfig{Activation: "softmax"}},
+			},
+		})
+		assert.NoError(err)
+		inMx, labelsVec := randData(20, 6, 3)
+		for _, cost := range []string{"loglike", "xentropy"} {
+			c := &config.TrainConfig{Cost: cost}
+			expected, err := perSampleGradient(n, c, inMx, labelsVec)
+			assert.NoError(err)
+			for _, workers := range []int{1, 3} {
+				c.Workers = workers
+				grad, err := n.getGradient(c, nil, inMx, labelsVec)
+				assert.NoError(err)
+				assert.Len(grad, len(expected))
+				for i := range expected {
+					assert.InDelta(expected[i], grad[i], 1e-12, activation)
+				}
+			}
+		}
+	}
+}
+
+func benchmarkGradient(b *testing.B, inMx *mat64.Dense, labelsVec *mat64.Vector, batched bool) {
+	_, features := inMx.Dims()
+	n, err := newBenchNetwork(features, 25, 10, "sigmoid")
+	if err != nil {
+		b.Fatal(err)
+	}
+	c := &config.TrainConfig{Cost: "loglike"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if batched {
+			_, err = n.getGradient(c, nil, inMx, labelsVec)
+		} else {
+			_, err = perSampleGradient(n, c, inMx, labelsVec)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkMNIST(b *testing.B, batched bool) {
+	ds, err := dataset.NewDataSet(filepath.Join("..", "testdata", "mnist_train_100.csv"), true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarkGradient(b, ds.Features().(*mat64.Dense), ds.Labels().(*mat64.Vector), batched)
+}
+
+func BenchmarkGradientMNIST(b *testing.B) {
+	benchmarkMNIST(b, true)
+}
+
+func BenchmarkGradientMNISTPerSample(b *testing.B) {
+	benchmarkMNIST(b, false)
+}
+
+func BenchmarkGradientSynthetic(b *testing.B) {
+	inMx, labelsVec := randData(1000, 784, 10)
+	benchmarkGradient(b, inMx, labelsVec, true)
+}
+
+func BenchmarkGradientSyntheticPerSample(b *testing.B) {
+	inMx, labelsVec := randData(1000, 784, 10)
+	benchmarkGradient(b, inMx, labelsVec, false)
+}
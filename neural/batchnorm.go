@@ -0,0 +1,154 @@
+package neural
+
+import (
+	"math"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+const (
+	// normEpsilon is added to the variance of activation inputs for numerical stability
+	normEpsilon = 1e-5
+	// normMomentum is the weight of running statistics in their moving average update
+	normMomentum = 0.9
+)
+
+// normCache holds intermediate results of batch normalization of a batch of activation inputs
+type normCache struct {
+	// normMx holds normalized activation inputs before they are scaled and shifted
+	normMx *mat64.Dense
+	// mean contains means of activation inputs of every neuron used for the normalization
+	mean []float64
+	// variance contains variances of activation inputs of every neuron used for the normalization
+	variance []float64
+	// batch is true if the mean and variance were calculated from the normalized batch
+	batch bool
+}
+
+// newNorm creates batch normalization parameters and running statistics of a layer with size neurons.
+// Parameters are kept in weights matrix of a parameter only layer so that they are trained
+// by the same optimizers as layer weights: the 1st column holds shift initialized to 0,
+// the 2nd column holds scale initialized to 1. Running statistics matrix holds running mean
+// in the 1st column initialized to 0 and running variance in the 2nd column initialized to 1.
+func newNorm(kind LayerKind, size int) (*Layer, *mat64.Dense) {
+	norm := &Layer{
+		kind:    kind,
+		weights: mat64.NewDense(size, 2, nil),
+		deltas:  mat64.NewDense(size, 2, nil),
+		isNorm:  true,
+	}
+	stats := mat64.NewDense(size, 2, nil)
+	for i := 0; i < size; i++ {
+		norm.weights.Set(i, 1, 1.0)
+		stats.Set(i, 1, 1.0)
+	}
+	return norm, stats
+}
+
+// batchStats returns the mean and variance of activation inputs of every neuron
+// over all rows of the supplied activation inputs of a batch split into shards
+func batchStats(actIns []*mat64.Dense) ([]float64, []float64) {
+	_, cols := actIns[0].Dims()
+	mean, variance := make([]float64, cols), make([]float64, cols)
+	samples := 0
+	for _, actIn := range actIns {
+		rows, _ := actIn.Dims()
+		samples += rows
+		for j := 0; j < cols; j++ {
+			for i := 0; i < rows; i++ {
+				mean[j] += actIn.At(i, j)
+			}
+		}
+	}
+	for j := range mean {
+		mean[j] /= float64(samples)
+	}
+	for _, actIn := range actIns {
+		rows, _ := actIn.Dims()
+		for j := 0; j < cols; j++ {
+			for i := 0; i < rows; i++ {
+				diff := actIn.At(i, j) - mean[j]
+				variance[j] += diff * diff
+			}
+		}
+	}
+	for j := range variance {
+		variance[j] /= float64(samples)
+	}
+	return mean, variance
+}
+
+// normalize normalizes activation inputs of the layer and applies the learned shift and scale.
+// Activation inputs are normalized using the supplied batch statistics in TRAINING mode
+// and using the running statistics in INFERENCE mode. If the batch statistics are nil,
+// the statistics of the supplied activation inputs are used.
+func (l *Layer) normalize(actIn *mat64.Dense, mean, variance []float64) (*mat64.Dense, *normCache) {
+	rows, cols := actIn.Dims()
+	nc := &normCache{
+		normMx: mat64.NewDense(rows, cols, nil),
+		batch:  l.mode == TRAINING,
+	}
+	if nc.batch {
+		nc.mean, nc.variance = mean, variance
+		if mean == nil {
+			nc.mean, nc.variance = batchStats([]*mat64.Dense{actIn})
+		}
+	} else {
+		nc.mean = mat64.Col(nil, 0, l.stats)
+		nc.variance = mat64.Col(nil, 1, l.stats)
+	}
+	out := mat64.NewDense(rows, cols, nil)
+	for j := 0; j < cols; j++ {
+		invStd := 1 / math.Sqrt(nc.variance[j]+normEpsilon)
+		shift, scale := l.norm.weights.At(j, 0), l.norm.weights.At(j, 1)
+		for i := 0; i < rows; i++ {
+			norm := (actIn.At(i, j) - nc.mean[j]) * invStd
+			nc.normMx.Set(i, j, norm)
+			out.Set(i, j, scale*norm+shift)
+		}
+	}
+	return out, nc
+}
+
+// normSums accumulates the errors of normalized activation inputs of every neuron into errSum
+// and their products with the normalized activation inputs into errNormSum. The sums are
+// the deltas of shift and scale and are needed by normBackProp.
+func normSums(nc *normCache, errMx mat64.Matrix, errSum, errNormSum []float64) {
+	rows, cols := errMx.Dims()
+	for j := 0; j < cols; j++ {
+		for i := 0; i < rows; i++ {
+			errSum[j] += errMx.At(i, j)
+			errNormSum[j] += errMx.At(i, j) * nc.normMx.At(i, j)
+		}
+	}
+}
+
+// normBackProp backpropagates the errors of normalized activation inputs through batch normalization
+// given the sums of the errors over the whole batch of the given number of samples calculated by normSums.
+// It returns the errors of activation inputs before normalization.
+func (l *Layer) normBackProp(nc *normCache, errMx mat64.Matrix, errSum, errNormSum []float64, samples int) *mat64.Dense {
+	rows, cols := errMx.Dims()
+	actErr := mat64.NewDense(rows, cols, nil)
+	for j := 0; j < cols; j++ {
+		scale := l.norm.weights.At(j, 1) / math.Sqrt(nc.variance[j]+normEpsilon)
+		for i := 0; i < rows; i++ {
+			e := errMx.At(i, j)
+			// batch statistics depend on every sample in the batch
+			if nc.batch {
+				e -= (errSum[j] + nc.normMx.At(i, j)*errNormSum[j]) / float64(samples)
+			}
+			actErr.Set(i, j, scale*e)
+		}
+	}
+	return actErr
+}
+
+// updateStats updates running statistics of the layer with the supplied batch mean and variance.
+// Momentum is the weight of running statistics in their moving average update:
+// zero momentum replaces the running statistics by the batch ones.
+func (l *Layer) updateStats(mean, variance []float64, momentum float64) {
+	for j := range mean {
+		l.stats.Set(j, 0, momentum*l.stats.At(j, 0)+(1-momentum)*mean[j])
+		l.stats.Set(j, 1, momentum*l.stats.At(j, 1)+(1-momentum)*variance[j])
+	}
+}
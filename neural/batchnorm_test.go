@@ -0,0 +1,245 @@
+package neural
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// newNormNetwork creates network with batch normalized hidden layers of the given sizes
+func newNormNetwork(sizes ...int) (*Network, error) {
+	var hidden []*config.LayerConfig
+	for _, size := range sizes {
+		hidden = append(hidden, &config.LayerConfig{
+			Kind:      "hidden",
+			Size:      size,
+			NeurFn:    &config.NeuronConfig{Activation: "sigmoid"},
+			Batchnorm: true,
+		})
+	}
+	return NewNetwork(&config.NetConfig{
+		Kind: "feedfwd",
+		Arch: &config.NetArch{
+			Input:  &config.LayerConfig{Kind: "input", Size: 4},
+			Hidden: hidden,
+			Output: &config.LayerConfig{Kind: "output", Size: 5, NeurFn: &config.NeuronConfig{Activation: "softmax"}},
+		},
+	})
+}
+
+func TestBatchnormLayer(t *testing.T) {
+	assert := assert.New(t)
+	c := &config.LayerConfig{
+		Kind:      "hidden",
+		Size:      3,
+		NeurFn:    &config.NeuronConfig{Activation: "sigmoid"},
+		Batchnorm: true,
+	}
+	layer, err := NewLayer(c, 4)
+	assert.NoError(err)
+	assert.True(layer.Batchnorm())
+	// output layer can't be normalized
+	c.Kind = "output"
+	_, err = NewLayer(c, 4)
+	assert.Error(err)
+	// activation inputs are normalized by batch statistics in TRAINING mode
+	layer.mode = TRAINING
+	actIn, _, nc, err := layer.activate(inMx)
+	assert.NoError(err)
+	assert.True(nc.batch)
+	rows, cols := actIn.Dims()
+	for j := 0; j < cols; j++ {
+		col := mat64.Col(nil, j, actIn)
+		mean, variance := 0.0, 0.0
+		for _, x := range col {
+			mean += x / float64(rows)
+		}
+		for _, x := range col {
+			variance += (x - mean) * (x - mean) / float64(rows)
+		}
+		assert.InDelta(0.0, mean, 1e-9)
+		assert.InDelta(nc.variance[j]/(nc.variance[j]+normEpsilon), variance, 1e-9)
+	}
+	// running statistics are used in INFERENCE mode
+	layer.mode = INFERENCE
+	layer.updateStats(nc.mean, nc.variance, normMomentum)
+	for j := 0; j < cols; j++ {
+		assert.InDelta(0.1*nc.mean[j], layer.stats.At(j, 0), 1e-9)
+		assert.InDelta(0.9+0.1*nc.variance[j], layer.stats.At(j, 1), 1e-9)
+	}
+	_, _, nc, err = layer.activate(inMx)
+	assert.NoError(err)
+	assert.False(nc.batch)
+	assert.Equal(mat64.Col(nil, 0, layer.stats), nc.mean)
+	// BackProp does not support batch normalization
+	n, err := newNormNetwork(5)
+	assert.NoError(err)
+	errVec := mat64.NewVector(5, []float64{0.1, -0.2, 0.3, 0.0, 0.1})
+	assert.Error(n.BackProp(inMx.RowView(0).T(), errVec.T(), 2))
+}
+
+func TestBatchnormGradient(t *testing.T) {
+	assert := assert.New(t)
+	for _, mode := range []Mode{INFERENCE, TRAINING} {
+		for _, workers := range []int{1, 2} {
+			n, err := newNormNetwork(6, 5)
+			assert.NoError(err)
+			// move batch normalization parameters away from their initial values
+			for _, layer := range n.Layers()[1:3] {
+				layer.norm.weights.Apply(func(i, j int, v float64) float64 {
+					return v + 0.1*float64(i+1)*float64(j+1)
+				}, layer.norm.weights)
+				size, _ := layer.Weights().Dims()
+				mean, variance := make([]float64, size), make([]float64, size)
+				for j := range mean {
+					mean[j], variance[j] = 0.3*float64(j)-0.5, 0.5*float64(j+1)
+				}
+				layer.updateStats(mean, variance, normMomentum)
+			}
+			n.SetMode(mode)
+			c := &config.TrainConfig{Cost: "loglike", Lambda: 1.0, Workers: workers}
+			stats := mat64.DenseCopyOf(n.Layers()[1].stats)
+			checks, err := n.CheckGradient(c, inMx, labelsVec, 5, 100)
+			assert.NoError(err)
+			// gradient checking leaves running statistics unchanged
+			assert.True(mat64.Equal(stats, n.Layers()[1].stats))
+			assert.Len(checks, 5)
+			for _, check := range checks {
+				assert.True(check.RelError < 1e-6, "%s %s", mode, check)
+			}
+			assert.True(checks[1].Norm)
+			assert.Equal(1, checks[1].Layer)
+			assert.Equal(12, checks[1].Weights)
+			assert.Equal(3, checks[4].Layer)
+		}
+	}
+}
+
+func TestBatchnormWorkers(t *testing.T) {
+	assert := assert.New(t)
+	n, err := newNormNetwork(6, 5)
+	assert.NoError(err)
+	n.SetMode(TRAINING)
+	c := &config.TrainConfig{Cost: "loglike", Lambda: 1.0}
+	cost, err := n.getCost(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	grad, gradCost, norms, err := n.gradient(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	// gradient is calculated along with the cost of its forward pass
+	assert.Equal(cost, gradCost)
+	// batch statistics of the whole batch are used regardless of the number of workers
+	for _, workers := range []int{2, 3, 5} {
+		c.Workers = workers
+		parCost, err := n.getCost(c, nil, inMx, labelsVec)
+		assert.NoError(err)
+		assert.InDelta(cost, parCost, 1e-12)
+		parGrad, parCost, parNorms, err := n.gradient(c, nil, inMx, labelsVec)
+		assert.NoError(err)
+		assert.InDelta(cost, parCost, 1e-12)
+		for i := range grad {
+			assert.InDelta(grad[i], parGrad[i], 1e-12)
+		}
+		for j := range norms[1].mean {
+			assert.InDelta(norms[1].mean[j], parNorms[1].mean[j], 1e-12)
+			assert.InDelta(norms[1].variance[j], parNorms[1].variance[j], 1e-12)
+		}
+	}
+	// gradient evaluation does not update running statistics
+	assert.Equal(0.0, n.Layers()[1].stats.At(0, 0))
+	assert.Equal(1.0, n.Layers()[1].stats.At(0, 1))
+}
+
+func TestBatchnormDecay(t *testing.T) {
+	assert := assert.New(t)
+	n, err := newNormNetwork(6)
+	assert.NoError(err)
+	params := n.params()
+	assert.Len(params, 3)
+	grads := make([]*mat64.Dense, len(params))
+	for i, layer := range params {
+		r, c := layer.Weights().Dims()
+		grads[i] = mat64.NewDense(r, c, nil)
+	}
+	weights := mat64.DenseCopyOf(params[0].Weights())
+	norm := mat64.DenseCopyOf(params[1].Weights())
+	// zero gradient leaves only the decoupled weight decay
+	adamw := &Adam{Beta1: defaultBeta1, Beta2: defaultBeta2, WeightDecay: 0.5}
+	adamw.Update(params, grads, 0.1)
+	assert.False(mat64.Equal(weights, params[0].Weights()))
+	// batch normalization shift and scale are not decayed
+	assert.True(mat64.Equal(norm, params[1].Weights()))
+}
+
+func TestTrainBatchnorm(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "loglike",
+		Learningrate: 0.01,
+		Epochs:       100,
+		Optimize:     &config.OptimConfig{Method: "adam", Batchsize: 5},
+	}
+	// running statistics are updated once per training step
+	n, err := newNormNetwork(6)
+	assert.NoError(err)
+	n.SetMode(TRAINING)
+	_, _, norms, err := n.gradient(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	n.SetMode(INFERENCE)
+	epochs := c.Epochs
+	c.Epochs = 1
+	assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+	c.Epochs = epochs
+	for j, mean := range norms[1].mean {
+		assert.InDelta(0.1*mean, n.Layers()[1].stats.At(j, 0), 1e-9)
+		assert.InDelta(0.9+0.1*norms[1].variance[j], n.Layers()[1].stats.At(j, 1), 1e-9)
+	}
+	n, err = newNormNetwork(16, 8, 8)
+	assert.NoError(err)
+	initCost, err := n.getCost(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+	cost, err := n.getCost(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	assert.True(cost < initCost)
+	// running statistics were updated during training
+	assert.False(math.Abs(n.Layers()[1].stats.At(0, 1)-1.0) < 1e-9)
+	// loaded network predicts identically
+	expected, err := n.Classify(inMx)
+	assert.NoError(err)
+	loaded, err := newNormNetwork(16, 8, 8)
+	assert.NoError(err)
+	assert.NoError(LoadFromFile(loaded))
+	for i, layer := range loaded.Layers()[1:4] {
+		assert.True(mat64.Equal(n.Layers()[i+1].norm.Weights(), layer.norm.Weights()))
+		assert.True(mat64.Equal(n.Layers()[i+1].stats, layer.stats))
+	}
+	classMx, err := loaded.Classify(inMx)
+	assert.NoError(err)
+	assert.True(mat64.Equal(expected, classMx))
+	// full batch optimization trains batch normalization parameters too
+	c = &config.TrainConfig{
+		Kind:     "backprop",
+		Cost:     "loglike",
+		Epochs:   1,
+		Optimize: &config.OptimConfig{Method: "bfgs", Iterations: 10},
+	}
+	n, err = newNormNetwork(16, 8, 8)
+	assert.NoError(err)
+	shift := n.Layers()[1].norm.Weights().At(0, 0)
+	assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+	assert.NotEqual(shift, n.Layers()[1].norm.Weights().At(0, 0))
+	assert.Equal(INFERENCE, n.Mode())
+	// running statistics are the statistics of the whole data set
+	n.SetMode(TRAINING)
+	_, _, norms, err = n.gradient(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	n.SetMode(INFERENCE)
+	assert.Equal(norms[1].mean, mat64.Col(nil, 0, n.Layers()[1].stats))
+	assert.Equal(norms[1].variance, mat64.Col(nil, 1, n.Layers()[1].stats))
+}
@@ -0,0 +1,193 @@
+package neural
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/gonum/floats"
+	"github.com/gonum/optimize"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// Metrics contains neural network training metrics
+type Metrics struct {
+	// Epoch is the number of completed training epochs
+	Epoch int
+	// Cost is the training cost
+	Cost float64
+	// LearningRate is the learning rate used by the latest mini-batch training step
+	LearningRate float64
+	// Validated is true if the network has been validated on validation data set
+	Validated bool
+	// Accuracy is the percentage of successful classifications of validation data set
+	Accuracy float64
+	// Regression contains regression metrics of validation data set. It is nil unless
+	// the validated network has PREDICT task
+	Regression *RegressionMetrics
+	// MultiLabel contains multilabel metrics of validation data set. It is nil unless
+	// the validated network has MULTILABEL task
+	MultiLabel *MultiLabelMetrics
+	// ValidationCost is the cost of validation data set without regularization
+	ValidationCost float64
+	// Stopped is true if the training has been stopped early
+	Stopped bool
+	// BestEpoch is the epoch whose weights were restored by early stopping
+	BestEpoch int
+}
+
+// Callback observes neural network training progress
+type Callback interface {
+	// OnTrainBegin is called before the first training epoch
+	OnTrainBegin(c *config.TrainConfig)
+	// OnIteration is called after every optimization iteration: major iteration of
+	// full batch optimization or mini-batch step. It receives the iteration number
+	// within the epoch, the cost and the L2 norm of the gradient before and after clipping.
+	// Both norms are equal if the gradient is not clipped
+	OnIteration(iter int, cost, gradNorm, clippedNorm float64)
+	// OnEpochEnd is called after every training epoch
+	OnEpochEnd(m Metrics)
+	// OnTrainEnd is called when the training finishes. err is nil if the training succeeded
+	OnTrainEnd(m Metrics, err error)
+}
+
+// NopCallback implements Callback interface and ignores all training events.
+// It can be embedded into custom callbacks which only observe some of the events.
+type NopCallback struct{}
+
+// OnTrainBegin implements Callback interface
+func (NopCallback) OnTrainBegin(c *config.TrainConfig) {}
+
+// OnIteration implements Callback interface
+func (NopCallback) OnIteration(iter int, cost, gradNorm, clippedNorm float64) {}
+
+// OnEpochEnd implements Callback interface
+func (NopCallback) OnEpochEnd(m Metrics) {}
+
+// OnTrainEnd implements Callback interface
+func (NopCallback) OnTrainEnd(m Metrics, err error) {}
+
+// Callbacks dispatches training events to all of its callbacks in order
+type Callbacks []Callback
+
+// OnTrainBegin implements Callback interface
+func (cbs Callbacks) OnTrainBegin(c *config.TrainConfig) {
+	for _, cb := range cbs {
+		cb.OnTrainBegin(c)
+	}
+}
+
+// OnIteration implements Callback interface
+func (cbs Callbacks) OnIteration(iter int, cost, gradNorm, clippedNorm float64) {
+	for _, cb := range cbs {
+		cb.OnIteration(iter, cost, gradNorm, clippedNorm)
+	}
+}
+
+// OnEpochEnd implements Callback interface
+func (cbs Callbacks) OnEpochEnd(m Metrics) {
+	for _, cb := range cbs {
+		cb.OnEpochEnd(m)
+	}
+}
+
+// OnTrainEnd implements Callback interface
+func (cbs Callbacks) OnTrainEnd(m Metrics, err error) {
+	for _, cb := range cbs {
+		cb.OnTrainEnd(m, err)
+	}
+}
+
+// Printer implements Callback interface and prints training progress to the supplied writer
+type Printer struct {
+	// W is the writer the progress is printed to
+	W io.Writer
+	// Iterations enables printing of every optimization iteration
+	Iterations bool
+	// c is configuration of the running training
+	c *config.TrainConfig
+}
+
+// NewPrinter returns new training progress printer.
+// If iterations is true every optimization iteration is printed too.
+func NewPrinter(w io.Writer, iterations bool) *Printer {
+	return &Printer{W: w, Iterations: iterations}
+}
+
+// OnTrainBegin implements Callback interface
+func (p *Printer) OnTrainBegin(c *config.TrainConfig) {
+	p.c = c
+	fmt.Fprintf(p.W, "Training %s network using %s optimization ...\n", c.Kind, c.Optimize.Method)
+}
+
+// OnIteration implements Callback interface
+func (p *Printer) OnIteration(iter int, cost, gradNorm, clippedNorm float64) {
+	if !p.Iterations {
+		return
+	}
+	if p.c != nil && p.c.Kind == "backprop" {
+		fmt.Fprintf(p.W, "(%v out of a minimum of %v) Current Cost: %f, Gradient norm: %f",
+			iter, p.c.Optimize.Iterations, cost, gradNorm)
+	} else {
+		fmt.Fprintf(p.W, "(batch %v) Current Cost: %f, Gradient norm: %f", iter, cost, gradNorm)
+	}
+	if p.c != nil && p.c.Clip != nil {
+		fmt.Fprintf(p.W, ", Clipped norm: %f", clippedNorm)
+	}
+	fmt.Fprintln(p.W)
+}
+
+// OnEpochEnd implements Callback interface
+func (p *Printer) OnEpochEnd(m Metrics) {
+	fmt.Fprintf(p.W, "\nEpoch %v... Cost: %f", m.Epoch, m.Cost)
+	if m.LearningRate > 0 {
+		fmt.Fprintf(p.W, ", Learning rate: %g", m.LearningRate)
+	}
+	fmt.Fprintln(p.W)
+	if m.Validated && m.Regression != nil {
+		fmt.Fprintf(p.W, "Validation %s, Validation cost: %f\n", m.Regression, m.ValidationCost)
+	} else if m.Validated && m.MultiLabel != nil {
+		fmt.Fprintf(p.W, "Validation %s, Validation cost: %f\n", m.MultiLabel, m.ValidationCost)
+	} else if m.Validated {
+		fmt.Fprintf(p.W, "Validation accuracy: %f, Validation cost: %f\n", m.Accuracy, m.ValidationCost)
+	}
+	if m.Stopped {
+		fmt.Fprintf(p.W, "\nNo improvement of validation metric, stopping early\n")
+	}
+}
+
+// OnTrainEnd implements Callback interface
+func (p *Printer) OnTrainEnd(m Metrics, err error) {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		fmt.Fprintf(p.W, "Training interrupted after %d epochs: %s\n", m.Epoch, err)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(p.W, "Training failed after %d epochs: %s\n", m.Epoch, err)
+		return
+	}
+	if m.BestEpoch > 0 {
+		fmt.Fprintf(p.W, "Restored weights of epoch %d\n", m.BestEpoch)
+	}
+}
+
+// iterRecorder implements optimize.Recorder and reports major iterations of full batch
+// optimization to training callback
+type iterRecorder struct {
+	cb Callback
+	// gradNorm returns the norm of the gradient at the given location before clipping
+	gradNorm func(x []float64) float64
+}
+
+// Init implements optimize.Recorder interface
+func (r iterRecorder) Init() error {
+	return nil
+}
+
+// Record implements optimize.Recorder interface
+func (r iterRecorder) Record(loc *optimize.Location, op optimize.Operation, stats *optimize.Stats) error {
+	if op&(optimize.InitIteration|optimize.MajorIteration) != 0 {
+		r.cb.OnIteration(stats.MajorIterations, loc.F, r.gradNorm(loc.X), floats.Norm(loc.Gradient, 2))
+	}
+	return nil
+}
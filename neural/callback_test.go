@@ -0,0 +1,85 @@
+package neural
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// recorder records training events
+type recorder struct {
+	NopCallback
+	begin   int
+	iters   []float64
+	metrics []Metrics
+	end     *Metrics
+	err     error
+}
+
+func (r *recorder) OnTrainBegin(c *config.TrainConfig) {
+	r.begin++
+}
+
+func (r *recorder) OnIteration(iter int, cost, gradNorm, clippedNorm float64) {
+	r.iters = append(r.iters, gradNorm)
+}
+
+func (r *recorder) OnEpochEnd(m Metrics) {
+	r.metrics = append(r.metrics, m)
+}
+
+func (r *recorder) OnTrainEnd(m Metrics, err error) {
+	r.end, r.err = &m, err
+}
+
+func TestCallback(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "xentropy",
+		Learningrate: 0.1,
+		Epochs:       3,
+		Optimize: &config.OptimConfig{
+			Method:    "sgd",
+			Batchsize: 2,
+		},
+	}
+	rec := &recorder{}
+	out := new(bytes.Buffer)
+	cbs := Callbacks{rec, NewPrinter(out, true)}
+	err = n.Train(context.Background(), c, inMx, labelsVec, inMx, labelsVec, "", cbs)
+	assert.NoError(err)
+	assert.Equal(rec.begin, 1)
+	// 5 samples in batches of 2 is 3 iterations per epoch
+	assert.Len(rec.iters, 9)
+	for _, gradNorm := range rec.iters {
+		assert.True(gradNorm > 0)
+	}
+	assert.Len(rec.metrics, 3)
+	for i, m := range rec.metrics {
+		assert.Equal(m.Epoch, i+1)
+		assert.True(m.Validated)
+		assert.Equal(m.LearningRate, 0.1)
+	}
+	assert.NotNil(rec.end)
+	assert.Equal(rec.end.Epoch, 3)
+	assert.NoError(rec.err)
+	assert.Contains(out.String(), "Validation accuracy")
+	// full batch optimization reports major iterations
+	rec = &recorder{}
+	err = n.Train(context.Background(), conf.Training, inMx, labelsVec, nil, nil, "", rec)
+	assert.NoError(err)
+	assert.NotEmpty(rec.iters)
+	assert.Len(rec.metrics, 1)
+	assert.False(rec.metrics[0].Validated)
+}
@@ -0,0 +1,103 @@
+package neural
+
+import (
+	"fmt"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+	"github.com/vstoianovici/nngoclassify/pkg/dataset"
+)
+
+// validateClasses validates imbalanced classes configuration. Nil configuration treats all classes equally.
+func validateClasses(c *config.TrainConfig) error {
+	if c.Classes == nil {
+		return nil
+	}
+	switch c.Classes.Balance {
+	case "":
+	case "weights":
+	case "sampling":
+		// over-sampling is only used by mini-batch training
+		if c.Kind != "sgd" {
+			return fmt.Errorf("Class balanced sampling not supported by %s training\n", c.Kind)
+		}
+	default:
+		return fmt.Errorf("Unsupported class balancing: %s\n", c.Classes.Balance)
+	}
+	if len(c.Classes.Weights) != 0 && c.Classes.Balance != "" {
+		return fmt.Errorf("Class weights can't be combined with %s balancing\n", c.Classes.Balance)
+	}
+	for _, weight := range c.Classes.Weights {
+		if weight < 0 {
+			return fmt.Errorf("Incorrect class weight: %f\n", weight)
+		}
+	}
+	return nil
+}
+
+// setClassWeights sets cost weights of network output classes. The weights are either taken
+// from the configuration or calculated from the label frequencies when balancing by weights.
+// It fails with error if the number of weights does not match the size of the output layer.
+func (n *Network) setClassWeights(c *config.ClassConfig, labels mat64.Matrix) error {
+	n.classWeights = nil
+	if c == nil {
+		return nil
+	}
+	layers := n.Layers()
+	classes, _ := layers[len(layers)-1].Weights().Dims()
+	switch {
+	case c.Balance == "weights":
+		weights, err := dataset.ClassWeights(labels, classes)
+		if err != nil {
+			return err
+		}
+		n.classWeights = weights
+	case len(c.Weights) != 0:
+		if len(c.Weights) != classes {
+			return fmt.Errorf("Incorrect number of class weights: %d\n", len(c.Weights))
+		}
+		n.classWeights = c.Weights
+	}
+	return nil
+}
+
+// clearClassWeights clears cost weights of network output classes once the training is finished
+func (n *Network) clearClassWeights() {
+	n.classWeights = nil
+}
+
+// balancedPerm returns shuffled indices of labeled samples in which every class is over-sampled
+// to the size of the most frequent class. Samples of minority classes are repeated in full rounds
+// and the remainder is drawn randomly without replacement.
+func (n *Network) balancedPerm(labels mat64.Matrix) []int {
+	byClass := make(map[int][]int)
+	var classes []int
+	largest := 0
+	rows, _ := labels.Dims()
+	for i := 0; i < rows; i++ {
+		label := int(labels.At(i, 0))
+		if _, ok := byClass[label]; !ok {
+			classes = append(classes, label)
+		}
+		byClass[label] = append(byClass[label], i)
+		if len(byClass[label]) > largest {
+			largest = len(byClass[label])
+		}
+	}
+	var idx []int
+	// classes are visited in the order of their first occurrence so the result is reproducible
+	for _, class := range classes {
+		samples := byClass[class]
+		for i := 0; i < largest/len(samples); i++ {
+			idx = append(idx, samples...)
+		}
+		for _, j := range n.rng.Perm(len(samples))[:largest%len(samples)] {
+			idx = append(idx, samples[j])
+		}
+	}
+	perm := make([]int, len(idx))
+	for i, j := range n.rng.Perm(len(idx)) {
+		perm[i] = idx[j]
+	}
+	return perm
+}
@@ -0,0 +1,149 @@
+package neural
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+	"github.com/vstoianovici/nngoclassify/pkg/matrix"
+)
+
+func TestWeightedCost(t *testing.T) {
+	assert := assert.New(t)
+	outMx := mat64.NewDense(3, 2, []float64{0.8, 0.2, 0.4, 0.6, 0.3, 0.7})
+	labels := []float64{0, 1, 1, 0, 0, 1}
+	weights := []float64{3.0, 0.5}
+	c := &config.TrainConfig{}
+	for cost, fn := range trainCost {
+		// only classification costs are weighted by classes
+		if costTasks[cost][0] != CLASS {
+			continue
+		}
+		unweighted := fn(c, nil)
+		weighted := fn(c, weights)
+		// cost functions modify their arguments
+		perSample := make([]float64, 3)
+		for i := range perSample {
+			inMx := mat64.NewDense(1, 1, nil)
+			perSample[i] = unweighted.CostFunc(inMx, mat64.DenseCopyOf(outMx.RowView(i).T()),
+				mat64.NewDense(1, 2, append([]float64(nil), labels[2*i:2*i+2]...)))
+		}
+		expected := (0.5*perSample[0] + 3.0*perSample[1] + 0.5*perSample[2]) / 3
+		inMx := mat64.NewDense(3, 1, nil)
+		actual := weighted.CostFunc(inMx, mat64.DenseCopyOf(outMx), mat64.NewDense(3, 2, append([]float64(nil), labels...)))
+		assert.InDelta(expected, actual, 1e-12, cost)
+		// unit weights don't change the cost
+		actual = fn(c, []float64{1, 1}).CostFunc(inMx, mat64.DenseCopyOf(outMx), mat64.NewDense(3, 2, append([]float64(nil), labels...)))
+		expected = unweighted.CostFunc(inMx, mat64.DenseCopyOf(outMx), mat64.NewDense(3, 2, append([]float64(nil), labels...)))
+		assert.InDelta(expected, actual, 1e-12, cost)
+		// output error of every sample is scaled by the weight of its class
+		labelsMx := mat64.NewDense(3, 2, append([]float64(nil), labels...))
+		delta := unweighted.Delta(outMx, labelsMx)
+		weightedDelta := weighted.Delta(outMx, labelsMx)
+		for i, w := range []float64{0.5, 3.0, 0.5} {
+			for j := 0; j < 2; j++ {
+				assert.InDelta(w*delta.At(i, j), weightedDelta.At(i, j), 1e-12, cost)
+			}
+		}
+	}
+}
+
+func TestClassWeightsGradient(t *testing.T) {
+	assert := assert.New(t)
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	for _, classes := range []*config.ClassConfig{
+		{Weights: []float64{0.5, 1.0, 2.0, 0.0, 3.0}},
+		{Balance: "weights"},
+	} {
+		n, err := NewNetwork(conf.Network)
+		assert.NoError(err)
+		c := &config.TrainConfig{Cost: "loglike", Lambda: 1.0, Classes: classes}
+		checks, err := n.CheckGradient(c, inMx, labelsVec, 5, 100)
+		assert.NoError(err)
+		for _, check := range checks {
+			assert.True(check.RelError < 1e-6, check.String())
+		}
+		// class weights don't outlive the gradient check
+		assert.Nil(n.classWeights)
+	}
+	// balanced weights follow label frequencies
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	assert.NoError(n.setClassWeights(&config.ClassConfig{Balance: "weights"}, labelsVec))
+	assert.Equal([]float64{1.0, 1.0, 0.5, 1.0, 1.0}, n.classWeights)
+	// every output class must have a weight
+	assert.Error(n.setClassWeights(&config.ClassConfig{Weights: []float64{1.0, 2.0}}, labelsVec))
+	assert.NoError(n.setClassWeights(nil, labelsVec))
+	assert.Nil(n.classWeights)
+}
+
+func TestBalancedSampling(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	// every class is over-sampled to the size of the largest class
+	labels := mat64.NewVector(7, []float64{0, 0, 0, 0, 0, 1, 2})
+	perm := n.balancedPerm(labels)
+	assert.Len(perm, 15)
+	counts := make(map[float64]int)
+	for _, i := range perm {
+		counts[labels.At(i, 0)]++
+	}
+	assert.Equal(map[float64]int{0: 5, 1: 5, 2: 5}, counts)
+	// 5 samples of 4 classes are sampled into 8 samples in batches of 2
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "loglike",
+		Learningrate: 0.1,
+		Optimize:     &config.OptimConfig{Method: "sgd", Batchsize: 2},
+		Classes:      &config.ClassConfig{Balance: "sampling"},
+	}
+	rec := &recorder{}
+	assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", rec))
+	assert.Len(rec.iters, 4)
+	// balanced sampling requires mini-batch training
+	c = &config.TrainConfig{
+		Kind:     "backprop",
+		Cost:     "loglike",
+		Optimize: &config.OptimConfig{Method: "bfgs", Iterations: 2},
+		Classes:  &config.ClassConfig{Balance: "sampling"},
+	}
+	assert.Error(ValidateTrainConfig(c))
+	c.Classes = &config.ClassConfig{Weights: []float64{1, 2, 1, 1, 1}, Balance: "weights"}
+	assert.Error(ValidateTrainConfig(c))
+	c.Classes = &config.ClassConfig{Weights: []float64{1, -2, 1, 1, 1}}
+	assert.Error(ValidateTrainConfig(c))
+	c.Classes = &config.ClassConfig{Balance: "foobar"}
+	assert.Error(ValidateTrainConfig(c))
+	c.Classes = &config.ClassConfig{Weights: []float64{1, 2, 1, 1, 1}}
+	assert.NoError(ValidateTrainConfig(c))
+	// weighted training still decreases the weighted cost
+	n, err = NewNetwork(conf.Network)
+	assert.NoError(err)
+	assert.NoError(n.setClassWeights(c.Classes, labelsVec))
+	initCost, err := n.getCost(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+	// class weights don't outlive the training
+	assert.Nil(n.classWeights)
+	assert.NoError(n.setClassWeights(c.Classes, labelsVec))
+	cost, err := n.getCost(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	assert.True(cost < initCost)
+	// validation cost is not weighted
+	outMx, err := n.ForwardProp(inMx, 2)
+	assert.NoError(err)
+	labelsMx, err := matrix.MakeLabelsMx(labelsVec, 5)
+	assert.NoError(err)
+	valCost, err := n.validationCost(c, inMx, labelsVec)
+	assert.NoError(err)
+	assert.InDelta(LogLikelihood{}.CostFunc(inMx, outMx, labelsMx), valCost, 1e-12)
+}
@@ -0,0 +1,41 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gonum/floats"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// clipGradient clips the gradient of all network layers in place. Gradient elements are
+// clipped by value first and then the whole gradient is rescaled to the maximum L2 norm.
+// It returns the L2 norm of the gradient before and after clipping.
+func clipGradient(c *config.ClipConfig, grad []float64) (float64, float64) {
+	norm := floats.Norm(grad, 2)
+	if c == nil {
+		return norm, norm
+	}
+	if c.Value > 0 {
+		for i, g := range grad {
+			grad[i] = math.Max(-c.Value, math.Min(c.Value, g))
+		}
+	}
+	clipped := floats.Norm(grad, 2)
+	if c.Norm > 0 && clipped > c.Norm {
+		floats.Scale(c.Norm/clipped, grad)
+		clipped = c.Norm
+	}
+	return norm, clipped
+}
+
+// validateClip validates gradient clipping configuration. Nil configuration disables clipping.
+func validateClip(c *config.ClipConfig) error {
+	if c == nil {
+		return nil
+	}
+	if c.Value < 0 || c.Norm < 0 {
+		return fmt.Errorf("Incorrect gradient clipping parameters: value %f, norm %f\n", c.Value, c.Norm)
+	}
+	return nil
+}
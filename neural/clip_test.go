@@ -0,0 +1,114 @@
+package neural
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/floats"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// normRecorder records gradient norms reported by training
+type normRecorder struct {
+	NopCallback
+	norms   []float64
+	clipped []float64
+}
+
+func (r *normRecorder) OnIteration(iter int, cost, gradNorm, clippedNorm float64) {
+	r.norms = append(r.norms, gradNorm)
+	r.clipped = append(r.clipped, clippedNorm)
+}
+
+func TestClipGradient(t *testing.T) {
+	assert := assert.New(t)
+	// no clipping
+	grad := []float64{3.0, -4.0}
+	norm, clipped := clipGradient(nil, grad)
+	assert.Equal(5.0, norm)
+	assert.Equal(5.0, clipped)
+	assert.Equal([]float64{3.0, -4.0}, grad)
+	// clipping by value
+	norm, clipped = clipGradient(&config.ClipConfig{Value: 3.0}, grad)
+	assert.Equal(5.0, norm)
+	assert.Equal([]float64{3.0, -3.0}, grad)
+	assert.InDelta(floats.Norm(grad, 2), clipped, 1e-12)
+	// clipping by norm keeps gradient direction
+	grad = []float64{3.0, -4.0}
+	norm, clipped = clipGradient(&config.ClipConfig{Norm: 1.0}, grad)
+	assert.Equal(5.0, norm)
+	assert.Equal(1.0, clipped)
+	assert.InDelta(0.6, grad[0], 1e-12)
+	assert.InDelta(-0.8, grad[1], 1e-12)
+	// gradient within limits is left unchanged
+	grad = []float64{0.3, -0.4}
+	norm, clipped = clipGradient(&config.ClipConfig{Value: 1.0, Norm: 1.0}, grad)
+	assert.InDelta(0.5, norm, 1e-12)
+	assert.Equal(norm, clipped)
+	assert.Equal([]float64{0.3, -0.4}, grad)
+	// incorrect clipping configuration
+	assert.NoError(validateClip(nil))
+	assert.Error(validateClip(&config.ClipConfig{Value: -1.0}))
+	assert.Error(validateClip(&config.ClipConfig{Norm: -1.0}))
+}
+
+func TestTrainClip(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "loglike",
+		Learningrate: 0.1,
+		Optimize:     &config.OptimConfig{Method: "sgd", Batchsize: 2},
+		Clip:         &config.ClipConfig{Norm: 0.01},
+	}
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	rec := &normRecorder{}
+	assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", rec))
+	assert.NotEmpty(rec.norms)
+	for i := range rec.norms {
+		assert.True(rec.norms[i] > 0.01)
+		assert.InDelta(0.01, rec.clipped[i], 1e-9)
+	}
+	// full batch training clips the gradient too
+	for _, method := range []string{"gd", "bfgs"} {
+		c = &config.TrainConfig{
+			Kind:     "backprop",
+			Cost:     "loglike",
+			Optimize: &config.OptimConfig{Method: method, Iterations: 3},
+			Clip:     &config.ClipConfig{Norm: 0.01},
+		}
+		n, err = NewNetwork(conf.Network)
+		assert.NoError(err)
+		rec = &normRecorder{}
+		assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", rec))
+		assert.NotEmpty(rec.norms, method)
+		for i := range rec.norms {
+			assert.True(rec.norms[i] > 0.01, method)
+			assert.InDelta(0.01, rec.clipped[i], 1e-9, method)
+		}
+	}
+	// the reported norm is the norm of the accepted location before clipping
+	c.Clip = nil
+	rec = &normRecorder{}
+	assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", rec))
+	assert.NotEmpty(rec.norms)
+	for i := range rec.norms {
+		assert.InDelta(rec.clipped[i], rec.norms[i], 1e-12)
+	}
+	// incorrect clipping configuration is rejected
+	c = &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "loglike",
+		Learningrate: 0.1,
+		Optimize:     &config.OptimConfig{Method: "sgd", Batchsize: 2},
+		Clip:         &config.ClipConfig{Value: -1},
+	}
+	assert.Error(ValidateTrainConfig(c))
+}
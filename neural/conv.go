@@ -0,0 +1,288 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+	"github.com/vstoianovici/nngoclassify/pkg/helpers"
+)
+
+// shape is shape of images stored in matrix rows: height, width and channels.
+// Pixels are stored row after row with the channels of every pixel next to each other.
+type shape struct {
+	h, w, c int
+}
+
+// size returns number of values of a single image
+func (s shape) size() int {
+	return s.h * s.w * s.c
+}
+
+// spatial holds configuration of conv, pooling and flatten layers which process images
+// stored in matrix rows. Conv layer weights matrix holds a row per filter: the bias
+// in the 1st column is followed by kernel weights ordered the same way as image pixels.
+type spatial struct {
+	// kind is spatial layer type: conv, maxpool, avgpool, flatten
+	kind string
+	// in is shape of input images
+	in shape
+	// out is shape of output images
+	out shape
+	// kernel is side of square convolution kernel or pooling window
+	kernel int
+	// stride is step of the kernel
+	stride int
+	// padding is number of zero pixels added to every side of input images
+	padding int
+}
+
+// newSpatialLayer creates conv, pooling or flatten HIDDEN layer processing input images of the given shape
+// using the supplied random generator. It returns the layer and shape of its output images which
+// is nil for flatten layer. It fails with error if the layer configuration is invalid.
+func newSpatialLayer(c *config.LayerConfig, in shape, rng *rand.Rand) (*Layer, *shape, error) {
+	if c.Kind != "hidden" {
+		return nil, nil, fmt.Errorf("Layer type %s not supported by %s layer\n", c.Type, c.Kind)
+	}
+	if c.Dropout != 0 || c.Batchnorm {
+		return nil, nil, fmt.Errorf("Dropout and batch normalization not supported by %s layer\n", c.Type)
+	}
+	s := &spatial{kind: c.Type, in: in, out: in}
+	layer := &Layer{kind: HIDDEN, spatial: s, rng: rng}
+	layer.id = helpers.PseudoRandString(10, rng)
+	switch c.Type {
+	case "flatten":
+		return layer, nil, nil
+	case "conv", "maxpool", "avgpool":
+	default:
+		return nil, nil, fmt.Errorf("Unsupported layer type: %s\n", c.Type)
+	}
+	conv := c.Conv
+	if conv == nil || conv.Kernel <= 0 || conv.Stride < 0 || conv.Padding < 0 || conv.Padding >= conv.Kernel {
+		return nil, nil, fmt.Errorf("Incorrect %s layer configuration: %v\n", c.Type, conv)
+	}
+	s.kernel, s.stride, s.padding = conv.Kernel, conv.Stride, conv.Padding
+	// convolution slides by a single pixel and pooling by the whole window by default
+	if s.stride == 0 {
+		s.stride = 1
+		if c.Type != "conv" {
+			s.stride = s.kernel
+		}
+	}
+	// kernel must fit into padded input images
+	if in.h+2*s.padding < s.kernel || in.w+2*s.padding < s.kernel {
+		return nil, nil, fmt.Errorf("Kernel %d exceeds %s layer input: %v\n", s.kernel, c.Type, in)
+	}
+	s.out.h = (in.h+2*s.padding-s.kernel)/s.stride + 1
+	s.out.w = (in.w+2*s.padding-s.kernel)/s.stride + 1
+	if c.Type == "conv" {
+		if conv.Filters <= 0 {
+			return nil, nil, fmt.Errorf("Incorrect number of conv layer filters: %d\n", conv.Filters)
+		}
+		if c.NeurFn == nil {
+			return nil, nil, fmt.Errorf("Incorrect conv layer neuron configuration: %v\n", c.NeurFn)
+		}
+		if err := layer.setActivation(c.NeurFn); err != nil {
+			return nil, nil, err
+		}
+		s.out.c = conv.Filters
+		// every filter sees all channels of the kernel window
+		var err error
+		fanIn := s.kernel * s.kernel * in.c
+		layer.weights, err = initWeights(c.Init, conv.Filters, fanIn, rng)
+		if err != nil {
+			return nil, nil, err
+		}
+		layer.deltas = mat64.NewDense(conv.Filters, fanIn+1, nil)
+	}
+	return layer, &s.out, nil
+}
+
+// window calls fn for every input pixel of the kernel window of output pixel (y, x) with the offset
+// of the pixel within the kernel and the index of its first channel in the input image.
+// Padding pixels are skipped.
+func (s *spatial) window(y, x int, fn func(k, idx int)) {
+	for ky := 0; ky < s.kernel; ky++ {
+		iy := y*s.stride - s.padding + ky
+		if iy < 0 || iy >= s.in.h {
+			continue
+		}
+		for kx := 0; kx < s.kernel; kx++ {
+			ix := x*s.stride - s.padding + kx
+			if ix < 0 || ix >= s.in.w {
+				continue
+			}
+			fn(ky*s.kernel+kx, (iy*s.in.w+ix)*s.in.c)
+		}
+	}
+}
+
+// spatialActivate calculates activation function inputs and outputs of spatial layer for given input.
+// Pooling and flatten layers have no activation function so their activation inputs are their outputs.
+func (l *Layer) spatialActivate(inputMx mat64.Matrix) (*mat64.Dense, *mat64.Dense, error) {
+	s := l.spatial
+	if _, cols := inputMx.Dims(); cols != s.in.size() {
+		return nil, nil, fmt.Errorf("Dimension mismatch. Image: %d, Input: %d\n", s.in.size(), cols)
+	}
+	in := denseOf(inputMx)
+	switch s.kind {
+	case "conv":
+		actIn := s.convolve(in, l.weights)
+		out := new(mat64.Dense)
+		out.Apply(l.act, actIn)
+		return actIn, out, nil
+	case "flatten":
+		out := mat64.DenseCopyOf(in)
+		return out, out, nil
+	default:
+		out := s.pool(in)
+		return out, out, nil
+	}
+}
+
+// convolve convolves every input image with the layer filters and adds their biases
+func (s *spatial) convolve(in, weights *mat64.Dense) *mat64.Dense {
+	rows, _ := in.Dims()
+	filters := s.out.c
+	out := mat64.NewDense(rows, s.out.size(), nil)
+	for i := 0; i < rows; i++ {
+		inRow, outRow := in.RawRowView(i), out.RawRowView(i)
+		for y := 0; y < s.out.h; y++ {
+			for x := 0; x < s.out.w; x++ {
+				o := (y*s.out.w + x) * filters
+				for f := 0; f < filters; f++ {
+					outRow[o+f] = weights.At(f, 0)
+				}
+				s.window(y, x, func(k, idx int) {
+					for f := 0; f < filters; f++ {
+						w := weights.RawRowView(f)[1+k*s.in.c:]
+						for ch := 0; ch < s.in.c; ch++ {
+							outRow[o+f] += w[ch] * inRow[idx+ch]
+						}
+					}
+				})
+			}
+		}
+	}
+	return out
+}
+
+// pool returns maximum or average of every channel of every pooling window of input images.
+// Padding pixels are not pooled.
+func (s *spatial) pool(in *mat64.Dense) *mat64.Dense {
+	rows, _ := in.Dims()
+	out := mat64.NewDense(rows, s.out.size(), nil)
+	for i := 0; i < rows; i++ {
+		inRow, outRow := in.RawRowView(i), out.RawRowView(i)
+		for y := 0; y < s.out.h; y++ {
+			for x := 0; x < s.out.w; x++ {
+				o := (y*s.out.w + x) * s.out.c
+				for ch := 0; ch < s.out.c; ch++ {
+					max, sum, count := math.Inf(-1), 0.0, 0
+					s.window(y, x, func(k, idx int) {
+						max = math.Max(max, inRow[idx+ch])
+						sum += inRow[idx+ch]
+						count++
+					})
+					outRow[o+ch] = max
+					if s.kind == "avgpool" {
+						outRow[o+ch] = sum / float64(count)
+					}
+				}
+			}
+		}
+	}
+	return out
+}
+
+// backward accumulates deltas of conv layer weights into deltas matrix given the layer input
+// and errors of layer activation inputs. If inErr is true it returns errors of the layer input:
+// errors of max pooling outputs are passed to the maximum input of the pooling window
+// and errors of average pooling outputs are spread evenly over the pooling window.
+func (s *spatial) backward(weights *mat64.Dense, inMx, errMx mat64.Matrix, deltas *mat64.Dense, inErr bool) *mat64.Dense {
+	if s.kind == "flatten" {
+		if !inErr {
+			return nil
+		}
+		return mat64.DenseCopyOf(errMx)
+	}
+	in, errs := denseOf(inMx), denseOf(errMx)
+	rows, _ := in.Dims()
+	var layerErr *mat64.Dense
+	if inErr {
+		layerErr = mat64.NewDense(rows, s.in.size(), nil)
+	}
+	for i := 0; i < rows; i++ {
+		inRow, errRow := in.RawRowView(i), errs.RawRowView(i)
+		var inErrRow []float64
+		if inErr {
+			inErrRow = layerErr.RawRowView(i)
+		}
+		for y := 0; y < s.out.h; y++ {
+			for x := 0; x < s.out.w; x++ {
+				o := (y*s.out.w + x) * s.out.c
+				switch s.kind {
+				case "conv":
+					s.convBackward(weights, deltas, inRow, errRow[o:o+s.out.c], inErrRow, y, x)
+				case "maxpool":
+					for ch := 0; ch < s.out.c; ch++ {
+						max, maxIdx := math.Inf(-1), 0
+						s.window(y, x, func(k, idx int) {
+							if inRow[idx+ch] > max {
+								max, maxIdx = inRow[idx+ch], idx+ch
+							}
+						})
+						if inErr {
+							inErrRow[maxIdx] += errRow[o+ch]
+						}
+					}
+				case "avgpool":
+					if !inErr {
+						continue
+					}
+					count := 0
+					s.window(y, x, func(k, idx int) { count++ })
+					s.window(y, x, func(k, idx int) {
+						for ch := 0; ch < s.out.c; ch++ {
+							inErrRow[idx+ch] += errRow[o+ch] / float64(count)
+						}
+					})
+				}
+			}
+		}
+	}
+	return layerErr
+}
+
+// convBackward accumulates conv layer deltas and input errors of output pixel (y, x) of a single image
+// given the errors of its activation inputs in all filters. Input errors are not calculated if inErrRow is nil.
+func (s *spatial) convBackward(weights, deltas *mat64.Dense, inRow, errs, inErrRow []float64, y, x int) {
+	for f, e := range errs {
+		deltas.Set(f, 0, deltas.At(f, 0)+e)
+	}
+	s.window(y, x, func(k, idx int) {
+		for f, e := range errs {
+			if e == 0 {
+				continue
+			}
+			w := weights.RawRowView(f)[1+k*s.in.c:]
+			d := deltas.RawRowView(f)[1+k*s.in.c:]
+			for ch := 0; ch < s.in.c; ch++ {
+				d[ch] += e * inRow[idx+ch]
+				if inErrRow != nil {
+					inErrRow[idx+ch] += e * w[ch]
+				}
+			}
+		}
+	})
+}
+
+// denseOf returns the matrix if it's a dense matrix or its dense copy otherwise
+func denseOf(m mat64.Matrix) *mat64.Dense {
+	if d, ok := m.(*mat64.Dense); ok {
+		return d
+	}
+	return mat64.DenseCopyOf(m)
+}
@@ -0,0 +1,188 @@
+package neural
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// convArch returns architecture of a small convolutional network for 6x6 images with 2 channels
+func convArch() *config.NetArch {
+	return &config.NetArch{
+		Input: &config.LayerConfig{Kind: "input", Size: 72, Shape: []int{6, 6, 2}},
+		Hidden: []*config.LayerConfig{
+			{Kind: "hidden", Type: "conv", NeurFn: &config.NeuronConfig{Activation: "tanh"},
+				Conv: &config.ConvConfig{Filters: 3, Kernel: 3, Padding: 1}},
+			{Kind: "hidden", Type: "maxpool", Conv: &config.ConvConfig{Kernel: 2}},
+			{Kind: "hidden", Type: "conv", NeurFn: &config.NeuronConfig{Activation: "sigmoid"},
+				Conv: &config.ConvConfig{Filters: 2, Kernel: 2}},
+			{Kind: "hidden", Type: "avgpool", Conv: &config.ConvConfig{Kernel: 2, Stride: 1, Padding: 1}},
+			{Kind: "hidden", Type: "flatten"},
+			{Kind: "hidden", Size: 4, NeurFn: &config.NeuronConfig{Activation: "sigmoid"}},
+		},
+		Output: &config.LayerConfig{Kind: "output", Size: 3, NeurFn: &config.NeuronConfig{Activation: "softmax"}},
+	}
+}
+
+// convData returns random images of convArch network and their labels
+func convData(samples int) (*mat64.Dense, *mat64.Vector) {
+	rng := rand.New(rand.NewSource(1))
+	images := mat64.NewDense(samples, 72, nil)
+	labels := mat64.NewVector(samples, nil)
+	for i := 0; i < samples; i++ {
+		for j := 0; j < 72; j++ {
+			images.Set(i, j, rng.Float64())
+		}
+		labels.SetVec(i, float64(i%3))
+	}
+	return images, labels
+}
+
+func TestSpatialLayers(t *testing.T) {
+	assert := assert.New(t)
+	// single 3x3 image with 1 channel
+	in := mat64.NewDense(1, 9, []float64{
+		1, 2, 3,
+		4, 5, 6,
+		7, 8, 9,
+	})
+	image := shape{h: 3, w: 3, c: 1}
+	// convolution with a single 2x2 filter
+	c := &config.LayerConfig{Kind: "hidden", Type: "conv", NeurFn: &config.NeuronConfig{Activation: "identity"},
+		Conv: &config.ConvConfig{Filters: 1, Kernel: 2}}
+	layer, out, err := newSpatialLayer(c, image, rand.New(rand.NewSource(1)))
+	assert.NoError(err)
+	assert.Equal(&shape{h: 2, w: 2, c: 1}, out)
+	assert.NoError(layer.SetWeights(mat64.NewDense(1, 5, []float64{0.5, 1, 0, 0, -1})))
+	_, actOut, _, err := layer.activate(in)
+	assert.NoError(err)
+	assert.Equal([]float64{-3.5, -3.5, -3.5, -3.5}, actOut.RawRowView(0))
+	// max and average pooling with padding ignore padding pixels
+	for _, tc := range []struct {
+		kind     string
+		expected []float64
+	}{
+		{"maxpool", []float64{1, 3, 7, 9}},
+		{"avgpool", []float64{1, 2.5, 5.5, 7}},
+	} {
+		c := &config.LayerConfig{Kind: "hidden", Type: tc.kind, Conv: &config.ConvConfig{Kernel: 2, Padding: 1}}
+		layer, out, err := newSpatialLayer(c, image, rand.New(rand.NewSource(1)))
+		assert.NoError(err)
+		assert.Equal(&shape{h: 2, w: 2, c: 1}, out)
+		assert.Nil(layer.Weights())
+		_, actOut, _, err := layer.activate(in)
+		assert.NoError(err)
+		assert.Equal(tc.expected, actOut.RawRowView(0))
+	}
+	// flatten layer keeps the images
+	layer, out, err = newSpatialLayer(&config.LayerConfig{Kind: "hidden", Type: "flatten"}, image, nil)
+	assert.NoError(err)
+	assert.Nil(out)
+	_, actOut, _, err = layer.activate(in)
+	assert.NoError(err)
+	assert.True(mat64.Equal(in, actOut))
+	// incorrect layer configurations
+	for _, c := range []*config.LayerConfig{
+		{Kind: "hidden", Type: "foo"},
+		{Kind: "output", Type: "flatten"},
+		{Kind: "hidden", Type: "maxpool"},
+		{Kind: "hidden", Type: "maxpool", Conv: &config.ConvConfig{Kernel: 4}},
+		{Kind: "hidden", Type: "maxpool", Conv: &config.ConvConfig{Kernel: 2, Padding: 2}},
+		{Kind: "hidden", Type: "conv", NeurFn: &config.NeuronConfig{Activation: "relu"}, Conv: &config.ConvConfig{Kernel: 2}},
+		{Kind: "hidden", Type: "conv", Conv: &config.ConvConfig{Filters: 1, Kernel: 2}},
+		{Kind: "hidden", Type: "conv", NeurFn: &config.NeuronConfig{Activation: "relu"}, Dropout: 0.5,
+			Conv: &config.ConvConfig{Filters: 1, Kernel: 2}},
+	} {
+		_, _, err := newSpatialLayer(c, image, rand.New(rand.NewSource(1)))
+		assert.Error(err)
+	}
+	// image dimensions must match
+	_, _, _, err = layer.activate(mat64.NewDense(1, 8, nil))
+	assert.Error(err)
+}
+
+func TestConvNetwork(t *testing.T) {
+	assert := assert.New(t)
+	arch := convArch()
+	n, err := NewNetwork(&config.NetConfig{Kind: "feedfwd", Arch: arch})
+	assert.NoError(err)
+	layers := n.Layers()
+	assert.Len(layers, 8)
+	// 6x6x2 -> 6x6x3 -> 3x3x3 -> 2x2x2 -> 3x3x2 -> 18 -> 4 -> 3
+	for i, size := range []int{108, 27, 8, 18, 18, 4, 3} {
+		assert.Equal(size, layers[i+1].size())
+	}
+	// pooling and flatten layers have no parameters
+	assert.Len(n.params(), 4)
+	// gradient of all network layers matches the numerical one
+	images, labels := convData(6)
+	c := &config.TrainConfig{Cost: "loglike", Lambda: 1.0}
+	checks, err := n.CheckGradient(c, images, labels, 6, 20)
+	assert.NoError(err)
+	assert.Len(checks, 4)
+	for i, layer := range []int{1, 3, 6, 7} {
+		assert.Equal(layer, checks[i].Layer)
+		assert.True(checks[i].RelError < 1e-6, checks[i].String())
+	}
+	// incorrect architectures
+	arch.Input.Shape = []int{6, 6, 3}
+	_, err = NewNetwork(&config.NetConfig{Kind: "feedfwd", Arch: arch})
+	assert.Error(err)
+	arch.Input.Shape = nil
+	_, err = NewNetwork(&config.NetConfig{Kind: "feedfwd", Arch: arch})
+	assert.Error(err)
+	// pooled images must be flattened before fully connected layer
+	arch = convArch()
+	arch.Hidden = append(arch.Hidden[:4], arch.Hidden[5])
+	_, err = NewNetwork(&config.NetConfig{Kind: "feedfwd", Arch: arch})
+	assert.Error(err)
+	arch.Hidden = arch.Hidden[:2]
+	_, err = NewNetwork(&config.NetConfig{Kind: "feedfwd", Arch: arch})
+	assert.Error(err)
+	// only fully connected layers support online backpropagation
+	assert.Error(n.BackProp(images, mat64.NewDense(6, 3, nil), 7))
+}
+
+func TestTrainConv(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	netConf := &config.NetConfig{Kind: "feedfwd", Seed: 3, Arch: convArch()}
+	n, err := NewNetwork(netConf)
+	assert.NoError(err)
+	images, labels := convData(12)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "loglike",
+		Learningrate: 0.5,
+		Epochs:       10,
+		Optimize:     &config.OptimConfig{Method: "adam", Batchsize: 4},
+	}
+	cost, err := n.getCost(c, nil, images, labels)
+	assert.NoError(err)
+	rec := &recorder{}
+	assert.NoError(n.Train(context.Background(), c, images, labels, images, labels, "", rec))
+	assert.True(rec.metrics[len(rec.metrics)-1].Cost < cost)
+	classMx, err := n.Classify(images)
+	assert.NoError(err)
+	rows, cols := classMx.Dims()
+	assert.Equal(12, rows)
+	assert.Equal(3, cols)
+	// trained network is restored from trainingdata
+	restored, err := NewNetwork(netConf)
+	assert.NoError(err)
+	assert.NoError(LoadFromFile(restored))
+	restoredMx, err := restored.Classify(images)
+	assert.NoError(err)
+	assert.True(mat64.Equal(classMx, restoredMx))
+	// full batch optimization trains conv layers too
+	c = &config.TrainConfig{
+		Kind:     "backprop",
+		Cost:     "loglike",
+		Optimize: &config.OptimConfig{Method: "bfgs", Iterations: 5},
+	}
+	assert.NoError(n.Train(context.Background(), c, images, labels, nil, nil, "", nil))
+}
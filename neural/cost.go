@@ -1,6 +1,8 @@
 package neural
 
 import (
+	"math"
+
 	"github.com/gonum/matrix/mat64"
 	"github.com/vstoianovici/nngoclassify/pkg/matrix"
 )
@@ -16,7 +18,10 @@ type Cost interface {
 }
 
 // CrossEntropy implements Cost interface
-type CrossEntropy struct{}
+type CrossEntropy struct {
+	// Weights contains cost weights of all classes. All classes have weight 1 if nil
+	Weights []float64
+}
 
 // CostFunc implements cross entropy cost function.
 // C = -(sum(sum((out_k .* log(out) + (1 - out_k) .* log(1 - out)), 2)))/samples
@@ -24,6 +29,8 @@ func (c CrossEntropy) CostFunc(inMx, outMx, labelsMx mat64.Matrix) float64 {
 	// safe switch type as matrix.MakeLabelsMx returns *mat64.Dense
 	lMx := labelsMx.(*mat64.Dense)
 	oMx := outMx.(*mat64.Dense)
+	// labels matrix is modified below so sample weights must be calculated first
+	weights := sampleWeights(c.Weights, lMx)
 	// out_k .* log(out)
 	costMxA := new(mat64.Dense)
 	costMxA.Apply(matrix.LogMx, oMx)
@@ -36,6 +43,7 @@ func (c CrossEntropy) CostFunc(inMx, outMx, labelsMx mat64.Matrix) float64 {
 	costMxB.MulElem(labelsMx, oMx)
 	// Cost matrix
 	costMxB.Add(costMxA, costMxB)
+	scaleRows(costMxB, weights)
 	// calculate the cost
 	samples, _ := inMx.Dims()
 	cost := -(mat64.Sum(costMxB) / float64(samples))
@@ -44,14 +52,19 @@ func (c CrossEntropy) CostFunc(inMx, outMx, labelsMx mat64.Matrix) float64 {
 
 // Delta calculates the error of the last layer and returns it
 // D = (out_k - out)
+// If class weights are set, rows of the error are scaled by the weight of the expected class.
 func (c CrossEntropy) Delta(outMx, expMx mat64.Matrix) mat64.Matrix {
 	deltaMx := new(mat64.Dense)
 	deltaMx.Sub(outMx, expMx)
+	scaleRows(deltaMx, sampleWeights(c.Weights, expMx))
 	return deltaMx
 }
 
 // LogLikelihood implements Cost interface
-type LogLikelihood struct{}
+type LogLikelihood struct {
+	// Weights contains cost weights of all classes. All classes have weight 1 if nil
+	Weights []float64
+}
 
 // CostFunc implements log-likelihood cost function.
 // C = -sum(sum(out_k.*log(out)))
@@ -63,6 +76,7 @@ func (c LogLikelihood) CostFunc(inMx, outMx, labelsMx mat64.Matrix) float64 {
 	costMx := new(mat64.Dense)
 	costMx.Apply(matrix.LogMx, oMx)
 	costMx.MulElem(lMx, costMx)
+	scaleRows(costMx, sampleWeights(c.Weights, lMx))
 	// calculate the cost
 	samples, _ := inMx.Dims()
 	cost := (-mat64.Sum(costMx) / float64(samples))
@@ -71,8 +85,137 @@ func (c LogLikelihood) CostFunc(inMx, outMx, labelsMx mat64.Matrix) float64 {
 
 // Delta calculates the error of the last layer and returns it
 // D = (out_k - out)
+// If class weights are set, rows of the error are scaled by the weight of the expected class.
 func (c LogLikelihood) Delta(outMx, expMx mat64.Matrix) mat64.Matrix {
 	deltaMx := new(mat64.Dense)
 	deltaMx.Sub(outMx, expMx)
+	scaleRows(deltaMx, sampleWeights(c.Weights, expMx))
+	return deltaMx
+}
+
+// MeanSquared implements Cost interface of regression networks with linear outputs
+type MeanSquared struct{}
+
+// CostFunc implements mean squared error cost function.
+// C = sum(sum((out - out_k).^2))/(2*samples)
+func (c MeanSquared) CostFunc(inMx, outMx, labelsMx mat64.Matrix) float64 {
+	errMx := new(mat64.Dense)
+	errMx.Sub(outMx, labelsMx)
+	errMx.MulElem(errMx, errMx)
+	samples, _ := inMx.Dims()
+	return mat64.Sum(errMx) / float64(2*samples)
+}
+
+// Delta calculates the error of the last layer and returns it
+// D = (out - out_k)
+func (c MeanSquared) Delta(outMx, expMx mat64.Matrix) mat64.Matrix {
+	deltaMx := new(mat64.Dense)
+	deltaMx.Sub(outMx, expMx)
+	return deltaMx
+}
+
+// MeanAbsolute implements Cost interface of regression networks with linear outputs
+type MeanAbsolute struct{}
+
+// CostFunc implements mean absolute error cost function.
+// C = sum(sum(abs(out - out_k)))/samples
+func (c MeanAbsolute) CostFunc(inMx, outMx, labelsMx mat64.Matrix) float64 {
+	errMx := new(mat64.Dense)
+	errMx.Sub(outMx, labelsMx)
+	errMx.Apply(func(i, j int, v float64) float64 { return math.Abs(v) }, errMx)
+	samples, _ := inMx.Dims()
+	return mat64.Sum(errMx) / float64(samples)
+}
+
+// Delta calculates the error of the last layer and returns it
+// D = sign(out - out_k)
+func (c MeanAbsolute) Delta(outMx, expMx mat64.Matrix) mat64.Matrix {
+	deltaMx := new(mat64.Dense)
+	deltaMx.Sub(outMx, expMx)
+	deltaMx.Apply(func(i, j int, v float64) float64 {
+		switch {
+		case v > 0:
+			return 1.0
+		case v < 0:
+			return -1.0
+		default:
+			return 0.0
+		}
+	}, deltaMx)
+	return deltaMx
+}
+
+// Huber implements Cost interface of regression networks with linear outputs.
+// It is quadratic for small errors and linear for errors larger than the threshold
+// so it is less sensitive to outliers than mean squared error.
+type Huber struct {
+	// Threshold is the absolute error at which the cost turns from quadratic to linear
+	Threshold float64
+}
+
+// CostFunc implements huber cost function.
+// C = sum(sum(h(out - out_k)))/samples, h(e) = e^2/2 if abs(e) <= t, t*(abs(e) - t/2) otherwise
+func (c Huber) CostFunc(inMx, outMx, labelsMx mat64.Matrix) float64 {
+	errMx := new(mat64.Dense)
+	errMx.Sub(outMx, labelsMx)
+	errMx.Apply(func(i, j int, v float64) float64 {
+		if e := math.Abs(v); e > c.Threshold {
+			return c.Threshold * (e - c.Threshold/2)
+		}
+		return v * v / 2
+	}, errMx)
+	samples, _ := inMx.Dims()
+	return mat64.Sum(errMx) / float64(samples)
+}
+
+// Delta calculates the error of the last layer and returns it
+// D = (out - out_k) clipped to [-t, t]
+func (c Huber) Delta(outMx, expMx mat64.Matrix) mat64.Matrix {
+	deltaMx := new(mat64.Dense)
+	deltaMx.Sub(outMx, expMx)
+	deltaMx.Apply(func(i, j int, v float64) float64 {
+		return math.Max(-c.Threshold, math.Min(c.Threshold, v))
+	}, deltaMx)
 	return deltaMx
 }
+
+// sampleWeights returns cost weights of every sample, i.e. row of labels matrix.
+// Sample weight is the weight of the most probable class of the row so that smoothed labels
+// are weighted the same as one-of-N labels. It returns nil if no class weights are supplied.
+func sampleWeights(weights []float64, labelsMx mat64.Matrix) []float64 {
+	if weights == nil {
+		return nil
+	}
+	rows, cols := labelsMx.Dims()
+	samples := make([]float64, rows)
+	for i := 0; i < rows; i++ {
+		class := 0
+		for j := 1; j < cols; j++ {
+			if labelsMx.At(i, j) > labelsMx.At(i, class) {
+				class = j
+			}
+		}
+		samples[i] = weights[class]
+	}
+	return samples
+}
+
+// smoothLabels mixes one-of-N labels matrix in place with uniform distribution over all classes:
+// out_k = (1 - smoothing) * out_k + smoothing / classes
+func smoothLabels(labelsMx *mat64.Dense, smoothing float64) {
+	if smoothing == 0 {
+		return
+	}
+	_, classes := labelsMx.Dims()
+	labelsMx.Apply(func(i, j int, v float64) float64 {
+		return (1-smoothing)*v + smoothing/float64(classes)
+	}, labelsMx)
+}
+
+// scaleRows scales every row of matrix by the corresponding weight. Nil weights leave the matrix unchanged.
+func scaleRows(mx *mat64.Dense, weights []float64) {
+	if weights == nil {
+		return
+	}
+	mx.Apply(func(i, j int, v float64) float64 { return v * weights[i] }, mx)
+}
@@ -0,0 +1,50 @@
+package neural
+
+import (
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmoothLabels(t *testing.T) {
+	assert := assert.New(t)
+	labelsMx := mat64.NewDense(2, 4, []float64{0, 1, 0, 0, 0, 0, 0, 1})
+	// no smoothing keeps one-of-N labels
+	smoothLabels(labelsMx, 0)
+	assert.True(mat64.Equal(mat64.NewDense(2, 4, []float64{0, 1, 0, 0, 0, 0, 0, 1}), labelsMx))
+	// smoothed labels are distributions with the most probable true class
+	smoothLabels(labelsMx, 0.2)
+	assert.True(mat64.EqualApprox(mat64.NewDense(2, 4, []float64{
+		0.05, 0.85, 0.05, 0.05,
+		0.05, 0.05, 0.05, 0.85,
+	}), labelsMx, 1e-12))
+	for i := 0; i < 2; i++ {
+		assert.InDelta(1.0, mat64.Sum(labelsMx.RowView(i)), 1e-12)
+	}
+	// class weights are applied per true class of smoothed labels
+	assert.Equal([]float64{2.0, 4.0}, sampleWeights([]float64{1, 2, 3, 4}, labelsMx))
+}
+
+func TestRegressionCosts(t *testing.T) {
+	assert := assert.New(t)
+	inMx := mat64.NewDense(2, 1, nil)
+	outMx := mat64.NewDense(2, 2, []float64{1.0, 2.0, 0.5, -1.0})
+	targetsMx := mat64.NewDense(2, 2, []float64{1.5, 2.0, 0.0, 1.0})
+	// errors are -0.5, 0, 0.5 and -2
+	for _, tc := range []struct {
+		cost  Cost
+		value float64
+		delta []float64
+	}{
+		{MeanSquared{}, (0.25 + 0.25 + 4) / 4, []float64{-0.5, 0, 0.5, -2}},
+		{MeanAbsolute{}, (0.5 + 0.5 + 2) / 2, []float64{-1, 0, 1, -1}},
+		{Huber{Threshold: 1.0}, (0.125 + 0.125 + 1.5) / 2, []float64{-0.5, 0, 0.5, -1}},
+	} {
+		assert.InDelta(tc.value, tc.cost.CostFunc(inMx, outMx, targetsMx), 1e-12)
+		assert.True(mat64.Equal(mat64.NewDense(2, 2, tc.delta), tc.cost.Delta(outMx, targetsMx)))
+	}
+	// huber cost equals half of squared error below the threshold
+	huber := Huber{Threshold: 3.0}.CostFunc(inMx, outMx, targetsMx)
+	assert.InDelta(MeanSquared{}.CostFunc(inMx, outMx, targetsMx), huber, 1e-12)
+}
@@ -0,0 +1,198 @@
+package neural
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+	"github.com/vstoianovici/nngoclassify/pkg/matrix"
+)
+
+// newDropoutNetwork creates network with a single hidden layer with the given dropout rate
+func newDropoutNetwork(dropout float64) (*Network, error) {
+	return NewNetwork(&config.NetConfig{
+		Kind: "feedfwd",
+		Arch: &config.NetArch{
+			Input: &config.LayerConfig{Kind: "input", Size: 4},
+			Hidden: []*config.LayerConfig{
+				{Kind: "hidden", Size: 50, NeurFn: &config.NeuronConfig{Activation: "sigmoid"}, Dropout: dropout},
+			},
+			Output: &config.LayerConfig{Kind: "output", Size: 5, NeurFn: &config.NeuronConfig{Activation: "softmax"}},
+		},
+	})
+}
+
+func TestDropoutLayer(t *testing.T) {
+	assert := assert.New(t)
+	c := &config.LayerConfig{
+		Kind:    "hidden",
+		Size:    100,
+		NeurFn:  &config.NeuronConfig{Activation: "sigmoid"},
+		Dropout: 0.5,
+	}
+	layer, err := NewLayer(c, 4)
+	assert.NoError(err)
+	assert.Equal(0.5, layer.Dropout())
+	// incorrect dropout rates
+	for _, rate := range []float64{-0.1, 1.0} {
+		c.Dropout = rate
+		_, err = NewLayer(c, 4)
+		assert.Error(err)
+	}
+	// output neurons can't be dropped
+	c.Dropout, c.Kind = 0.5, "output"
+	_, err = NewLayer(c, 4)
+	assert.Error(err)
+	// layer output is unchanged in INFERENCE mode
+	out, err := layer.FwdOut(inMx)
+	assert.NoError(err)
+	again, err := layer.FwdOut(inMx)
+	assert.NoError(err)
+	assert.True(mat64.Equal(out, again))
+	// outputs are either dropped or scaled in TRAINING mode
+	layer.mode = TRAINING
+	dropped, err := layer.FwdOut(inMx)
+	assert.NoError(err)
+	rows, cols := out.Dims()
+	zeros := 0
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if dropped.At(i, j) == 0 {
+				zeros++
+				continue
+			}
+			assert.InDelta(2*out.At(i, j), dropped.At(i, j), 1e-12)
+		}
+	}
+	assert.InDelta(0.5, float64(zeros)/float64(rows*cols), 0.1)
+}
+
+func TestNetworkMode(t *testing.T) {
+	assert := assert.New(t)
+	n, err := newDropoutNetwork(0.5)
+	assert.NoError(err)
+	assert.Equal(INFERENCE, n.Mode())
+	assert.Equal("INFERENCE", n.Mode().String())
+	expected, err := n.Classify(inMx)
+	assert.NoError(err)
+	accuracy, err := n.Validate(inMx, labelsVec)
+	assert.NoError(err)
+	n.SetMode(TRAINING)
+	for _, layer := range n.Layers() {
+		assert.Equal(TRAINING, layer.mode)
+	}
+	// dropout is disabled by classification and validation
+	classMx, err := n.Classify(inMx)
+	assert.NoError(err)
+	assert.True(mat64.Equal(expected, classMx))
+	valAccuracy, err := n.Validate(inMx, labelsVec)
+	assert.NoError(err)
+	assert.Equal(accuracy, valAccuracy)
+	assert.Equal(TRAINING, n.Mode())
+}
+
+func TestDropoutGradient(t *testing.T) {
+	assert := assert.New(t)
+	n, err := newDropoutNetwork(0.5)
+	assert.NoError(err)
+	n.SetMode(TRAINING)
+	c := &config.TrainConfig{Cost: "loglike"}
+	samples, _ := inMx.Dims()
+	masks := n.dropMasks(samples)
+	assert.NotNil(masks[1])
+	assert.Nil(masks[2])
+	labelsMx, err := matrix.MakeLabelsMx(labelsVec, 5)
+	assert.NoError(err)
+	// cost with fixed dropout masks
+	cost := func() float64 {
+		caches, err := n.forwardShards(inMx, 1, masks)
+		assert.NoError(err)
+		return trainCost[c.Cost](c, nil).CostFunc(inMx, caches[0].outs[2], labelsMx)
+	}
+	caches, err := n.forwardShards(inMx, 1, masks)
+	assert.NoError(err)
+	deltas := []*mat64.Dense{nil, mat64.NewDense(50, 5, nil), mat64.NewDense(5, 51, nil)}
+	errMxs := []mat64.Matrix{trainCost[c.Cost](c, nil).Delta(caches[0].outs[2], labelsMx)}
+	assert.NoError(n.backPropShards(caches, errMxs, [][]*mat64.Dense{deltas}, nil))
+	// compare with finite differences
+	for i, layer := range n.Layers()[1:] {
+		weightsMx := layer.Weights()
+		r, cols := weightsMx.Dims()
+		for row := 0; row < r; row += 7 {
+			for col := 0; col < cols; col += 3 {
+				w := weightsMx.At(row, col)
+				weightsMx.Set(row, col, w+gradCheckEps)
+				costPlus := cost()
+				weightsMx.Set(row, col, w-gradCheckEps)
+				costMinus := cost()
+				weightsMx.Set(row, col, w)
+				numerical := (costPlus - costMinus) / (2 * gradCheckEps)
+				assert.InDelta(numerical, deltas[i+1].At(row, col)/float64(samples), 1e-7)
+			}
+		}
+	}
+}
+
+// costRecorder records the costs reported to callback
+type costRecorder struct {
+	NopCallback
+	costs []float64
+}
+
+func (r *costRecorder) OnIteration(iter int, cost, gradNorm, clippedNorm float64) {
+	r.costs = append(r.costs, cost)
+}
+
+func TestDropoutStep(t *testing.T) {
+	assert := assert.New(t)
+	n, err := newDropoutNetwork(0.5)
+	assert.NoError(err)
+	n.SetMode(TRAINING)
+	c := &config.TrainConfig{Cost: "loglike", Optimize: &config.OptimConfig{Method: "sgd"}, Learningrate: 0.1}
+	samples, _ := inMx.Dims()
+	labelsMx, err := matrix.MakeLabelsMx(labelsVec, 5)
+	assert.NoError(err)
+	// cost and next random value after drawing a single set of dropout masks
+	n.rng.Seed(3)
+	caches, err := n.forwardShards(inMx, 1, n.dropMasks(samples))
+	assert.NoError(err)
+	expected := trainCost[c.Cost](c, nil).CostFunc(inMx, caches[0].outs[2], labelsMx)
+	next := n.rng.Int63()
+	// training step reports the cost of the forward pass used by the gradient
+	n.rng.Seed(3)
+	rec := &costRecorder{}
+	assert.NoError(n.sgdStep(c, firstOrder["sgd"](c.Optimize), n.params(), inMx, labelsVec, 1, 1, rec))
+	assert.Len(rec.costs, 1)
+	assert.InDelta(expected, rec.costs[0], 1e-12)
+	assert.Equal(next, n.rng.Int63())
+}
+
+func TestTrainDropout(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	n, err := newDropoutNetwork(0.2)
+	assert.NoError(err)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "loglike",
+		Learningrate: 0.5,
+		Epochs:       20,
+		Optimize:     &config.OptimConfig{Method: "sgd", Batchsize: 5},
+	}
+	initCost, err := n.getCost(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+	cost, err := n.getCost(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	assert.True(cost < initCost)
+	// network is back in INFERENCE mode after training
+	assert.Equal(INFERENCE, n.Mode())
+	// dropout is not supported by full batch optimization
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	assert.Error(n.Train(context.Background(), conf.Training, inMx, labelsVec, nil, nil, "", nil))
+}
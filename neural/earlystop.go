@@ -0,0 +1,114 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// earlyStop maps monitored validation metrics to functions which report
+// whether the new metric value improves the best one by more than minimum delta
+var earlyStop = map[string]func(metric, best, minDelta float64) bool{
+	"accuracy": func(metric, best, minDelta float64) bool {
+		return metric > best+minDelta
+	},
+	"cost": func(metric, best, minDelta float64) bool {
+		return metric < best-minDelta
+	},
+}
+
+// earlyStopping monitors validation metric after every training epoch and
+// keeps the network weights and training progress of the best epoch
+type earlyStopping struct {
+	// c is early stopping configuration
+	c *config.EarlyStopConfig
+	// best is the best value of the monitored metric
+	best float64
+	// bestEpoch is the epoch with the best metric value
+	bestEpoch int
+	// step is the number of mini-batch training steps completed by the best epoch
+	step int
+	// rate is the learning rate of the last training step of the best epoch
+	rate float64
+	// wait is the number of epochs since the last improvement
+	wait int
+	// weights contains network weights of the best epoch
+	weights []*mat64.Dense
+	// stats contains running statistics of batch normalized layers of the best epoch
+	stats []*mat64.Dense
+}
+
+// newEarlyStopping creates new early stopping monitor
+func newEarlyStopping(c *config.EarlyStopConfig) *earlyStopping {
+	best := math.Inf(1)
+	if c.Metric == "accuracy" {
+		best = math.Inf(-1)
+	}
+	return &earlyStopping{c: c, best: best}
+}
+
+// update records the monitored metric of the given epoch. If the metric improved,
+// it takes a snapshot of network weights and training progress. It returns true if the training should stop.
+func (e *earlyStopping) update(n *Network, epoch int, metric float64) bool {
+	if earlyStop[e.c.Metric](metric, e.best, e.c.Mindelta) {
+		e.best, e.bestEpoch, e.wait = metric, epoch, 0
+		e.step, e.rate = n.step, n.rate
+		e.weights = e.weights[:0]
+		for _, layer := range n.params() {
+			weights := new(mat64.Dense)
+			weights.Clone(layer.Weights())
+			e.weights = append(e.weights, weights)
+		}
+		e.stats = e.stats[:0]
+		for _, layer := range n.Layers() {
+			if layer.stats != nil {
+				stats := new(mat64.Dense)
+				stats.Clone(layer.stats)
+				e.stats = append(e.stats, stats)
+			}
+		}
+		return false
+	}
+	e.wait++
+	return e.wait >= e.c.Patience
+}
+
+// restore sets network weights, running statistics and training progress to the ones of the best epoch
+// so that resumed training continues from the best epoch. Nothing is restored if no epoch improved the metric.
+func (e *earlyStopping) restore(n *Network) {
+	if e.bestEpoch == 0 {
+		return
+	}
+	n.epoch, n.step, n.rate = e.bestEpoch, e.step, e.rate
+	for i, layer := range n.params() {
+		if i < len(e.weights) {
+			layer.Weights().Copy(e.weights[i])
+		}
+	}
+	i := 0
+	for _, layer := range n.Layers() {
+		if layer.stats != nil && i < len(e.stats) {
+			layer.stats.Copy(e.stats[i])
+			i++
+		}
+	}
+}
+
+// validateEarlyStop validates early stopping configuration
+func validateEarlyStop(c *config.EarlyStopConfig) error {
+	if c == nil {
+		return nil
+	}
+	if _, ok := earlyStop[c.Metric]; !ok {
+		return fmt.Errorf("Unsupported early stopping metric: %s\n", c.Metric)
+	}
+	if c.Patience <= 0 {
+		return fmt.Errorf("Incorrect early stopping patience: %d\n", c.Patience)
+	}
+	if c.Mindelta < 0 {
+		return fmt.Errorf("Incorrect early stopping minimum delta: %f\n", c.Mindelta)
+	}
+	return nil
+}
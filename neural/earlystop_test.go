@@ -0,0 +1,103 @@
+package neural
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+func TestEarlyStopping(t *testing.T) {
+	assert := assert.New(t)
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	// monitor validation cost with patience of 2 epochs
+	stopper := newEarlyStopping(&config.EarlyStopConfig{Metric: "cost", Patience: 2, Mindelta: 0.1})
+	n.step, n.rate = 10, 0.5
+	assert.False(stopper.update(n, 1, 1.0))
+	bestWeights := new(mat64.Dense)
+	bestWeights.Clone(n.Layers()[1].Weights())
+	// improvement smaller than minimum delta does not count
+	n.Layers()[1].Weights().Scale(2.0, n.Layers()[1].Weights())
+	n.epoch, n.step, n.rate = 3, 30, 0.1
+	assert.False(stopper.update(n, 2, 0.95))
+	assert.True(stopper.update(n, 3, 1.2))
+	assert.Equal(stopper.bestEpoch, 1)
+	assert.Equal(stopper.best, 1.0)
+	// best weights and training progress are restored
+	stopper.restore(n)
+	assert.True(mat64.Equal(n.Layers()[1].Weights(), bestWeights))
+	assert.Equal(1, n.Epoch())
+	assert.Equal(10, n.step)
+	assert.Equal(0.5, n.LearningRate())
+	// accuracy must increase
+	stopper = newEarlyStopping(&config.EarlyStopConfig{Metric: "accuracy", Patience: 1})
+	assert.False(stopper.update(n, 1, 50.0))
+	assert.False(stopper.update(n, 2, 60.0))
+	assert.True(stopper.update(n, 3, 60.0))
+	assert.Equal(stopper.bestEpoch, 2)
+	// nothing is restored if the metric never improved
+	stopper = newEarlyStopping(&config.EarlyStopConfig{Metric: "cost", Patience: 2})
+	assert.False(stopper.update(n, 1, math.NaN()))
+	assert.True(stopper.update(n, 2, math.NaN()))
+	assert.Equal(0, stopper.bestEpoch)
+	weights := mat64.DenseCopyOf(n.Layers()[1].Weights())
+	n.epoch, n.step, n.rate = 2, 20, 0.3
+	stopper.restore(n)
+	assert.True(mat64.Equal(weights, n.Layers()[1].Weights()))
+	assert.Equal(2, n.Epoch())
+	assert.Equal(20, n.step)
+	assert.Equal(0.3, n.LearningRate())
+}
+
+func TestTrainEarlyStop(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "xentropy",
+		Learningrate: 0.5,
+		Epochs:       50,
+		Optimize: &config.OptimConfig{
+			Method:    "sgd",
+			Batchsize: 5,
+		},
+		EarlyStop: &config.EarlyStopConfig{Metric: "accuracy", Patience: 3},
+	}
+	// early stopping requires validation data set
+	err = n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil)
+	assert.Error(err)
+	// incomplete validation data set
+	err = n.Train(context.Background(), c, inMx, labelsVec, inMx, nil, "", nil)
+	assert.Error(err)
+	// unsupported metric
+	c.EarlyStop.Metric = "foobar"
+	err = n.Train(context.Background(), c, inMx, labelsVec, inMx, labelsVec, "", nil)
+	assert.Error(err)
+	c.EarlyStop.Metric = "accuracy"
+	// training stops once accuracy stops improving
+	rec := &recorder{}
+	err = n.Train(context.Background(), c, inMx, labelsVec, inMx, labelsVec, "", rec)
+	assert.NoError(err)
+	last := rec.metrics[len(rec.metrics)-1]
+	assert.True(last.Stopped)
+	assert.True(last.Epoch < 50)
+	// checkpoint resumes from the best epoch
+	assert.Equal(rec.end.BestEpoch, n.Epoch())
+	resumed, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	assert.NoError(LoadFromFile(resumed))
+	assert.Equal(n.Epoch(), resumed.Epoch())
+	assert.Equal(n.step, resumed.step)
+}
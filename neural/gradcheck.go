@@ -0,0 +1,153 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// gradCheckEps is the weight perturbation used by central finite differences
+const gradCheckEps = 1e-5
+
+// GradCheck holds the result of numerical gradient check of a single network layer
+type GradCheck struct {
+	// Layer is the index of the checked layer
+	Layer int
+	// Norm is true if batch normalization parameters of the layer were checked instead of its weights
+	Norm bool
+	// Weights is the number of checked layer weights
+	Weights int
+	// RelError is the relative error between analytic and numerical gradient of the checked weights:
+	// |analytic - numerical| / (|analytic| + |numerical|)
+	RelError float64
+	// MaxRelError is the largest relative error of a single checked weight
+	MaxRelError float64
+}
+
+// String implements Stringer interface for pretty printing
+func (g GradCheck) String() string {
+	name := fmt.Sprintf("Layer %d", g.Layer)
+	if g.Norm {
+		name += " batchnorm"
+	}
+	return fmt.Sprintf("%s: %d weights checked, relative error: %e, max relative error: %e",
+		name, g.Weights, g.RelError, g.MaxRelError)
+}
+
+// CheckGradient compares the analytic gradient calculated by backpropagation with the gradient
+// estimated by central finite differences of the training cost.
+// The check is run on at most samples randomly selected data samples and at most weights
+// randomly selected weights of every layer. Network weights are left unchanged.
+// Batch normalization parameters of every batch normalized layer are checked separately.
+// It returns the results of the check per network layer or fails with error if either
+// the supplied configuration or data are invalid.
+func (n *Network) CheckGradient(c *config.TrainConfig, inMx *mat64.Dense, labels mat64.Matrix,
+	samples, weights int) ([]GradCheck, error) {
+	// config can't be nil
+	if c == nil {
+		return nil, fmt.Errorf("Invalid training config: %v\n", c)
+	}
+	// cost must be supported
+	if _, ok := trainCost[c.Cost]; !ok {
+		return nil, fmt.Errorf("Unsupported cost function: %s\n", c.Cost)
+	}
+	// cost must suit the network task
+	if err := n.validateTask(c); err != nil {
+		return nil, err
+	}
+	// regularization must be supported by the network
+	if err := validateRegularizer(c); err != nil {
+		return nil, err
+	}
+	for layer := range c.Lambdas {
+		if layer >= len(n.Layers()) {
+			return nil, fmt.Errorf("Lambda supplied for nonexistent layer: %d\n", layer)
+		}
+	}
+	// label smoothing factor must be in range [0, 1)
+	if c.Smoothing < 0 || c.Smoothing >= 1 {
+		return nil, fmt.Errorf("Incorrect label smoothing: %f\n", c.Smoothing)
+	}
+	if inMx == nil || labels == nil {
+		return nil, fmt.Errorf("Incorrect data supplied. In: %v, Out: %v\n", inMx, labels)
+	}
+	// checked cost is weighted by the same class weights as the training cost
+	if err := n.setClassWeights(c.Classes, labels); err != nil {
+		return nil, err
+	}
+	defer n.clearClassWeights()
+	if samples <= 0 || weights <= 0 {
+		return nil, fmt.Errorf("Number of checked samples and weights must be positive: %d, %d\n",
+			samples, weights)
+	}
+	// pick random subset of data samples
+	rows, _ := inMx.Dims()
+	if samples > rows {
+		samples = rows
+	}
+	batchMx, batchLabels := makeBatch(inMx, labels, n.rng.Perm(rows)[:samples])
+	// analytic gradient
+	grad, err := n.getGradient(c, nil, batchMx, batchLabels)
+	if err != nil {
+		return nil, err
+	}
+	params := n.params()
+	grads, err := layerGrads(params, grad)
+	if err != nil {
+		return nil, err
+	}
+	var checks []GradCheck
+	layer := 0
+	for i, param := range params {
+		// batch normalization parameters follow the weights of their layer
+		norm := layer > 0 && param == n.Layers()[layer].norm
+		if !norm {
+			// pooling and flatten layers have no parameters
+			layer++
+			for n.Layers()[layer] != param {
+				layer++
+			}
+		}
+		weightsMx := param.Weights()
+		r, cols := weightsMx.Dims()
+		count := weights
+		if count > r*cols {
+			count = r * cols
+		}
+		check := GradCheck{Layer: layer, Norm: norm, Weights: count}
+		var diffSq, anaSq, numSq float64
+		for _, idx := range n.rng.Perm(r * cols)[:count] {
+			row, col := idx/cols, idx%cols
+			w := weightsMx.At(row, col)
+			// cost at both sides of the weight
+			weightsMx.Set(row, col, w+gradCheckEps)
+			costPlus, err := n.getCost(c, nil, batchMx, batchLabels)
+			if err != nil {
+				weightsMx.Set(row, col, w)
+				return nil, err
+			}
+			weightsMx.Set(row, col, w-gradCheckEps)
+			costMinus, err := n.getCost(c, nil, batchMx, batchLabels)
+			weightsMx.Set(row, col, w)
+			if err != nil {
+				return nil, err
+			}
+			numerical := (costPlus - costMinus) / (2 * gradCheckEps)
+			analytic := grads[i].At(row, col)
+			diff := math.Abs(analytic - numerical)
+			if norm := math.Abs(analytic) + math.Abs(numerical); norm > 0 {
+				check.MaxRelError = math.Max(check.MaxRelError, diff/norm)
+			}
+			diffSq += diff * diff
+			anaSq += analytic * analytic
+			numSq += numerical * numerical
+		}
+		if norm := math.Sqrt(anaSq) + math.Sqrt(numSq); norm > 0 {
+			check.RelError = math.Sqrt(diffSq) / norm
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
@@ -0,0 +1,100 @@
+package neural
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+func TestCheckGradient(t *testing.T) {
+	assert := assert.New(t)
+	for _, tc := range []struct {
+		cost      string
+		hidden    string
+		param     float64
+		output    string
+		smoothing float64
+		ok        bool
+	}{
+		{"loglike", "sigmoid", 0, "softmax", 0, true},
+		{"loglike", "tanh", 0, "softmax", 0, true},
+		{"loglike", "relu", 0, "softmax", 0, true},
+		{"xentropy", "sigmoid", 0, "sigmoid", 0, true},
+		{"loglike", "leakyrelu", 0, "softmax", 0, true},
+		{"loglike", "leakyrelu", 0.2, "softmax", 0, true},
+		{"loglike", "elu", 0.5, "softmax", 0, true},
+		{"loglike", "selu", 0, "softmax", 0, true},
+		{"loglike", "softplus", 0, "softmax", 0, true},
+		{"loglike", "gelu", 0, "softmax", 0, true},
+		{"loglike", "swish", 0, "softmax", 0, true},
+		{"loglike", "swish", 2.0, "softmax", 0, true},
+		{"loglike", "identity", 0, "softmax", 0, true},
+		// smoothed labels
+		{"loglike", "sigmoid", 0, "softmax", 0.1, true},
+		{"xentropy", "sigmoid", 0, "sigmoid", 0.2, true},
+		// cross entropy delta assumes independent sigmoid outputs
+		{"xentropy", "sigmoid", 0, "softmax", 0, false},
+	} {
+		netConf := &config.NetConfig{
+			Kind: "feedfwd",
+			Arch: &config.NetArch{
+				Input: &config.LayerConfig{Kind: "input", Size: 4},
+				Hidden: []*config.LayerConfig{
+					{Kind: "hidden", Size: 5, NeurFn: &config.NeuronConfig{Activation: tc.hidden, Param: tc.param}},
+				},
+				Output: &config.LayerConfig{Kind: "output", Size: 5, NeurFn: &config.NeuronConfig{Activation: tc.output}},
+			},
+		}
+		n, err := NewNetwork(netConf)
+		assert.NoError(err)
+		weights := mat64.DenseCopyOf(n.Layers()[1].Weights())
+		c := &config.TrainConfig{Cost: tc.cost, Lambda: 1.0, Smoothing: tc.smoothing}
+		checks, err := n.CheckGradient(c, inMx, labelsVec, 3, 100)
+		assert.NoError(err)
+		assert.Len(checks, 2)
+		for _, check := range checks {
+			if tc.ok {
+				assert.True(check.RelError < 1e-6, check.String())
+			} else {
+				assert.True(check.RelError > 1e-3, check.String())
+			}
+		}
+		// weights are restored after the check
+		assert.True(mat64.Equal(weights, n.Layers()[1].Weights()))
+		// all weights of small layers are checked
+		assert.Equal(25, checks[0].Weights)
+		assert.Equal(30, checks[1].Weights)
+	}
+	// create new network
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	_, err = n.CheckGradient(nil, inMx, labelsVec, 3, 10)
+	assert.Error(err)
+	_, err = n.CheckGradient(&config.TrainConfig{Cost: "foo"}, inMx, labelsVec, 3, 10)
+	assert.Error(err)
+	_, err = n.CheckGradient(conf.Training, nil, labelsVec, 3, 10)
+	assert.Error(err)
+	_, err = n.CheckGradient(conf.Training, inMx, labelsVec, 0, 10)
+	assert.Error(err)
+	_, err = n.CheckGradient(&config.TrainConfig{Cost: "loglike", Smoothing: 1.0}, inMx, labelsVec, 3, 10)
+	assert.Error(err)
+	// regularization is validated the same way as for training
+	for _, c := range []*config.TrainConfig{
+		{Cost: "loglike", Lambda: -1.0},
+		{Cost: "loglike", Regularizer: "foo"},
+		{Cost: "loglike", Lambdas: map[int]float64{0: 1.0}},
+		{Cost: "loglike", Lambdas: map[int]float64{10: 1.0}},
+	} {
+		_, err = n.CheckGradient(c, inMx, labelsVec, 3, 10)
+		assert.Error(err)
+	}
+	checks, err := n.CheckGradient(conf.Training, inMx, labelsVec, 3, 10)
+	assert.NoError(err)
+	assert.Equal(10, checks[0].Weights)
+}
@@ -0,0 +1,119 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+	"github.com/vstoianovici/nngoclassify/pkg/matrix"
+)
+
+// initializers maps weights initialization schemes to functions which generate
+// weights matrix of a layer with out neurons and in inputs not accounting for bias
+var initializers = map[string]func(c *config.InitConfig, out, in int, rng *rand.Rand) *mat64.Dense{
+	"uniform": func(c *config.InitConfig, out, in int, rng *rand.Rand) *mat64.Dense {
+		// dimensions are always positive so MakeRandMx never fails
+		weights, _ := matrix.MakeRandMx(out, in, 0.0, 1.0, rng)
+		return weights
+	},
+	// Xavier/Glorot initialization suits sigmoid and tanh layers
+	"xavieruniform": func(c *config.InitConfig, out, in int, rng *rand.Rand) *mat64.Dense {
+		return randUniform(out, in, math.Sqrt(6/float64(in+out)), rng)
+	},
+	"xaviernormal": func(c *config.InitConfig, out, in int, rng *rand.Rand) *mat64.Dense {
+		return randNormal(out, in, math.Sqrt(2/float64(in+out)), rng)
+	},
+	// He initialization suits ReLU layers
+	"heuniform": func(c *config.InitConfig, out, in int, rng *rand.Rand) *mat64.Dense {
+		return randUniform(out, in, math.Sqrt(6/float64(in)), rng)
+	},
+	"henormal": func(c *config.InitConfig, out, in int, rng *rand.Rand) *mat64.Dense {
+		return randNormal(out, in, math.Sqrt(2/float64(in)), rng)
+	},
+	"lecun": func(c *config.InitConfig, out, in int, rng *rand.Rand) *mat64.Dense {
+		return randNormal(out, in, math.Sqrt(1/float64(in)), rng)
+	},
+	"orthogonal": func(c *config.InitConfig, out, in int, rng *rand.Rand) *mat64.Dense {
+		return randOrthogonal(out, in, rng)
+	},
+	"constant": func(c *config.InitConfig, out, in int, rng *rand.Rand) *mat64.Dense {
+		weights := mat64.NewDense(out, in, nil)
+		weights.Apply(func(i, j int, v float64) float64 { return c.Value }, weights)
+		return weights
+	},
+}
+
+// initWeights creates weights matrix of a layer with out neurons and in inputs using the supplied random generator.
+// The first column of the returned matrix holds bias weights which are initialized separately
+// from the rest of the weights. If no initialization is configured, all the weights
+// including bias weights are initialized by the default uniform scheme.
+func initWeights(c *config.InitConfig, out, in int, rng *rand.Rand) (*mat64.Dense, error) {
+	if c == nil {
+		return matrix.MakeRandMx(out, in+1, 0.0, 1.0, rng)
+	}
+	init, ok := initializers[c.Kind]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported weights initialization: %s\n", c.Kind)
+	}
+	weightsMx := init(c, out, in, rng)
+	layerMx := mat64.NewDense(out, in+1, nil)
+	layerMx.View(0, 1, out, in).(*mat64.Dense).Copy(weightsMx)
+	for i := 0; i < out; i++ {
+		layerMx.Set(i, 0, c.Bias)
+	}
+	return layerMx, nil
+}
+
+// randUniform returns matrix of random values uniformly distributed in [-limit, limit)
+func randUniform(rows, cols int, limit float64, rng *rand.Rand) *mat64.Dense {
+	randMx := mat64.NewDense(rows, cols, nil)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			randMx.Set(i, j, (2*rng.Float64()-1)*limit)
+		}
+	}
+	return randMx
+}
+
+// randNormal returns matrix of normally distributed random values with zero mean and std deviation
+func randNormal(rows, cols int, std float64, rng *rand.Rand) *mat64.Dense {
+	randMx := mat64.NewDense(rows, cols, nil)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			randMx.Set(i, j, rng.NormFloat64()*std)
+		}
+	}
+	return randMx
+}
+
+// randOrthogonal returns random matrix with orthonormal rows or columns, whichever are fewer.
+// It is the orthogonal factor of QR decomposition of a matrix of normally distributed values.
+func randOrthogonal(rows, cols int, rng *rand.Rand) *mat64.Dense {
+	// QR decomposition requires at least as many rows as columns
+	m, n := rows, cols
+	if m < n {
+		m, n = n, m
+	}
+	qr := new(mat64.QR)
+	qr.Factorize(randNormal(m, n, 1.0, rng))
+	q, r := new(mat64.Dense), new(mat64.Dense)
+	q.QFromQR(qr)
+	r.RFromQR(qr)
+	// make the decomposition unique so that the result is uniformly distributed
+	orthMx := mat64.NewDense(m, n, nil)
+	for j := 0; j < n; j++ {
+		sign := 1.0
+		if r.At(j, j) < 0 {
+			sign = -1.0
+		}
+		for i := 0; i < m; i++ {
+			orthMx.Set(i, j, sign*q.At(i, j))
+		}
+	}
+	if rows < cols {
+		return mat64.DenseCopyOf(orthMx.T())
+	}
+	return orthMx
+}
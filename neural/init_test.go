@@ -0,0 +1,93 @@
+package neural
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+func TestInitWeights(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(1))
+	out, in := 200, 300
+	for _, tc := range []struct {
+		kind  string
+		limit float64
+		std   float64
+	}{
+		{"xavieruniform", math.Sqrt(6.0 / 500), math.Sqrt(2.0 / 500)},
+		{"xaviernormal", 0, math.Sqrt(2.0 / 500)},
+		{"heuniform", math.Sqrt(6.0 / 300), math.Sqrt(2.0 / 300)},
+		{"henormal", 0, math.Sqrt(2.0 / 300)},
+		{"lecun", 0, math.Sqrt(1.0 / 300)},
+		{"orthogonal", 0, math.Sqrt(1.0 / 300)},
+	} {
+		weightsMx, err := initWeights(&config.InitConfig{Kind: tc.kind, Bias: 0.1}, out, in, rng)
+		assert.NoError(err)
+		r, c := weightsMx.Dims()
+		assert.Equal(out, r)
+		assert.Equal(in+1, c)
+		// bias weights are initialized separately
+		for i := 0; i < out; i++ {
+			assert.Equal(0.1, weightsMx.At(i, 0))
+		}
+		// weights have zero mean and expected std deviation
+		weights := weightsMx.View(0, 1, out, in)
+		mean := mat64.Sum(weights) / float64(out*in)
+		sqrMx := new(mat64.Dense)
+		sqrMx.MulElem(weights, weights)
+		std := math.Sqrt(mat64.Sum(sqrMx)/float64(out*in) - mean*mean)
+		assert.InDelta(0.0, mean, 0.05*tc.std, tc.kind)
+		assert.InDelta(tc.std, std, 0.05*tc.std, tc.kind)
+		if tc.limit > 0 {
+			assert.True(mat64.Max(weights) <= tc.limit, tc.kind)
+			assert.True(mat64.Min(weights) >= -tc.limit, tc.kind)
+		}
+	}
+	// orthogonal weights have orthonormal rows or columns
+	for _, dims := range [][2]int{{5, 8}, {8, 5}} {
+		weightsMx, err := initWeights(&config.InitConfig{Kind: "orthogonal"}, dims[0], dims[1], rng)
+		assert.NoError(err)
+		weights := weightsMx.View(0, 1, dims[0], dims[1])
+		prod := new(mat64.Dense)
+		if dims[0] < dims[1] {
+			prod.Mul(weights, weights.T())
+		} else {
+			prod.Mul(weights.T(), weights)
+		}
+		n, _ := prod.Dims()
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				expected := 0.0
+				if i == j {
+					expected = 1.0
+				}
+				assert.InDelta(expected, prod.At(i, j), 1e-12)
+			}
+		}
+	}
+	// constant weights
+	weightsMx, err := initWeights(&config.InitConfig{Kind: "constant", Value: 0.5, Bias: -1.0}, 3, 2, rng)
+	assert.NoError(err)
+	assert.True(mat64.Equal(mat64.NewDense(3, 3, []float64{-1, 0.5, 0.5, -1, 0.5, 0.5, -1, 0.5, 0.5}), weightsMx))
+	// unsupported scheme
+	_, err = initWeights(&config.InitConfig{Kind: "foo"}, 3, 2, rng)
+	assert.Error(err)
+	// layer weights are initialized per layer configuration
+	c := &config.LayerConfig{
+		Kind:   "hidden",
+		Size:   3,
+		NeurFn: &config.NeuronConfig{Activation: "relu"},
+		Init:   &config.InitConfig{Kind: "constant", Value: 0.5, Bias: -1.0},
+	}
+	layer, err := NewLayer(c, 2)
+	assert.NoError(err)
+	assert.True(mat64.Equal(weightsMx, layer.Weights()))
+	c.Init.Kind = "foo"
+	_, err = NewLayer(c, 2)
+	assert.Error(err)
+}
@@ -2,6 +2,8 @@ package neural
 
 import (
 	"fmt"
+	"math/rand"
+	"time"
 
 	"github.com/gonum/matrix/mat64"
 	"github.com/vstoianovici/nngoclassify/pkg/config"
@@ -21,23 +23,72 @@ const (
 // ActivFunc defines a neuron activation function
 type ActivFunc func(int, int, float64) float64
 
+// activation provides activation function and its derivation for given activation parameter
+type activation struct {
+	// act creates activation function
+	act func(float64) func(int, int, float64) float64
+	// grad creates derivation of activation function. It is nil if the activation
+	// can't be differentiated element by element
+	grad func(float64) func(int, int, float64) float64
+	// param is default activation parameter. Activations without parameter have zero param
+	param float64
+}
+
+// fixed wraps activation function without parameter
+func fixed(f func(int, int, float64) float64) func(float64) func(int, int, float64) float64 {
+	return func(float64) func(int, int, float64) float64 {
+		return f
+	}
+}
+
 // activations maps activation function names to their actual implementations
-var activations = map[string]map[string]ActivFunc{
+var activations = map[string]activation{
 	"sigmoid": {
-		"act":  matrix.SigmoidMx,
-		"grad": matrix.SigmoidGradMx,
+		act:  fixed(matrix.SigmoidMx),
+		grad: fixed(matrix.SigmoidGradMx),
 	},
+	// softmax normalizes exponentials of all layer outputs so it's only supported by OUTPUT layer
 	"softmax": {
-		"act":  matrix.ExpMx,
-		"grad": matrix.SigmoidGradMx,
+		act: fixed(matrix.ExpMx),
 	},
 	"tanh": {
-		"act":  matrix.TanhMx,
-		"grad": matrix.TanhGradMx,
+		act:  fixed(matrix.TanhMx),
+		grad: fixed(matrix.TanhGradMx),
 	},
 	"relu": {
-		"act":  matrix.ReluMx,
-		"grad": matrix.ReluGradMx,
+		act:  fixed(matrix.ReluMx),
+		grad: fixed(matrix.ReluGradMx),
+	},
+	"leakyrelu": {
+		act:   matrix.LeakyReluMx,
+		grad:  matrix.LeakyReluGradMx,
+		param: 0.01,
+	},
+	"elu": {
+		act:   matrix.EluMx,
+		grad:  matrix.EluGradMx,
+		param: 1.0,
+	},
+	"selu": {
+		act:  fixed(matrix.SeluMx),
+		grad: fixed(matrix.SeluGradMx),
+	},
+	"softplus": {
+		act:  fixed(matrix.SoftplusMx),
+		grad: fixed(matrix.SoftplusGradMx),
+	},
+	"gelu": {
+		act:  fixed(matrix.GeluMx),
+		grad: fixed(matrix.GeluGradMx),
+	},
+	"swish": {
+		act:   matrix.SwishMx,
+		grad:  matrix.SwishGradMx,
+		param: 1.0,
+	},
+	"identity": {
+		act:  fixed(matrix.IdentityMx),
+		grad: fixed(matrix.IdentityGradMx),
 	},
 }
 
@@ -76,18 +127,53 @@ type Layer struct {
 	weights *mat64.Dense
 	// deltas matrix holds output deltas used for backprop
 	deltas *mat64.Dense
+	// velocity matrix holds momentum of weights updates used by mini-batch training
+	velocity *mat64.Dense
+	// moment matrix holds first moment estimate of weights gradient used by adaptive optimizers
+	moment *mat64.Dense
+	// variance matrix holds second moment estimate of weights gradient used by adaptive optimizers
+	variance *mat64.Dense
+	// steps counts optimizer updates of layer weights
+	steps int
 	// act is neuron activation function
 	act ActivFunc
 	// actGrad is derivation of neuron activation function
 	actGrad ActivFunc
 	// meta contains layer metadata: currently only info about OUT ActFn
 	meta string
+	// dropout is the probability of dropping neuron output in TRAINING mode
+	dropout float64
+	// mode is either TRAINING or INFERENCE
+	mode Mode
+	// norm holds batch normalization shift and scale of layer neurons. It is nil if the layer
+	// activation inputs are not normalized
+	norm *Layer
+	// stats holds running mean and variance of layer activation inputs used by batch normalization
+	stats *mat64.Dense
+	// isNorm is true if the layer only holds batch normalization parameters of another layer.
+	// Such parameters are neither regularized nor decayed
+	isNorm bool
+	// spatial holds configuration of conv, pooling and flatten layers which process images.
+	// It is nil for fully connected layers
+	spatial *spatial
+	// rng generates random weights and dropout masks
+	rng *rand.Rand
 }
 
 // NewLayer creates a new neural network layer and returns it.
-// Layer weights are initialized to uniformly distributed random values (-1,1)
+// Layer weights are initialized by the scheme configured in the Init field of the layer config and
+// bias weights are set to its Bias value. If Init is nil, all the weights including bias weights are
+// initialized by the default uniform scheme i.e. to random values uniformly distributed
+// in (-sqrt(6/(out+in+1)), sqrt(6/(out+in+1))) where out and in are the numbers of neurons and inputs.
+// Random values are generated by a randomly seeded generator. Layers of networks created
+// by NewNetwork are generated by the seeded generator of the network instead.
 // NewLayer fails with error if the neural network supplied as a parameter does not exist.
 func NewLayer(c *config.LayerConfig, layerIn int) (*Layer, error) {
+	return newLayer(c, layerIn, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+// newLayer creates a new neural network layer using the supplied random generator
+func newLayer(c *config.LayerConfig, layerIn int, rng *rand.Rand) (*Layer, error) {
 	// layer in must be positive integer
 	if layerIn <= 0 {
 		return nil, fmt.Errorf("Layer input must be positive integer: %d\n", layerIn)
@@ -100,32 +186,34 @@ func NewLayer(c *config.LayerConfig, layerIn int) (*Layer, error) {
 	if _, ok := layerKind[c.Kind]; !ok {
 		return nil, fmt.Errorf("Invalid layer kind requested: %s", c.Kind)
 	}
-	layer := &Layer{}
-	layer.id = helpers.PseudoRandString(10)
+	// conv, pooling and flatten layers are created from the shape of their input images
+	if c.Type != "" && c.Type != "dense" {
+		return nil, fmt.Errorf("Layer type %s requires input image shape\n", c.Type)
+	}
+	layer := &Layer{rng: rng}
+	layer.id = helpers.PseudoRandString(10, rng)
 	layer.kind = layerKind[c.Kind]
 	// INPUT layer has neither weights matrix nor activation funcs
 	if layer.kind != INPUT {
-		// Set activation function
-		activFunc, ok := activations[c.NeurFn.Activation]
-		if !ok {
-			return nil, fmt.Errorf("Unsupported activation function: %s\n",
-				c.NeurFn.Activation)
+		if err := layer.setActivation(c.NeurFn); err != nil {
+			return nil, err
 		}
-		// set activation functions
-		layer.act = activFunc["act"]
-		// if tanh - needs to be rescaled if used in OUTPUT layer
-		if c.NeurFn.Activation == "tanh" {
-			if layer.kind == OUTPUT {
-				layer.act = matrix.TanhOutMx
-			}
+		// only HIDDEN layer outputs can be dropped
+		if c.Dropout < 0 || c.Dropout >= 1 || (c.Dropout > 0 && layer.kind != HIDDEN) {
+			return nil, fmt.Errorf("Incorrect dropout rate of %s layer: %f\n", layer.kind, c.Dropout)
 		}
-
-		layer.actGrad = activFunc["grad"]
-		layer.meta = c.NeurFn.Activation
+		layer.dropout = c.Dropout
 		layerOut := c.Size
-		// initialize weights to random values
+		// only HIDDEN layer activation inputs can be normalized
+		if c.Batchnorm {
+			if layer.kind != HIDDEN {
+				return nil, fmt.Errorf("Batch normalization not supported by %s layer\n", layer.kind)
+			}
+			layer.norm, layer.stats = newNorm(layer.kind, layerOut)
+		}
+		// initialize weights per the configured scheme
 		var err error
-		layer.weights, err = matrix.MakeRandMx(layerOut, layerIn+1, 0.0, 1.0)
+		layer.weights, err = initWeights(c.Init, layerOut, layerIn, rng)
 		if err != nil {
 			return nil, err
 		}
@@ -135,6 +223,44 @@ func NewLayer(c *config.LayerConfig, layerIn int) (*Layer, error) {
 	return layer, nil
 }
 
+// setActivation sets activation function of the layer and its derivation per neuron configuration.
+// It fails with error if the activation is not supported or its parameter is incorrect.
+func (l *Layer) setActivation(n *config.NeuronConfig) error {
+	activFunc, ok := activations[n.Activation]
+	if !ok {
+		return fmt.Errorf("Unsupported activation function: %s\n",
+			n.Activation)
+	}
+	// only some activation functions are parametrized
+	param := activFunc.param
+	if n.Param != 0 {
+		if param == 0 || n.Param < 0 {
+			return fmt.Errorf("Incorrect parameter of %s activation: %f\n",
+				n.Activation, n.Param)
+		}
+		param = n.Param
+	}
+	// HIDDEN layer activation must be differentiable for backpropagation
+	if activFunc.grad == nil && l.kind == HIDDEN {
+		return fmt.Errorf("Activation function %s not supported by %s layer\n",
+			n.Activation, l.kind)
+	}
+	// set activation functions
+	l.act = activFunc.act(param)
+	// if tanh - needs to be rescaled if used in OUTPUT layer
+	if n.Activation == "tanh" {
+		if l.kind == OUTPUT {
+			l.act = matrix.TanhOutMx
+		}
+	}
+
+	if activFunc.grad != nil {
+		l.actGrad = activFunc.grad(param)
+	}
+	l.meta = n.Activation
+	return nil
+}
+
 // ID returns layer id
 func (l Layer) ID() string {
 	return l.id
@@ -145,7 +271,7 @@ func (l Layer) Kind() LayerKind {
 	return l.kind
 }
 
-// Weights returns layer's eights matrix
+// Weights returns layer's eights matrix. Pooling and flatten layers have no weights and return nil.
 func (l *Layer) Weights() *mat64.Dense {
 	return l.weights
 }
@@ -155,8 +281,8 @@ func (l *Layer) Weights() *mat64.Dense {
 // than the existing layer weights or if the passed in weights matrix is nil
 // or if the layer is an INPUT layer: INPUT layer has no weights matrix.
 func (l *Layer) SetWeights(w *mat64.Dense) error {
-	// INPUT layer has no weights
-	if l.kind == INPUT {
+	// INPUT, pooling and flatten layers have no weights
+	if l.kind == INPUT || l.weights == nil {
 		return fmt.Errorf("Can't set weights matrix of %s layer\n", l.kind)
 	}
 	// we can't set weights to nil
@@ -174,6 +300,9 @@ func (l *Layer) SetWeights(w *mat64.Dense) error {
 	// We must re-allocate deltas too
 	deltas := mat64.NewDense(wr, wc, nil)
 	l.deltas = deltas
+	// optimizer state of the old weights no longer applies
+	l.velocity, l.moment, l.variance = nil, nil, nil
+	l.steps = 0
 	return nil
 }
 
@@ -184,6 +313,16 @@ func (l *Layer) Deltas() *mat64.Dense {
 	return l.deltas
 }
 
+// optimState returns optimizer state matrix stored in the layer.
+// The state matrix has the same dimensions as weights and it is allocated to zero values on first use.
+func (l *Layer) optimState(state **mat64.Dense) *mat64.Dense {
+	if *state == nil {
+		r, c := l.weights.Dims()
+		*state = mat64.NewDense(r, c, nil)
+	}
+	return *state
+}
+
 // FwdOut calculates forward output of the network layer for given input.
 // If the layer is an INPUT layer, it returns the matrix supplied as an argument.
 func (l *Layer) FwdOut(inputMx mat64.Matrix) (mat64.Matrix, error) {
@@ -195,8 +334,70 @@ func (l *Layer) FwdOut(inputMx mat64.Matrix) (mat64.Matrix, error) {
 	if l.kind == INPUT {
 		return inputMx, nil
 	}
+	_, out, _, err := l.activate(inputMx)
+	if err != nil {
+		return nil, err
+	}
+	// randomly drop neuron outputs in TRAINING mode
+	if mask := l.dropMask(out.Dims()); mask != nil {
+		out.MulElem(out, mask)
+	}
+	return out, nil
+}
+
+// Dropout returns the probability of dropping neuron output in TRAINING mode
+func (l Layer) Dropout() float64 {
+	return l.dropout
+}
+
+// Batchnorm returns true if the layer activation inputs are batch normalized
+func (l Layer) Batchnorm() bool {
+	return l.norm != nil
+}
+
+// dropMask returns random dropout mask for layer output of the given dimensions.
+// Kept outputs are scaled by 1/(1-dropout) so that no scaling is needed in INFERENCE mode.
+// It returns nil if no outputs are dropped.
+func (l Layer) dropMask(rows, cols int) *mat64.Dense {
+	if l.mode != TRAINING || l.dropout == 0 {
+		return nil
+	}
+	mask := mat64.NewDense(rows, cols, nil)
+	scale := 1 / (1 - l.dropout)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if l.rng.Float64() >= l.dropout {
+				mask.Set(i, j, scale)
+			}
+		}
+	}
+	return mask
+}
+
+// activate calculates activation function inputs and outputs of the layer for given input.
+// Activation function inputs are used to calculate activation gradient in backpropagation.
+// If the layer is batch normalized, activation function inputs are normalized and the results
+// of the normalization needed by backpropagation are returned too.
+func (l *Layer) activate(inputMx mat64.Matrix) (*mat64.Dense, *mat64.Dense, *normCache, error) {
+	if l.spatial != nil {
+		actIn, out, err := l.spatialActivate(inputMx)
+		return actIn, out, nil, err
+	}
+	actIn, err := l.linear(inputMx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var nc *normCache
+	if l.norm != nil {
+		actIn, nc = l.normalize(actIn, nil, nil)
+	}
+	return actIn, l.output(actIn), nc, nil
+}
+
+// linear calculates activation function inputs of the layer for given input before they are normalized
+func (l *Layer) linear(inputMx mat64.Matrix) (*mat64.Dense, error) {
 	// input column dimensions + bias must match the weights column dimensions
-	inRows, inCols := inputMx.Dims()
+	_, inCols := inputMx.Dims()
 	_, wCols := l.weights.Dims()
 	if inCols+1 != wCols {
 		return nil, fmt.Errorf("Dimension mismatch. Weight: %d, Input: %d\n", wCols, inCols)
@@ -204,19 +405,54 @@ func (l *Layer) FwdOut(inputMx mat64.Matrix) (mat64.Matrix, error) {
 	// add bias to input
 	biasInMx := matrix.AddBias(inputMx)
 	// calculate activation function inputs
+	actIn := new(mat64.Dense)
+	actIn.Mul(biasInMx, l.weights.T())
+	return actIn, nil
+}
+
+// output activates layer neurons given their activation function inputs
+func (l *Layer) output(actIn *mat64.Dense) *mat64.Dense {
 	out := new(mat64.Dense)
-	out.Mul(biasInMx, l.weights.T())
-	// activate layer neurons
-	out.Apply(l.act, out)
+	out.Apply(l.act, actIn)
 	if l.meta == "softmax" {
+		rows, _ := out.Dims()
 		rowSums := matrix.RowSums(out)
-		for i := 0; i < inRows; i++ {
+		for i := 0; i < rows; i++ {
 			rowVec := out.RowView(i)
 			rowVec.ScaleVec(1/rowSums[i], rowVec)
 			out.SetRow(i, rowVec.RawVector().Data)
 		}
 	}
-	return out, nil
+	return out
+}
+
+// backward accumulates deltas of layer weights into deltas matrix given the layer input and errors
+// of layer activation inputs. Rows of both matrices hold samples. If inErr is true it returns
+// errors of the layer input, otherwise it returns nil.
+func (l *Layer) backward(inMx, errMx mat64.Matrix, deltas *mat64.Dense, inErr bool) *mat64.Dense {
+	if l.spatial != nil {
+		return l.spatial.backward(l.weights, inMx, errMx, deltas, inErr)
+	}
+	dMx := new(mat64.Dense)
+	dMx.Mul(errMx.T(), matrix.AddBias(inMx))
+	deltas.Add(deltas, dMx)
+	if !inErr {
+		return nil
+	}
+	// layer error not accounting for bias
+	r, c := l.weights.Dims()
+	layerErr := new(mat64.Dense)
+	layerErr.Mul(errMx, l.weights.View(0, 1, r, c-1))
+	return layerErr
+}
+
+// size returns number of layer outputs of a single sample
+func (l *Layer) size() int {
+	if l.spatial != nil {
+		return l.spatial.out.size()
+	}
+	size, _ := l.weights.Dims()
+	return size
 }
 
 // ActFn returns layer activation function
@@ -224,7 +460,8 @@ func (l Layer) ActFn() func(int, int, float64) float64 {
 	return l.act
 }
 
-// ActGrad returns layer gradient activation function
+// ActGrad returns layer gradient activation function. It is nil if the layer activation
+// can't be differentiated element by element, e.g. softmax
 func (l Layer) ActGrad() func(int, int, float64) float64 {
 	return l.actGrad
 }
@@ -62,6 +62,38 @@ func TestNewLayer(t *testing.T) {
 	tstLayer, err = NewLayer(c, 10)
 	assert.NotNil(tstLayer)
 	assert.NoError(err)
+	// softmax is only supported by output layer
+	c.NeurFn.Activation = "softmax"
+	tstLayer, err = NewLayer(c, 10)
+	assert.Nil(tstLayer)
+	assert.Error(err)
+	c.Kind = "output"
+	tstLayer, err = NewLayer(c, 10)
+	assert.NotNil(tstLayer)
+	assert.NoError(err)
+	c.Kind = "hidden"
+	// activation parameter
+	c.NeurFn.Activation = "leakyrelu"
+	c.NeurFn.Param = 0.2
+	tstLayer, err = NewLayer(c, 10)
+	assert.NoError(err)
+	assert.Equal(-0.2, tstLayer.ActFn()(0, 0, -1.0))
+	assert.Equal(0.2, tstLayer.ActGrad()(0, 0, -1.0))
+	c.NeurFn.Param = 0
+	tstLayer, err = NewLayer(c, 10)
+	assert.NoError(err)
+	assert.Equal(-0.01, tstLayer.ActFn()(0, 0, -1.0))
+	c.NeurFn.Param = -0.2
+	tstLayer, err = NewLayer(c, 10)
+	assert.Nil(tstLayer)
+	assert.Error(err)
+	// sigmoid has no parameter
+	c.NeurFn.Activation = "sigmoid"
+	c.NeurFn.Param = 0.2
+	tstLayer, err = NewLayer(c, 10)
+	assert.Nil(tstLayer)
+	assert.Error(err)
+	c.NeurFn.Param = 0
 	// correct cases - let's change activation
 	c.NeurFn.Activation = "tanh"
 	lKinds := []string{"input", "hidden", "output"}
@@ -0,0 +1,189 @@
+package neural
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// thresholdSteps is the number of equal steps of range (0, 1) of decision thresholds tried by TuneThresholds
+const thresholdSteps = 20
+
+// MultiLabelMetrics contains metrics of MULTILABEL network tags
+type MultiLabelMetrics struct {
+	// HammingLoss is the fraction of wrongly tagged labels over all samples and labels
+	HammingLoss float64
+	// SubsetAccuracy is the fraction of samples whose labels are all tagged correctly
+	SubsetAccuracy float64
+	// F1 contains F1 score of every label. Labels which are neither present nor tagged have score 1
+	F1 []float64
+}
+
+// String implements Stringer interface for pretty printing
+func (m MultiLabelMetrics) String() string {
+	f1 := make([]string, len(m.F1))
+	for i, score := range m.F1 {
+		f1[i] = fmt.Sprintf("%f", score)
+	}
+	return fmt.Sprintf("Hamming loss: %f, Subset accuracy: %f, F1: [%s]",
+		m.HammingLoss, m.SubsetAccuracy, strings.Join(f1, " "))
+}
+
+// checkIndicators fails with error if labels matrix contains anything but binary label indicators
+func checkIndicators(labels mat64.Matrix) error {
+	rows, cols := labels.Dims()
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			if v := labels.At(i, j); v != 0 && v != 1 {
+				return fmt.Errorf("Incorrect label indicator: %f\n", v)
+			}
+		}
+	}
+	return nil
+}
+
+// Thresholds returns decision thresholds of MULTILABEL network outputs
+func (n Network) Thresholds() []float64 {
+	return n.thresholds
+}
+
+// SetThresholds sets decision thresholds of every MULTILABEL network output.
+// It fails with error if the network does not tag labels or if any of the thresholds
+// is not in range (0, 1) or their number does not match the number of network outputs.
+func (n *Network) SetThresholds(thresholds []float64) error {
+	if n.task != MULTILABEL {
+		return fmt.Errorf("Output thresholds not supported by %s task\n", n.task)
+	}
+	layers := n.Layers()
+	if outputs, _ := layers[len(layers)-1].Weights().Dims(); len(thresholds) != outputs {
+		return fmt.Errorf("Incorrect number of output thresholds: %d\n", len(thresholds))
+	}
+	for _, threshold := range thresholds {
+		if threshold <= 0 || threshold >= 1 {
+			return fmt.Errorf("Incorrect output threshold: %f\n", threshold)
+		}
+	}
+	n.thresholds = append([]float64(nil), thresholds...)
+	return nil
+}
+
+// Tag runs forward propagation of MULTILABEL network on the supplied input.
+// It returns a matrix of binary indicators of all labels per row: a label is tagged
+// if its network output reaches its decision threshold.
+// It returns error if the network does not tag labels or if the forward propagation fails.
+func (n *Network) Tag(inMx mat64.Matrix) (*mat64.Dense, error) {
+	if inMx == nil {
+		return nil, fmt.Errorf("Can't tag %v\n", inMx)
+	}
+	if n.task != MULTILABEL {
+		return nil, fmt.Errorf("Tagging not supported by %s task\n", n.task)
+	}
+	outMx, err := n.infer(inMx)
+	if err != nil {
+		return nil, err
+	}
+	outMx.Apply(func(i, j int, v float64) float64 {
+		if v >= n.thresholds[j] {
+			return 1.0
+		}
+		return 0.0
+	}, outMx)
+	return outMx, nil
+}
+
+// EvaluateLabels runs MULTILABEL network on the supplied data set and compares its tags
+// with the label indicators. It returns multilabel metrics of the tags or error.
+func (n *Network) EvaluateLabels(inMx *mat64.Dense, labels mat64.Matrix) (*MultiLabelMetrics, error) {
+	outMx, err := n.evalOutputs(inMx, labels)
+	if err != nil {
+		return nil, err
+	}
+	rows, cols := outMx.Dims()
+	m := &MultiLabelMetrics{F1: make([]float64, cols)}
+	wrong, exact := 0, 0
+	for i := 0; i < rows; i++ {
+		rowWrong := 0
+		for j := 0; j < cols; j++ {
+			tagged, present := outMx.At(i, j) >= n.thresholds[j], labels.At(i, j) == 1
+			if tagged != present {
+				rowWrong++
+			}
+		}
+		if rowWrong == 0 {
+			exact++
+		}
+		wrong += rowWrong
+	}
+	for j := 0; j < cols; j++ {
+		m.F1[j] = f1Score(outMx, labels, j, n.thresholds[j])
+	}
+	m.HammingLoss = float64(wrong) / float64(rows*cols)
+	m.SubsetAccuracy = float64(exact) / float64(rows)
+	return m, nil
+}
+
+// TuneThresholds sets decision threshold of every MULTILABEL network output to the one
+// which maximizes F1 score of its label on the supplied data set. Thresholds are picked
+// from a grid of thresholdSteps steps; the lowest of equally good thresholds is picked.
+// It returns the tuned thresholds or error.
+func (n *Network) TuneThresholds(inMx *mat64.Dense, labels mat64.Matrix) ([]float64, error) {
+	outMx, err := n.evalOutputs(inMx, labels)
+	if err != nil {
+		return nil, err
+	}
+	_, cols := outMx.Dims()
+	thresholds := make([]float64, cols)
+	for j := range thresholds {
+		best := -1.0
+		for k := 1; k < thresholdSteps; k++ {
+			threshold := float64(k) / thresholdSteps
+			if f1 := f1Score(outMx, labels, j, threshold); f1 > best {
+				best, thresholds[j] = f1, threshold
+			}
+		}
+	}
+	return thresholds, n.SetThresholds(thresholds)
+}
+
+// evalOutputs validates the data set evaluated by MULTILABEL network and returns the network outputs
+func (n *Network) evalOutputs(inMx *mat64.Dense, labels mat64.Matrix) (*mat64.Dense, error) {
+	// data set can't be nil
+	if inMx == nil || labels == nil {
+		return nil, fmt.Errorf("Cant evaluate data set. In: %v, Out: %v\n", inMx, labels)
+	}
+	if n.task != MULTILABEL {
+		return nil, fmt.Errorf("Multilabel evaluation not supported by %s task\n", n.task)
+	}
+	outMx, err := n.infer(inMx)
+	if err != nil {
+		return nil, err
+	}
+	rows, cols := outMx.Dims()
+	if lRows, lCols := labels.Dims(); lRows != rows || lCols != cols {
+		return nil, fmt.Errorf("Dimension mismatch. Labels: %dx%d, Outputs: %dx%d\n", lRows, lCols, rows, cols)
+	}
+	return outMx, checkIndicators(labels)
+}
+
+// f1Score returns F1 score of label j given the network outputs thresholded at threshold.
+// It returns 1 if the label is neither present nor tagged.
+func f1Score(outMx *mat64.Dense, labels mat64.Matrix, j int, threshold float64) float64 {
+	rows, _ := outMx.Dims()
+	var tp, fp, fn float64
+	for i := 0; i < rows; i++ {
+		tagged, present := outMx.At(i, j) >= threshold, labels.At(i, j) == 1
+		switch {
+		case tagged && present:
+			tp++
+		case tagged:
+			fp++
+		case present:
+			fn++
+		}
+	}
+	if tp+fp+fn == 0 {
+		return 1.0
+	}
+	return 2 * tp / (2*tp + fp + fn)
+}
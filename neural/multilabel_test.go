@@ -0,0 +1,213 @@
+package neural
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// multiLabelConfig returns configuration of a small MULTILABEL network with 3 labels
+func multiLabelConfig(output string, thresholds []float64) *config.NetConfig {
+	return &config.NetConfig{
+		Kind:       "feedfwd",
+		Task:       "multilabel",
+		Seed:       5,
+		Thresholds: thresholds,
+		Arch: &config.NetArch{
+			Input: &config.LayerConfig{Kind: "input", Size: 4},
+			Hidden: []*config.LayerConfig{
+				{Kind: "hidden", Size: 10, NeurFn: &config.NeuronConfig{Activation: "tanh"}},
+			},
+			Output: &config.LayerConfig{Kind: "output", Size: 3, NeurFn: &config.NeuronConfig{Activation: output}},
+		},
+	}
+}
+
+// multiLabelData returns random inputs generated by seeded generator and their label indicators
+func multiLabelData(samples int, seed int64) (*mat64.Dense, *mat64.Dense) {
+	rng := rand.New(rand.NewSource(seed))
+	in := mat64.NewDense(samples, 4, nil)
+	labels := mat64.NewDense(samples, 3, nil)
+	indicator := func(b bool) float64 {
+		if b {
+			return 1.0
+		}
+		return 0.0
+	}
+	for i := 0; i < samples; i++ {
+		x := []float64{2*rng.Float64() - 1, 2*rng.Float64() - 1, 2*rng.Float64() - 1, 2*rng.Float64() - 1}
+		in.SetRow(i, x)
+		labels.SetRow(i, []float64{indicator(x[0] > 0), indicator(x[1]+x[2] > 0.5), indicator(x[0]*x[3] > 0.2)})
+	}
+	return in, labels
+}
+
+func TestF1Score(t *testing.T) {
+	assert := assert.New(t)
+	outMx := mat64.NewDense(4, 2, []float64{
+		0.9, 0.1,
+		0.6, 0.2,
+		0.3, 0.3,
+		0.2, 0.4,
+	})
+	labels := mat64.NewDense(4, 2, []float64{
+		1, 0,
+		0, 0,
+		1, 0,
+		0, 0,
+	})
+	// 1 true positive, 1 false positive and 1 false negative
+	assert.InDelta(0.5, f1Score(outMx, labels, 0, 0.5), 1e-12)
+	// all positives tagged
+	assert.InDelta(0.8, f1Score(outMx, labels, 0, 0.25), 1e-12)
+	// label which is neither present nor tagged
+	assert.Equal(1.0, f1Score(outMx, labels, 1, 0.5))
+	assert.Equal(0.0, f1Score(outMx, labels, 1, 0.1))
+}
+
+func TestMultiLabelNetwork(t *testing.T) {
+	assert := assert.New(t)
+	n, err := NewNetwork(multiLabelConfig("sigmoid", nil))
+	assert.NoError(err)
+	assert.Equal(MULTILABEL, n.Task())
+	assert.Equal([]float64{0.5, 0.5, 0.5}, n.Thresholds())
+	in, labels := multiLabelData(20, 1)
+	// gradient of binary cross entropy matches the numerical one
+	checks, err := n.CheckGradient(&config.TrainConfig{Cost: "xentropy", Lambda: 0.5}, in, labels, 10, 20)
+	assert.NoError(err)
+	assert.Len(checks, 2)
+	for _, check := range checks {
+		assert.True(check.RelError < 1e-6, check.String())
+	}
+	// tags are compared with label indicators
+	tagMx, err := n.Tag(in)
+	assert.NoError(err)
+	metrics, err := n.EvaluateLabels(in, tagMx)
+	assert.NoError(err)
+	assert.Equal(MultiLabelMetrics{HammingLoss: 0, SubsetAccuracy: 1, F1: []float64{1, 1, 1}}, *metrics)
+	tagMx.Set(0, 1, 1-tagMx.At(0, 1))
+	tagMx.Set(1, 1, 1-tagMx.At(1, 1))
+	metrics, err = n.EvaluateLabels(in, tagMx)
+	assert.NoError(err)
+	assert.InDelta(2.0/60.0, metrics.HammingLoss, 1e-12)
+	assert.InDelta(18.0/20.0, metrics.SubsetAccuracy, 1e-12)
+	assert.Equal(1.0, metrics.F1[0])
+	assert.True(metrics.F1[1] < 1)
+	// labels must be binary indicators of every output
+	tagMx.Set(0, 0, 0.5)
+	_, err = n.EvaluateLabels(in, tagMx)
+	assert.Error(err)
+	_, err = n.EvaluateLabels(in, mat64.NewDense(20, 2, nil))
+	assert.Error(err)
+	_, err = n.getCost(&config.TrainConfig{Cost: "xentropy"}, nil, in, tagMx)
+	assert.Error(err)
+	// decision thresholds
+	assert.NoError(n.SetThresholds([]float64{0.2, 0.5, 0.8}))
+	assert.Equal([]float64{0.2, 0.5, 0.8}, n.Thresholds())
+	for _, thresholds := range [][]float64{{0.5}, {0.5, 0.5, 1.0}, {0, 0.5, 0.5}} {
+		assert.Error(n.SetThresholds(thresholds))
+	}
+	// neither classification nor prediction is supported
+	_, err = n.Classify(in)
+	assert.Error(err)
+	_, err = n.Predict(in)
+	assert.Error(err)
+	_, err = n.CheckGradient(&config.TrainConfig{Cost: "loglike"}, in, labels, 3, 10)
+	assert.Error(err)
+	// incorrect networks
+	for _, c := range []*config.NetConfig{
+		multiLabelConfig("softmax", nil),
+		multiLabelConfig("sigmoid", []float64{0.5, 0.5}),
+		multiLabelConfig("sigmoid", []float64{0.5, 0.5, 1.5}),
+	} {
+		_, err = NewNetwork(c)
+		assert.Error(err)
+	}
+	c := multiLabelConfig("sigmoid", []float64{0.5, 0.5, 0.5})
+	c.Task = "class"
+	_, err = NewNetwork(c)
+	assert.Error(err)
+	// classification network does not tag
+	c.Thresholds = nil
+	classNet, err := NewNetwork(c)
+	assert.NoError(err)
+	_, err = classNet.Tag(in)
+	assert.Error(err)
+	assert.Error(classNet.SetThresholds([]float64{0.5, 0.5, 0.5}))
+	_, err = classNet.EvaluateLabels(in, labels)
+	assert.Error(err)
+}
+
+func TestTrainMultiLabel(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	n, err := NewNetwork(multiLabelConfig("sigmoid", nil))
+	assert.NoError(err)
+	in, labels := multiLabelData(200, 1)
+	valIn, valLabels := multiLabelData(50, 2)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "xentropy",
+		Learningrate: 0.05,
+		Epochs:       30,
+		Optimize:     &config.OptimConfig{Method: "adam", Batchsize: 10},
+		// accuracy of multilabel network is its subset accuracy
+		EarlyStop: &config.EarlyStopConfig{Metric: "accuracy", Patience: 5},
+	}
+	before, err := n.EvaluateLabels(valIn, valLabels)
+	assert.NoError(err)
+	rec := &recorder{}
+	assert.NoError(n.Train(context.Background(), c, in, labels, valIn, valLabels, "", rec))
+	last := rec.metrics[len(rec.metrics)-1]
+	assert.True(last.Validated)
+	assert.NotNil(last.MultiLabel)
+	assert.Equal(100*last.MultiLabel.SubsetAccuracy, last.Accuracy)
+	after, err := n.EvaluateLabels(valIn, valLabels)
+	assert.NoError(err)
+	assert.True(after.HammingLoss < before.HammingLoss)
+	assert.True(after.SubsetAccuracy > 0.7, after.String())
+	// tuned thresholds don't decrease F1 score of any label
+	thresholds, err := n.TuneThresholds(valIn, valLabels)
+	assert.NoError(err)
+	assert.Equal(thresholds, n.Thresholds())
+	tuned, err := n.EvaluateLabels(valIn, valLabels)
+	assert.NoError(err)
+	for j := range thresholds {
+		assert.True(tuned.F1[j] >= after.F1[j])
+	}
+	// thresholds are tuned on validation data set at the end of training and saved with the network
+	assert.NoError(n.SetThresholds([]float64{0.5, 0.5, 0.5}))
+	c.Tune = true
+	c.Epochs = 1
+	assert.NoError(n.Train(context.Background(), c, in, labels, valIn, valLabels, "", nil))
+	tunedOut, err := n.TuneThresholds(valIn, valLabels)
+	assert.NoError(err)
+	assert.Equal(tunedOut, n.Thresholds())
+	loaded, err := NewNetwork(multiLabelConfig("sigmoid", nil))
+	assert.NoError(err)
+	assert.NoError(LoadFromFile(loaded))
+	assert.Equal(n.Thresholds(), loaded.Thresholds())
+	// tuning requires validation data set
+	assert.Error(n.Train(context.Background(), c, in, labels, nil, nil, "", nil))
+	c.Tune = false
+	// training configuration must suit the task
+	for _, bad := range []*config.TrainConfig{
+		{Kind: "sgd", Cost: "loglike", Optimize: c.Optimize},
+		{Kind: "sgd", Cost: "mse", Optimize: c.Optimize},
+		{Kind: "sgd", Cost: "xentropy", Smoothing: 0.1, Optimize: c.Optimize},
+		{Kind: "sgd", Cost: "xentropy", Classes: &config.ClassConfig{Weights: []float64{1, 2, 1}}, Optimize: c.Optimize},
+	} {
+		assert.Error(n.Train(context.Background(), bad, in, labels, valIn, valLabels, "", nil))
+	}
+	// only multilabel networks have thresholds to tune
+	classConf := multiLabelConfig("softmax", nil)
+	classConf.Task = "class"
+	classNet, err := NewNetwork(classConf)
+	assert.NoError(err)
+	c = &config.TrainConfig{Kind: "sgd", Cost: "loglike", Tune: true, Optimize: c.Optimize}
+	assert.Error(classNet.Train(context.Background(), c, in, mat64.NewVector(200, nil), valIn, mat64.NewVector(50, nil), "", nil))
+}
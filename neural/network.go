@@ -1,17 +1,23 @@
 package neural
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
 	"io"
+	"io/ioutil"
+	"math/rand"
+	"time"
 	//"path/filepath"
+	"github.com/gonum/floats"
 	"github.com/gonum/matrix/mat64"
 	"github.com/gonum/optimize"
 	"github.com/vstoianovici/nngoclassify/pkg/config"
 	"github.com/vstoianovici/nngoclassify/pkg/helpers"
 	"github.com/vstoianovici/nngoclassify/pkg/matrix"
 	"github.com/vstoianovici/nngoclassify/pkg/dataset"
+	"gopkg.in/yaml.v1"
 )
 
 const (
@@ -19,11 +25,6 @@ const (
 	FEEDFWD NetworkKind = iota + 1
 )
 
-// optim maps optimization algorithm names to their actual implementations
-var optim = map[string]optimize.Method{
-	"bfgs": &optimize.BFGS{},
-}
-
 // kindMap maps strings to NetworkKind
 var netKind = map[string]NetworkKind{
 	"feedfwd": FEEDFWD,
@@ -42,8 +43,64 @@ func (n NetworkKind) String() string {
 	}
 }
 
+const (
+	// CLASS task classifies data samples into output classes
+	CLASS Task = iota + 1
+	// PREDICT task predicts continuous target values of all network outputs
+	PREDICT
+	// MULTILABEL task tags data samples with any number of labels, one per network output
+	MULTILABEL
+)
+
+// netTask maps strings to Task. Networks classify data samples if no task is configured
+var netTask = map[string]Task{
+	"":           CLASS,
+	"class":      CLASS,
+	"predict":    PREDICT,
+	"multilabel": MULTILABEL,
+}
+
+// Task defines what the neural network is trained for
+type Task uint
+
+// String implements Stringer interface for pretty printing
+func (t Task) String() string {
+	switch t {
+	case CLASS:
+		return "CLASS"
+	case PREDICT:
+		return "PREDICT"
+	case MULTILABEL:
+		return "MULTILABEL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+const (
+	// INFERENCE mode uses the network for predictions
+	INFERENCE Mode = iota
+	// TRAINING mode enables training only behavior such as dropout
+	TRAINING
+)
+
+// Mode defines whether the network is being trained or used for inference
+type Mode uint
+
+// String implements Stringer interface for pretty printing
+func (m Mode) String() string {
+	switch m {
+	case INFERENCE:
+		return "INFERENCE"
+	case TRAINING:
+		return "TRAINING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 // network maps supported neural network types to their constructors
-var network = map[string]func(*config.NetArch) (*Network, error){
+var network = map[string]func(*config.NetArch, *rand.Rand) (*Network, error){
 	"feedfwd": createFeedFwdNetwork,
 }
 
@@ -52,10 +109,34 @@ type Network struct {
 	id     string
 	kind   NetworkKind
 	layers []*Layer
+	// task is CLASS, PREDICT or MULTILABEL
+	task Task
+	// thresholds contains decision thresholds of every output of MULTILABEL network
+	thresholds []float64
+	// epoch is the number of completed training epochs
+	epoch int
+	// step is the number of mini-batch training steps
+	step int
+	// rate is the learning rate used by the latest training step
+	rate float64
+	// mode is either TRAINING or INFERENCE
+	mode Mode
+	// seed is the seed of the random generator
+	seed int64
+	// rng generates random weights, shuffles training samples and generates dropout masks
+	rng *rand.Rand
+	// classWeights contains cost weights of all classes used by the current training.
+	// It is only set while Train, TrainStream or CheckGradient runs
+	classWeights []float64
 }
 
 // NewNetwork creates new Neural Network based on the passed in configuration parameters.
-// It fails with error if either the requested network type is not supported or
+// All the randomness of the network is generated by a random generator seeded by the configured
+// seed, so networks created and trained with the same configuration and seed are identical.
+// If no seed is configured, a random seed is picked.
+// Networks of PREDICT task must have identity OUTPUT layer activation and networks
+// of MULTILABEL task must have sigmoid OUTPUT layer activation.
+// It fails with error if either the requested network type or task is not supported or
 // if any of the neural network layers failed to be created.
 func NewNetwork(c *config.NetConfig) (*Network, error) {
 	// supplied configuration cant be nil
@@ -67,19 +148,56 @@ func NewNetwork(c *config.NetConfig) (*Network, error) {
 	if !ok {
 		return nil, fmt.Errorf("Unsupported neural network type: %s\n", c.Kind)
 	}
+	task, ok := netTask[c.Task]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported neural network task: %s\n", c.Task)
+	}
+	seed := c.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
 	// create new network and return it
-	return createNet(c.Arch)
+	net, err := createNet(c.Arch, rand.New(rand.NewSource(seed)))
+	if err != nil {
+		return nil, err
+	}
+	net.seed = seed
+	net.task = task
+	// regression costs assume linear network outputs and labels are tagged independently
+	layers := net.Layers()
+	out := layers[len(layers)-1]
+	if (task == PREDICT && out.meta != "identity") || (task == MULTILABEL && out.meta != "sigmoid") {
+		return nil, fmt.Errorf("Activation function %s not supported by %s task\n", out.meta, task)
+	}
+	if len(c.Thresholds) != 0 && task != MULTILABEL {
+		return nil, fmt.Errorf("Output thresholds not supported by %s task\n", task)
+	}
+	if task == MULTILABEL {
+		outputs, _ := out.Weights().Dims()
+		thresholds := c.Thresholds
+		if len(thresholds) == 0 {
+			thresholds = make([]float64, outputs)
+			for i := range thresholds {
+				thresholds[i] = 0.5
+			}
+		}
+		if err := net.SetThresholds(thresholds); err != nil {
+			return nil, err
+		}
+	}
+	return net, nil
 }
 
-// createFeedFwdNetwork creates feedforward neural network or fails with error
-func createFeedFwdNetwork(arch *config.NetArch) (*Network, error) {
+// createFeedFwdNetwork creates feedforward neural network using the supplied random generator
+// or fails with error
+func createFeedFwdNetwork(arch *config.NetArch, rng *rand.Rand) (*Network, error) {
 	// check if the supplied architecture is not nil
 	if arch == nil {
 		return nil, fmt.Errorf("Incorrect architecture supplied: %v\n", arch)
 	}
 	// create new network
-	net := &Network{}
-	net.id = helpers.PseudoRandString(10)
+	net := &Network{rng: rng}
+	net.id = helpers.PseudoRandString(10, rng)
 	net.kind = FEEDFWD
 	// INPUT layer can't be nil
 	if arch.Input == nil {
@@ -87,7 +205,7 @@ func createFeedFwdNetwork(arch *config.NetArch) (*Network, error) {
 	}
 	// Create INPUT layer
 	layerInSize := arch.Input.Size
-	inLayer, err := NewLayer(arch.Input, arch.Input.Size)
+	inLayer, err := newLayer(arch.Input, arch.Input.Size, rng)
 	if err != nil {
 		return nil, err
 	}
@@ -95,9 +213,30 @@ func createFeedFwdNetwork(arch *config.NetArch) (*Network, error) {
 	if err := net.AddLayer(inLayer); err != nil {
 		return nil, err
 	}
+	// inShape is shape of input images of the next layer. It is nil if the input is not images
+	var inShape *shape
+	if s := arch.Input.Shape; len(s) != 0 {
+		if len(s) != 3 || s[0] <= 0 || s[1] <= 0 || s[2] <= 0 || s[0]*s[1]*s[2] != layerInSize {
+			return nil, fmt.Errorf("Incorrect INPUT layer shape: %v\n", s)
+		}
+		inShape = &shape{h: s[0], w: s[1], c: s[2]}
+	}
 	// create HIDDEN layers
 	for _, layerConfig := range arch.Hidden {
-		layer, err := NewLayer(layerConfig, layerInSize)
+		var layer *Layer
+		if layerConfig.Type != "" && layerConfig.Type != "dense" {
+			// conv, pooling and flatten layers process images
+			if inShape == nil {
+				return nil, fmt.Errorf("Layer type %s requires input image shape\n", layerConfig.Type)
+			}
+			layer, inShape, err = newSpatialLayer(layerConfig, *inShape, rng)
+		} else {
+			if err := net.checkFlat(); err != nil {
+				return nil, err
+			}
+			inShape = nil
+			layer, err = newLayer(layerConfig, layerInSize, rng)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -106,14 +245,17 @@ func createFeedFwdNetwork(arch *config.NetArch) (*Network, error) {
 			return nil, err
 		}
 		// layerInSize is set to output of the previous layer
-		layerInSize = layerConfig.Size
+		layerInSize = layer.size()
 	}
 	// OUTPUT layer can't be nil
 	if arch.Output == nil {
 		return nil, fmt.Errorf("Invalid OUTPUT layer: %v\n", arch.Output)
 	}
+	if err := net.checkFlat(); err != nil {
+		return nil, err
+	}
 	// Create OUTPUT layer
-	outLayer, err := NewLayer(arch.Output, layerInSize)
+	outLayer, err := newLayer(arch.Output, layerInSize, rng)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +266,16 @@ func createFeedFwdNetwork(arch *config.NetArch) (*Network, error) {
 	return net, nil
 }
 
+// checkFlat fails with error if the last network layer is conv or pooling layer whose output images
+// must be flattened by flatten layer before being passed to fully connected layer
+func (n *Network) checkFlat() error {
+	last := n.layers[len(n.layers)-1]
+	if last.spatial != nil && last.spatial.kind != "flatten" {
+		return fmt.Errorf("Output of %s layer must be flattened\n", last.spatial.kind)
+	}
+	return nil
+}
+
 // AddLayer adds a neural layer to neural network or fails with error
 // AddLayer places restrictions on adding new layers to the network:
 // 1. INPUT layer  - there can only be one INPUT layer
@@ -131,6 +283,8 @@ func createFeedFwdNetwork(arch *config.NetArch) (*Network, error) {
 // 3. OUTPUT layer - there can only be one OUTPUT layer
 // AddLayer fails with error if either 1. or 3. are not satisfied
 func (n *Network) AddLayer(layer *Layer) error {
+	// layers follow the network mode and use its random generator
+	layer.mode, layer.rng = n.mode, n.rng
 	layerCount := len(n.layers)
 	// if no layer exists yet, just append
 	if layerCount == 0 {
@@ -180,11 +334,63 @@ func (n Network) Kind() NetworkKind {
 	return n.kind
 }
 
+// Task returns neural network task
+func (n Network) Task() Task {
+	return n.task
+}
+
+// Epoch returns the number of completed training epochs
+func (n Network) Epoch() int {
+	return n.epoch
+}
+
+// LearningRate returns the learning rate used by the latest mini-batch training step
+func (n Network) LearningRate() float64 {
+	return n.rate
+}
+
+// Seed returns the seed of the network random generator
+func (n Network) Seed() int64 {
+	return n.seed
+}
+
+// Mode returns network mode
+func (n Network) Mode() Mode {
+	return n.mode
+}
+
+// SetMode sets the mode of the network and all of its layers.
+// Networks are created in INFERENCE mode.
+func (n *Network) SetMode(m Mode) {
+	n.mode = m
+	for _, layer := range n.layers {
+		layer.mode = m
+	}
+}
+
 // Layers returns network layers in slice sorted from INPUT to OUTPUT layer
 func (n Network) Layers() []*Layer {
 	return n.layers
 }
 
+// params returns the layers which hold trainable network parameters in the order
+// they are rolled into weights and gradient slices: every layer but INPUT layer
+// followed by its batch normalization parameters if the layer is batch normalized.
+// Pooling and flatten layers have no parameters.
+func (n Network) params() []*Layer {
+	var params []*Layer
+	for _, layer := range n.layers[1:] {
+		if layer.weights == nil {
+			continue
+		}
+		params = append(params, layer)
+		if layer.norm != nil {
+			params = append(params, layer.norm)
+		}
+	}
+	return params
+}
+
 // ForwardProp performs forward propagation for a given input up to a specified network layer.
 // It recursively activates all layers in the network and returns the output in a matrix
 // It fails with error if requested end layer index is beyond all available layers or if
@@ -238,17 +444,32 @@ func (n *Network) BackProp(inMx, errMx mat64.Matrix, fromLayer int) error {
 	if fromLayer < 1 || fromLayer > len(layers)-1 {
 		return fmt.Errorf("Cant backpropagate beyond first layer: %d\n", len(layers))
 	}
+	// batch normalization is only backpropagated through whole batches during training
+	for _, layer := range layers {
+		if layer.Batchnorm() {
+			return fmt.Errorf("Can't backpropagate batch normalized layer\n")
+		}
+		if layer.spatial != nil {
+			return fmt.Errorf("Can't backpropagate %s layer\n", layer.spatial.kind)
+		}
+	}
+	// accumulate into layer deltas
+	deltas := make([]*mat64.Dense, len(layers))
+	for i := 1; i < len(layers); i++ {
+		deltas[i] = layers[i].Deltas()
+	}
 	// perform the actual back propagation till the first hidden layer
-	return n.doBackProp(inMx, errMx, fromLayer, 1)
+	return n.doBackProp(inMx, errMx, fromLayer, 1, deltas)
 }
 
-// doBackProp performs the actual backpropagation
-func (n *Network) doBackProp(inMx, errMx mat64.Matrix, from, to int) error {
+// doBackProp performs the actual backpropagation.
+// Deltas of every layer are accumulated into the matrix with the same index in deltas.
+func (n *Network) doBackProp(inMx, errMx mat64.Matrix, from, to int, deltas []*mat64.Dense) error {
 	// get all the layers
 	layers := n.Layers()
 	// pick deltas layer
 	layer := layers[from]
-	deltasMx := layer.Deltas()
+	deltasMx := deltas[from]
 	weightsMx := layer.Weights()
 	//forward propagate to previous layer
 	outMx, err := n.ForwardProp(inMx, from-1)
@@ -285,13 +506,39 @@ func (n *Network) doBackProp(inMx, errMx mat64.Matrix, from, to int) error {
 	gradMx.Mul(biasActInMx, weightsErrMx.T())
 	gradMx.Apply(weightsErrLayer.ActGrad(), gradMx)
 	gradMx.MulElem(layerErr.T(), gradMx)
-	return n.doBackProp(inMx, gradMx, from-1, to)
+	return n.doBackProp(inMx, gradMx, from-1, to, deltas)
 }
 
-// costMap maps name of cost to their actual implementations
-var trainCost = map[string]Cost{
-	"xentropy": CrossEntropy{},
-	"loglike":  LogLikelihood{}}
+// trainCost maps name of cost to functions which create their implementations
+// per training configuration with given class weights
+var trainCost = map[string]func(c *config.TrainConfig, weights []float64) Cost{
+	"xentropy": func(c *config.TrainConfig, weights []float64) Cost { return CrossEntropy{Weights: weights} },
+	"loglike":  func(c *config.TrainConfig, weights []float64) Cost { return LogLikelihood{Weights: weights} },
+	"mse":      func(c *config.TrainConfig, weights []float64) Cost { return MeanSquared{} },
+	"mae":      func(c *config.TrainConfig, weights []float64) Cost { return MeanAbsolute{} },
+	"huber": func(c *config.TrainConfig, weights []float64) Cost {
+		threshold := c.Huber
+		if threshold == 0 {
+			threshold = 1.0
+		}
+		return Huber{Threshold: threshold}
+	},
+}
+
+// costTasks maps name of cost to the network tasks it is used for
+var costTasks = map[string][]Task{
+	"xentropy": {CLASS, MULTILABEL},
+	"loglike":  {CLASS},
+	"mse":      {PREDICT},
+	"mae":      {PREDICT},
+	"huber":    {PREDICT},
+}
+
+// trainKind maps training kinds to functions which run a single training epoch
+var trainKind = map[string]func(*Network, context.Context, *config.TrainConfig, *mat64.Dense, mat64.Matrix, Callback) error{
+	"backprop": (*Network).trainBackprop,
+	"sgd":      (*Network).trainSGD,
+}
 
 // ValidateTrainConfig validates training configuration.
 // It returns error if any of the supplied configuration parameters are invalid.
@@ -300,13 +547,64 @@ func ValidateTrainConfig(c *config.TrainConfig) error {
 	if c == nil {
 		return fmt.Errorf("Incorrect configuration supplied: %v\n", c)
 	}
+	// check if the requested training kind is supported
+	if _, ok := trainKind[c.Kind]; !ok {
+		return fmt.Errorf("Unsupported training kind: %s\n", c.Kind)
+	}
 	// check if the requested training is supported
 	if _, ok := trainCost[c.Cost]; !ok {
 		return fmt.Errorf("Unsupported training cost: %s\n", c.Cost)
 	}
-	// Incorrect lambda supplied
-	if c.Lambda < 0 {
-		return fmt.Errorf("Incorrect regularizer supplied: %f\n", c.Lambda)
+	// validate regularization configuration
+	if err := validateRegularizer(c); err != nil {
+		return err
+	}
+	// Incorrect number of epochs supplied
+	if c.Epochs < 0 {
+		return fmt.Errorf("Incorrect number of epochs: %d\n", c.Epochs)
+	}
+	// Incorrect number of workers supplied
+	if c.Workers < 0 {
+		return fmt.Errorf("Incorrect number of workers: %d\n", c.Workers)
+	}
+	// label smoothing factor must be in range [0, 1)
+	if c.Smoothing < 0 || c.Smoothing >= 1 {
+		return fmt.Errorf("Incorrect label smoothing: %f\n", c.Smoothing)
+	}
+	// huber threshold can't be negative
+	if c.Huber < 0 {
+		return fmt.Errorf("Incorrect huber threshold: %f\n", c.Huber)
+	}
+	// optimization config can't be nil
+	if c.Optimize == nil {
+		return fmt.Errorf("Incorrect optimization configuration supplied: %v\n", c.Optimize)
+	}
+	// validate early stopping configuration
+	if err := validateEarlyStop(c.EarlyStop); err != nil {
+		return err
+	}
+	// validate gradient clipping configuration
+	if err := validateClip(c.Clip); err != nil {
+		return err
+	}
+	// validate imbalanced classes configuration
+	if err := validateClasses(c); err != nil {
+		return err
+	}
+	// validate data augmentation configuration
+	if err := validateAugment(c); err != nil {
+		return err
+	}
+	// mini-batch training uses first order optimizers
+	if c.Kind == "sgd" {
+		if err := validateMiniBatchConfig(c); err != nil {
+			return err
+		}
+		return validateSchedule(c)
+	}
+	// learning rate schedules are only used by mini-batch training
+	if c.Schedule != nil {
+		return fmt.Errorf("Learning rate schedule not supported by %s training\n", c.Kind)
 	}
 	// if the optimization method is not supported
 	if _, ok := optim[c.Optimize.Method]; !ok {
@@ -316,47 +614,282 @@ func ValidateTrainConfig(c *config.TrainConfig) error {
 	if c.Optimize.Iterations <= 0 {
 		return fmt.Errorf("Incorrect number of iterations: %d\n", c.Optimize.Iterations)
 	}
-	return nil
+	// validate method specific options
+	return validateOptimConfig(c.Optimize)
 }
 
 // Train trains feedforward neural network per configuration passed in as parameter.
+// It runs the configured number of training epochs (at least one) and saves the trained
+// network into trainingdata directory after every epoch. If validation data set is supplied
+// the network is validated after every epoch. Early stopping, if configured, stops
+// the training once the validation metric stops improving and restores the weights of the best epoch.
+// Training progress is reported to the supplied callback which can be nil.
+// Labels of CLASS network are stored in a single column. Labels of PREDICT network
+// contain the target values of every network output in separate columns.
+// When ctx is cancelled the training stops at the next iteration boundary, the network
+// trained so far is saved into trainingdata directory and ctx.Err() is returned.
 // It returns error if either the training configuration is invalid ot the training fails.
-func (n *Network) Train(c *config.TrainConfig, inMx *mat64.Dense, labelsVec *mat64.Vector, manifest string) error {
+func (n *Network) Train(ctx context.Context, c *config.TrainConfig, inMx *mat64.Dense, labels mat64.Matrix,
+	valInMx *mat64.Dense, valLabels mat64.Matrix, manifest string, cb Callback) error {
 	// validate the supplied configuration
 	if err := ValidateTrainConfig(c); err != nil {
 		return err
 	}
+	if err := n.validateTask(c); err != nil {
+		return err
+	}
 	// input matrix can't be nil
 	if inMx == nil {
 		return fmt.Errorf("Incorrect input supplied: %v\n", inMx)
 	}
 	// output labels can't be nil
-	if labelsVec == nil {
-		return fmt.Errorf("Incorrect lables supplied: %v\n", labelsVec)
+	if labels == nil {
+		return fmt.Errorf("Incorrect lables supplied: %v\n", labels)
+	}
+	// class weights are resolved once for the whole training
+	if err := n.setClassWeights(c.Classes, labels); err != nil {
+		return err
+	}
+	defer n.clearClassWeights()
+	// run the configured training kind epoch by epoch
+	trainEpoch := trainKind[c.Kind]
+	epoch := func(cb Callback) error {
+		return trainEpoch(n, ctx, c, inMx, labels, cb)
+	}
+	// training cost is calculated over the whole data set
+	cost := func() (float64, error) {
+		return n.getCost(c, nil, inMx, labels)
+	}
+	return n.train(ctx, c, epoch, cost, valInMx, valLabels, manifest, cb)
+}
+
+// validateTask validates that the training configuration can be used to train the network for its task.
+// PREDICT and MULTILABEL networks support neither classes nor label smoothing and only
+// decision thresholds of MULTILABEL networks can be tuned.
+func (n *Network) validateTask(c *config.TrainConfig) error {
+	supported := false
+	for _, task := range costTasks[c.Cost] {
+		supported = supported || task == n.task
+	}
+	if !supported {
+		return fmt.Errorf("Cost %s not supported by %s task\n", c.Cost, n.task)
+	}
+	if c.Tune && n.task != MULTILABEL {
+		return fmt.Errorf("Thresholds tuning not supported by %s task\n", n.task)
+	}
+	if n.task == CLASS {
+		return nil
+	}
+	if c.Smoothing != 0 || c.Classes != nil {
+		return fmt.Errorf("Label smoothing and classes not supported by %s task\n", n.task)
+	}
+	// accuracy of MULTILABEL network is its subset accuracy
+	if n.task == PREDICT && c.EarlyStop != nil && c.EarlyStop.Metric == "accuracy" {
+		return fmt.Errorf("Early stopping metric %s not supported by %s task\n", c.EarlyStop.Metric, n.task)
+	}
+	return nil
+}
+
+// train validates the configuration shared by all training data sources,
+// runs the training epochs and reports the progress to callback.
+// runEpoch runs a single training epoch and epochCost calculates the training cost after every epoch.
+func (n *Network) train(ctx context.Context, c *config.TrainConfig, runEpoch func(Callback) error,
+	epochCost func() (float64, error), valInMx *mat64.Dense, valLabels mat64.Matrix, manifest string, cb Callback) error {
+	// validation data set must be complete
+	if (valInMx == nil) != (valLabels == nil) {
+		return fmt.Errorf("Incorrect validation data supplied. In: %v, Out: %v\n", valInMx, valLabels)
+	}
+	// dropout makes the cost stochastic so it can only be used by mini-batch training
+	for _, layer := range n.Layers() {
+		if layer.Dropout() > 0 && c.Kind != "sgd" {
+			return fmt.Errorf("Dropout not supported by %s training\n", c.Kind)
+		}
+	}
+	// per layer lambda must refer to existing layers
+	for layer := range c.Lambdas {
+		if layer >= len(n.Layers()) {
+			return fmt.Errorf("Lambda supplied for nonexistent layer: %d\n", layer)
+		}
+	}
+	// early stopping monitors validation data set
+	var stopper *earlyStopping
+	if c.EarlyStop != nil {
+		if valInMx == nil {
+			return fmt.Errorf("Early stopping requires validation data set\n")
+		}
+		stopper = newEarlyStopping(c.EarlyStop)
+	}
+	// decision thresholds are tuned on validation data set
+	if c.Tune && valInMx == nil {
+		return fmt.Errorf("Thresholds tuning requires validation data set\n")
+	}
+	if cb == nil {
+		cb = NopCallback{}
+	}
+	cb.OnTrainBegin(c)
+	metrics, err := n.runEpochs(ctx, c, runEpoch, epochCost, valInMx, valLabels, manifest, cb, stopper)
+	cb.OnTrainEnd(metrics, err)
+	return err
+}
+
+// runEpochs runs the training epochs and returns the metrics of the last epoch
+func (n *Network) runEpochs(ctx context.Context, c *config.TrainConfig, runEpoch func(Callback) error,
+	epochCost func() (float64, error), valInMx *mat64.Dense, valLabels mat64.Matrix, manifest string, cb Callback,
+	stopper *earlyStopping) (Metrics, error) {
+	// run at least one epoch
+	epochs := c.Epochs
+	if epochs < 1 {
+		epochs = 1
+	}
+	metrics := Metrics{Epoch: n.epoch}
+	for i := 1; i <= epochs; i++ {
+		if err := runEpoch(cb); err != nil {
+			// keep the progress of interrupted training so it can be resumed
+			if ctx.Err() != nil {
+				if cpErr := n.checkpoint(manifest); cpErr != nil {
+					return metrics, cpErr
+				}
+			}
+			return metrics, err
+		}
+		n.epoch++
+		//save the network trained so far along with the manifest used for training
+		if err := n.checkpoint(manifest); err != nil {
+			return metrics, err
+		}
+		// report training cost over the whole data set
+		cost, err := epochCost()
+		if err != nil {
+			return metrics, err
+		}
+		metrics = Metrics{Epoch: n.epoch, Cost: cost, LearningRate: n.rate}
+		if valInMx != nil {
+			// validate the network after every epoch
+			metrics.Validated = true
+			if err := n.validateMetrics(&metrics, valInMx, valLabels); err != nil {
+				return metrics, err
+			}
+			if metrics.ValidationCost, err = n.validationCost(c, valInMx, valLabels); err != nil {
+				return metrics, err
+			}
+		}
+		if stopper != nil {
+			metric := metrics.Accuracy
+			if c.EarlyStop.Metric == "cost" {
+				metric = metrics.ValidationCost
+			}
+			metrics.Stopped = stopper.update(n, n.epoch, metric)
+		}
+		cb.OnEpochEnd(metrics)
+		if metrics.Stopped {
+			break
+		}
+	}
+	// restore the best model
+	if stopper != nil {
+		stopper.restore(n)
+		metrics.BestEpoch = stopper.bestEpoch
+	}
+	// tune decision thresholds of the final model
+	if c.Tune {
+		if _, err := n.TuneThresholds(valInMx, valLabels); err != nil {
+			return metrics, err
+		}
 	}
+	// save the restored or tuned model
+	if stopper != nil || c.Tune {
+		if err := n.checkpoint(manifest); err != nil {
+			return metrics, err
+		}
+	}
+	return metrics, nil
+}
 
+// checkpoint saves the network and its training progress into trainingdata directory
+// along with the manifest used for training, if any. It fails with error if anything can't be saved.
+func (n *Network) checkpoint(manifest string) error {
+	//keep the manifest used for training
+	if manifest != "" {
+		if _, err := keepManifest(manifest, "./trainingdata", "trainedManifest.yml"); err != nil {
+			return fmt.Errorf("Failed to save checkpoint: %s\n", err)
+		}
+	}
+	//save information gathered from training to files
+	for i := 1; i < len(n.layers); i++ {
+		// pooling and flatten layers have no weights
+		if n.layers[i].weights != nil {
+			if err := saveToFile(n, i); err != nil {
+				return fmt.Errorf("Failed to save checkpoint: %s\n", err)
+			}
+		}
+	}
+	if err := saveState(n); err != nil {
+		return fmt.Errorf("Failed to save checkpoint: %s\n", err)
+	}
+	return nil
+}
+
+// validateMetrics sets the validation metrics of the network task: classification accuracy
+// of CLASS network, regression metrics of PREDICT network or multilabel metrics of MULTILABEL network
+func (n *Network) validateMetrics(m *Metrics, valInMx *mat64.Dense, valLabels mat64.Matrix) error {
+	var err error
+	switch n.task {
+	case PREDICT:
+		m.Regression, err = n.Evaluate(valInMx, valLabels)
+		return err
+	case MULTILABEL:
+		if m.MultiLabel, err = n.EvaluateLabels(valInMx, valLabels); err != nil {
+			return err
+		}
+		m.Accuracy = 100 * m.MultiLabel.SubsetAccuracy
+		return nil
+	}
+	m.Accuracy, err = n.Validate(valInMx, valLabels)
+	return err
+}
+
+// validationCost calculates the cost of the network output for validation data set.
+// Unlike training cost it does not include the regularization.
+func (n *Network) validationCost(c *config.TrainConfig, valInMx *mat64.Dense, valLabels mat64.Matrix) (float64, error) {
+	outMx, err := n.ForwardProp(valInMx, len(n.Layers())-1)
+	if err != nil {
+		return -1.0, err
+	}
+	_, labelCount := outMx.Dims()
+	labelsMx, err := n.targets(valLabels, labelCount, 0)
+	if err != nil {
+		return -1.0, err
+	}
+	// validation cost is not weighted so it stays comparable across class configurations
+	tc := trainCost[c.Cost](c, nil)
+	return tc.CostFunc(valInMx, outMx, labelsMx), nil
+}
+
+// trainBackprop runs a single full batch training epoch using gonum optimization methods.
+// It stops before the next function evaluation once ctx is cancelled.
+func (n *Network) trainBackprop(ctx context.Context, c *config.TrainConfig, inMx *mat64.Dense, labels mat64.Matrix, cb Callback) error {
+	// batch normalized layers use the statistics of the whole data set during training
+	defer n.SetMode(n.mode)
+	n.SetMode(TRAINING)
 	// costFunc for optimization
-	var iter int = 0
 	costFunc := func(x []float64) float64 {
-		curCost, err := n.getCost(c, x, inMx, labelsVec)
+		curCost, err := n.getCost(c, x, inMx, labels)
 		if err != nil {
 			panic(err)
 		}
-		// TODO: can be enabled via verbose flag
-		if iter == 0 {
-			fmt.Printf("Initial Cost: %f ... starting optimization ...\n", curCost)
-		}else{
-			fmt.Printf("(%v out of a minimum of %v) Current Cost: %f\n", iter, c.Optimize.Iterations, curCost)
-		}
-		iter++
 		return curCost
 	}
 	// gradfunc for optimization
+	var gradX []float64
+	var gradNorm float64
 	gradFunc := func(grad []float64, x []float64) {
-		curGrad, err := n.getGradient(c, x, inMx, labelsVec)
+		curGrad, err := n.getGradient(c, x, inMx, labels)
 		if err != nil {
 			panic(err)
 		}
+		// remember the norm before clipping along with the evaluated location
+		gradNorm, _ = clipGradient(c.Clip, curGrad)
+		gradX = append(gradX[:0], x...)
 		cdata := copy(grad, curGrad)
 
 		//fmt.Printf("Current Grad successfull\n")
@@ -366,157 +899,294 @@ func (n *Network) Train(c *config.TrainConfig, inMx *mat64.Dense, labelsVec *mat
 	}
 	// initialize parameters
 	var initWeights []float64
-	layers := n.Layers()
+	params := n.params()
 	//fmt.Println("Layers: ", layers)
-	for i := range layers[1:] {
-		initWeights = append(initWeights, matrix.Mx2Vec(layers[i+1].Weights(), false)...)
+	for _, layer := range params {
+		initWeights = append(initWeights, matrix.Mx2Vec(layer.Weights(), false)...)
 	}
 	// optimization problem settings
 	p := optimize.Problem{
 		Func: costFunc,
 		Grad: gradFunc,
+		// terminate the optimization when training is cancelled
+		Status: func() (optimize.Status, error) {
+			return optimize.NotTerminated, ctx.Err()
+		},
 	}
 	//fmt.Println("Problem: ", p)
 	settings := optimize.DefaultSettings()
-	settings.Recorder = nil
+	// report optimization iterations to training callback
+	settings.Recorder = iterRecorder{cb: cb, gradNorm: func(x []float64) float64 {
+		// line searches may evaluate the gradient at rejected locations last
+		if !floats.Equal(x, gradX) {
+			curGrad, err := n.getGradient(c, x, inMx, labels)
+			if err != nil {
+				panic(err)
+			}
+			return floats.Norm(curGrad, 2)
+		}
+		return gradNorm
+	}}
 	settings.FunctionConverge = nil
 	settings.MajorIterations = c.Optimize.Iterations
 	//settings.Runtime = 36000
 	// run the optimization
 	//fmt.Println("Will run optimize for settings: ", settings)
-	_, err := optimize.Local(p, initWeights, settings, optim[c.Optimize.Method])
+	result, err := optimize.Local(p, initWeights, settings, optim[c.Optimize.Method](c.Optimize))
+	if err != nil && ctx.Err() != nil {
+		// roll the network back to the best location found before cancellation
+		if result != nil && len(result.X) == len(initWeights) {
+			if err := setNetWeights(params, result.X); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
 	if err != nil {
 		return err
 	}
-	//keep the manifest used for training
-	keepManifest(manifest, "./trainingdata", "trainedManifest.yml")
-	//save information gathered from training to files
-	for i :=1; i < len(layers); i++{
-		saveToFile(n,i)
+	// line searches leave the network at the last evaluated location which need not be the accepted one
+	if err := setNetWeights(params, result.X); err != nil {
+		return err
+	}
+	// running statistics are the statistics of the whole data set at the accepted location
+	for _, layer := range n.Layers() {
+		if layer.norm != nil {
+			samples, _ := inMx.Dims()
+			caches, err := n.forwardShards(inMx, workerCount(c, samples), nil)
+			if err != nil {
+				return err
+			}
+			n.updateStats(caches[0].norms, 0)
+			break
+		}
 	}
 	return nil
 }
 
 // getCost calculates the cost of the neural network output for given input and expected output.
 func (n *Network) getCost(c *config.TrainConfig, weights []float64,
-	inMx *mat64.Dense, labelsVec *mat64.Vector) (float64, error) {
-	// get all network layers
-	layers := n.Layers()
+	inMx *mat64.Dense, labels mat64.Matrix) (float64, error) {
 	// if we supply network weights, set the neural network to provided weights
 	if weights != nil {
-		if err := setNetWeights(layers[1:], weights); err != nil {
+		if err := setNetWeights(n.params(), weights); err != nil {
 			return -1.0, err
 		}
 	}
+	cost, err := n.dataCost(c, inMx, labels)
+	if err != nil {
+		return -1.0, err
+	}
+	//fmt.Printf("\nCost from Costfunc is: %v\n", cost)
+	// number of data samples
+	samples, _ := inMx.Dims()
+	return cost + n.penalty(c, samples), nil
+}
+
+// dataCost calculates the cost of the neural network output for given input and expected output
+// without the regularization penalty.
+func (n *Network) dataCost(c *config.TrainConfig, inMx *mat64.Dense, labels mat64.Matrix) (float64, error) {
 	// run forward propagation from INPUT layer
-	outMx, err := n.ForwardProp(inMx, len(layers)-1)
+	outMx, err := n.forwardProp(c, inMx)
 	if err != nil {
 		return -1.0, err
 	}
-	// labelsMx is one-of-N matrix for each output label
-	// i.e. 3rd label would be: 0 0 1 0 0 etc.
+	// expected network output for each sample
 	_, labelCount := outMx.Dims()
-	labelsMx, err := matrix.MakeLabelsMx(labelsVec, labelCount)
+	labelsMx, err := n.targets(labels, labelCount, c.Smoothing)
 	if err != nil {
 		return -1.0, err
 	}
 	// calculate cost
-	tc, _ := trainCost[c.Cost]
-	cost := tc.CostFunc(inMx, outMx, labelsMx)
-	//fmt.Printf("\nCost from Costfunc is: %v\n", cost)
-	// number of data samples
-	samples, _ := inMx.Dims()
+	tc := trainCost[c.Cost](c, n.classWeights)
+	return tc.CostFunc(inMx, outMx, labelsMx), nil
+}
+
+// penalty calculates the regularization penalty of network weights for given number of data samples
+func (n *Network) penalty(c *config.TrainConfig, samples int) float64 {
+	// get all network layers
+	layers := n.Layers()
 	reg := 0.0
-	// if regularizer is not 0, calculate L2-regularization
-	if c.Lambda > 0 {
-		// Ignore first layer i.e. input layer
-		for _, layer := range layers[1:] {
-			r, c := layer.Weights().Dims()
-			// Don't penalize bias units
-			weightsMx := layer.Weights().View(0, 1, r, c-1)
-			sqrMx := new(mat64.Dense)
-			sqrMx.Apply(matrix.PowMx(2), weightsMx)
-			reg += mat64.Sum(sqrMx)
+	regularizer := regularizers[c.Regularizer](c)
+	// Ignore first layer i.e. input layer
+	for i := 1; i < len(layers); i++ {
+		// if regularizer is not 0, calculate layer weights penalty
+		lambda := layerLambda(c, i)
+		if lambda == 0 || layers[i].weights == nil {
+			continue
 		}
-		reg = (c.Lambda / (2 * float64(samples))) * reg
+		r, cols := layers[i].Weights().Dims()
+		// Don't penalize bias units
+		weightsMx := layers[i].Weights().View(0, 1, r, cols-1)
+		reg += (lambda / float64(samples)) * regularizer.Penalty(weightsMx)
 	}
-	return cost + reg, nil
+	return reg
 }
 
 // getGradient calculates network gradient for a particular network and configuration
 // It returns a gradient slice or fails with error
 func (n *Network) getGradient(c *config.TrainConfig, weights []float64,
-	inMx *mat64.Dense, labelsVec *mat64.Vector) ([]float64, error) {
+	inMx *mat64.Dense, labels mat64.Matrix) ([]float64, error) {
+	grad, _, _, err := n.gradient(c, weights, inMx, labels)
+	return grad, err
+}
+
+// gradient calculates network gradient like getGradient. It also returns the cost of the forward
+// pass used to calculate the gradient and the batch normalization results of the forward pass
+// which contain the batch statistics of batch normalized layers.
+func (n *Network) gradient(c *config.TrainConfig, weights []float64,
+	inMx *mat64.Dense, labels mat64.Matrix) ([]float64, float64, []*normCache, error) {
 	// get all network layers
 	layers := n.Layers()
 	// if we supply network weights, set the neural network to provided weights
 	if weights != nil {
-		if err := setNetWeights(layers[1:], weights); err != nil {
-			return nil, err
+		if err := setNetWeights(n.params(), weights); err != nil {
+			return nil, -1.0, nil, err
 		}
 	}
-	// run full forward propagation
-	outMx, err := n.ForwardProp(inMx, len(layers)-1)
+	// expected network output for each sample
+	labelCount, _ := layers[len(layers)-1].Weights().Dims()
+	labelsMx, err := n.targets(labels, labelCount, c.Smoothing)
 	if err != nil {
-		return nil, err
-	}
-	// labelsMx is one-of-N matrix for each output label
-	// i.e. 3rd label would be: 0 0 1 0 0 etc.
-	_, labelCount := outMx.Dims()
-	labelsMx, err := matrix.MakeLabelsMx(labelsVec, labelCount)
-	if err != nil {
-		return nil, err
+		return nil, -1.0, nil, err
 	}
 	// number of data samples
 	samples, _ := inMx.Dims()
-	// iterate through all samples and calculate errors and corrections
-	for i := 0; i < samples; i++ {
-		// input vector
-		inVec := inMx.RowView(i)
-		// expected output
-		expVec := labelsMx.RowView(i)
-		// output from output layer - safe switch type - ForwardProp returns *mat64.Dense
-		outVec := (outMx.(*mat64.Dense)).RowView(i)
-		// calculate the error = out - y
-		tc, _ := trainCost[c.Cost]
-		deltaVec := tc.Delta(outVec, expVec)
-		// run the backpropagation
-		if err := n.BackProp(inVec.T(), deltaVec.T(), len(layers)-1); err != nil {
-			return nil, err
+	tc := trainCost[c.Cost](c, n.classWeights)
+	// samples are sharded across workers, each worker accumulates its own deltas
+	workers := workerCount(c, samples)
+	workerDeltas := make([][]*mat64.Dense, workers)
+	for w := range workerDeltas {
+		workerDeltas[w] = make([]*mat64.Dense, len(layers))
+		for i := 1; i < len(layers); i++ {
+			// pooling and flatten layers have no weights
+			if layers[i].weights == nil {
+				continue
+			}
+			r, c := layers[i].Weights().Dims()
+			workerDeltas[w][i] = mat64.NewDense(r, c, nil)
+		}
+	}
+	// batch normalization deltas are summed over the whole batch
+	normDeltas := make([]*mat64.Dense, len(layers))
+	for i, layer := range layers {
+		if layer.norm != nil {
+			r, c := layer.norm.Weights().Dims()
+			normDeltas[i] = mat64.NewDense(r, c, nil)
+		}
+	}
+	// run forward propagation of the whole batch once
+	caches, err := n.forwardShards(inMx, workers, n.dropMasks(samples))
+	if err != nil {
+		return nil, -1.0, nil, err
+	}
+	// cost is calculated from the same outputs as the gradient.
+	// Cost functions may modify their arguments so they are passed copies
+	outMx := mat64.NewDense(samples, labelCount, nil)
+	for w, cache := range caches {
+		from, to := shard(samples, workers, w)
+		outMx.View(from, 0, to-from, labelCount).(*mat64.Dense).Copy(cache.outs[len(layers)-1])
+	}
+	cost := tc.CostFunc(inMx, outMx, mat64.DenseCopyOf(labelsMx)) + n.penalty(c, samples)
+	// calculate the error = out - y
+	errMxs := make([]mat64.Matrix, workers)
+	for w, cache := range caches {
+		from, to := shard(samples, workers, w)
+		errMxs[w] = tc.Delta(cache.outs[len(layers)-1], labelsMx.View(from, 0, to-from, labelCount))
+	}
+	// run the backpropagation
+	if err := n.backPropShards(caches, errMxs, workerDeltas, normDeltas); err != nil {
+		return nil, -1.0, nil, err
+	}
+	// reduce worker deltas in a fixed order so the result is deterministic
+	for i := 1; i < len(layers); i++ {
+		if layers[i].weights == nil {
+			continue
+		}
+		deltas := layers[i].Deltas()
+		deltas.Copy(workerDeltas[0][i])
+		for w := 1; w < workers; w++ {
+			deltas.Add(deltas, workerDeltas[w][i])
+		}
+		if norm := layers[i].norm; norm != nil {
+			norm.deltas.Copy(normDeltas[i])
 		}
 	}
 	// calculate the gradient and update network weights
 	var gradient []float64
+	regularizer := regularizers[c.Regularizer](c)
 	// skip zero layer - INPUT layer has no Deltas
 	for i := 1; i < len(layers); i++ {
 		layer := layers[i]
+		if layer.weights == nil {
+			continue
+		}
 		deltas := layer.Deltas()
-		deltas.Scale(c.Learningrate, deltas)
-		if c.Lambda > 0.0 {
-			rows, cols := layer.Weights().Dims()
-			regWeights := mat64.NewDense(rows, cols, nil)
-			reg := c.Lambda / float64(samples)
-			regWeights.Clone(layer.Weights())
+		// cost is averaged over all samples so is its gradient
+		deltas.Scale(1/float64(samples), deltas)
+		gradMx := deltas
+		if lambda := layerLambda(c, i); lambda > 0.0 {
+			rows, _ := layer.Weights().Dims()
+			regWeights := new(mat64.Dense)
+			reg := lambda / float64(samples)
+			regWeights.Apply(regularizer.Grad, layer.Weights())
 			// set the first column to 0
 			zeros := make([]float64, rows)
 			regWeights.SetCol(0, zeros)
 			regWeights.Scale(reg, regWeights)
 			// Update particular layer deltas matrix
 			regWeights.Add(deltas, regWeights)
-			gradVec := matrix.Mx2Vec(regWeights, false)
-			gradient = append(gradient, gradVec...)
+			gradMx = regWeights
+		}
+		gradVec := matrix.Mx2Vec(gradMx, false)
+		gradient = append(gradient, gradVec...)
+		// batch normalization parameters are not regularized
+		if norm := layer.norm; norm != nil {
+			norm.deltas.Scale(1/float64(samples), norm.deltas)
+			gradient = append(gradient, matrix.Mx2Vec(norm.deltas, false)...)
 		}
 	}
-	return gradient, nil
+	return gradient, cost, caches[0].norms, nil
+}
+
+// updateStats updates running statistics of batch normalized layers with the batch statistics
+// of the supplied batch normalization results using the given momentum.
+// Running statistics are only updated if the batch statistics were used i.e. in TRAINING mode.
+func (n *Network) updateStats(norms []*normCache, momentum float64) {
+	for i, layer := range n.Layers() {
+		if layer.norm == nil || !norms[i].batch {
+			continue
+		}
+		layer.updateStats(norms[i].mean, norms[i].variance, momentum)
+	}
+}
+
+// infer runs forward propagation of the network in INFERENCE mode and returns the network output
+func (n *Network) infer(inMx mat64.Matrix) (*mat64.Dense, error) {
+	// neuron outputs are never dropped during inference
+	defer n.SetMode(n.mode)
+	n.SetMode(INFERENCE)
+	out, err := n.ForwardProp(inMx, len(n.Layers())-1)
+	if err != nil {
+		return nil, err
+	}
+	return mat64.DenseCopyOf(out), nil
 }
 
 // Classify classifies the provided data vector to a particular label class.
 // It returns a matrix that contains probabilities of the input belonging to a particular class
-// It returns error if the network forward propagation fails at any point during classification.
+// It returns error if the network does not classify or if the network forward propagation fails
+// at any point during classification.
 func (n *Network) Classify(inMx mat64.Matrix) (mat64.Matrix, error) {
 	if inMx == nil {
 		return nil, fmt.Errorf("Can't classify %v\n", inMx)
 	}
+	if n.task != CLASS {
+		return nil, fmt.Errorf("Classification not supported by %s task\n", n.task)
+	}
+	// neuron outputs are never dropped during inference
+	defer n.SetMode(n.mode)
+	n.SetMode(INFERENCE)
 	// do forward propagation
 	out, err := n.ForwardProp(inMx, len(n.Layers())-1)
 	if err != nil {
@@ -548,11 +1218,18 @@ func (n *Network) Classify(inMx mat64.Matrix) (mat64.Matrix, error) {
 
 // Validate runs forward propagation on the validation data set through neural network.
 // It returns the percentage of successful classifications or error.
-func (n *Network) Validate(valInMx *mat64.Dense, valOut *mat64.Vector) (float64, error) {
+// Networks of PREDICT task are evaluated by Evaluate instead.
+func (n *Network) Validate(valInMx *mat64.Dense, valOut mat64.Matrix) (float64, error) {
 	// validation set can't be nil
 	if valInMx == nil || valOut == nil {
 		return 0.0, fmt.Errorf("Cant validate data set. In: %v, Out: %v\n", valInMx, valOut)
 	}
+	if n.task != CLASS {
+		return 0.0, fmt.Errorf("Validation accuracy not supported by %s task\n", n.task)
+	}
+	// neuron outputs are never dropped during inference
+	defer n.SetMode(n.mode)
+	n.SetMode(INFERENCE)
 	out, err := n.ForwardProp(valInMx, len(n.Layers())-1)
 	if err != nil {
 		return 0.0, err
@@ -572,7 +1249,8 @@ func (n *Network) Validate(valInMx *mat64.Dense, valOut *mat64.Vector) (float64,
 			}
 		}
 	}
-	success := (hits / float64(valOut.Len())) * 100
+	samples, _ := valOut.Dims()
+	success := (hits / float64(samples)) * 100
 	return success, nil
 }
 
@@ -638,20 +1316,37 @@ func setNetWeights(layers []*Layer, weights []float64) error {
 
 
 //save weights or deltas to file
-func saveToFile(net *Network, id int) {
+func saveToFile(net *Network, id int) error {
 	strID := strconv.Itoa(id)
-	h, err := os.Create("trainingdata/" + strID + "weights.model")
-	defer h.Close()
-	if err == nil {
-		
-		net.layers[id].weights.MarshalBinaryTo(h)
+	if err := saveMatrix("trainingdata/"+strID+"weights.model", net.layers[id].weights); err != nil {
+		return err
+	}
+	if err := saveMatrix("trainingdata/"+strID+"deltas.model", net.layers[id].deltas); err != nil {
+		return err
 	}
-	o, err := os.Create("trainingdata/" + strID + "deltas.model")
-	defer o.Close()
-	if err == nil {
+	// batch normalization parameters and running statistics
+	if net.layers[id].norm != nil {
+		if err := saveMatrix("trainingdata/"+strID+"norm.model", net.layers[id].norm.weights); err != nil {
+			return err
+		}
+		if err := saveMatrix("trainingdata/"+strID+"stats.model", net.layers[id].stats); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		net.layers[id].deltas.MarshalBinaryTo(o)
+// saveMatrix saves matrix m into file of the given name
+func saveMatrix(name string, m *mat64.Dense) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := m.MarshalBinaryTo(f); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
 }
 
 //load weights or deltas from file
@@ -659,10 +1354,13 @@ func LoadFromFile(net *Network) error {
 	
 	var initWeights *mat64.Dense
 	var initDeltas *mat64.Dense
-	var err error
 	layers := net.Layers()
 
 	for i :=1; i < len(layers); i++ {
+		// pooling and flatten layers have no weights
+		if layers[i].weights == nil {
+			continue
+		}
 		strID := strconv.Itoa(i)
 		h, err := os.Open("trainingdata/" + strID + "weights.model")
 		defer h.Close()
@@ -682,10 +1380,93 @@ func LoadFromFile(net *Network) error {
 		}else{
 			return err
 		}
+		if net.layers[i].norm != nil {
+			if err := loadNorm(net.layers[i], strID); err != nil {
+				return err
+			}
+		}
+	}
+	return loadState(net)
+}
+
+//load batch normalization parameters and running statistics from files
+func loadNorm(layer *Layer, strID string) error {
+	b, err := os.Open("trainingdata/" + strID + "norm.model")
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+	layer.norm.weights.Reset()
+	if _, err := layer.norm.weights.UnmarshalBinaryFrom(b); err != nil {
+		return err
 	}
+	s, err := os.Open("trainingdata/" + strID + "stats.model")
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	layer.stats.Reset()
+	_, err = layer.stats.UnmarshalBinaryFrom(s)
 	return err
 }
 
+// trainState is training progress saved alongside network weights so that
+// resumed training continues where it left off
+type trainState struct {
+	// Epoch is the number of completed training epochs
+	Epoch int `yaml:"epoch"`
+	// Step is the number of mini-batch training steps
+	Step int `yaml:"step"`
+	// Learningrate is the learning rate used by the latest training step
+	Learningrate float64 `yaml:"learningrate"`
+	// Seed is the seed of the network random generator
+	Seed int64 `yaml:"seed,omitempty"`
+	// Thresholds are decision thresholds of MULTILABEL network outputs
+	Thresholds []float64 `yaml:"thresholds,omitempty"`
+}
+
+//save training progress to file
+func saveState(net *Network) error {
+	data, err := yaml.Marshal(&trainState{
+		Epoch:        net.epoch,
+		Step:         net.step,
+		Learningrate: net.rate,
+		Seed:         net.seed,
+		Thresholds:   net.thresholds,
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile("trainingdata/state.yml", data, 0644)
+}
+
+//load training progress from file
+//networks trained before the progress was recorded have no state file
+func loadState(net *Network) error {
+	data, err := ioutil.ReadFile("trainingdata/state.yml")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state trainState
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	net.epoch, net.step, net.rate = state.Epoch, state.Step, state.Learningrate
+	// resumed training is reproducible too
+	if state.Seed != 0 {
+		net.seed = state.Seed
+		net.rng.Seed(state.Seed + int64(state.Epoch))
+	}
+	// tuned thresholds override the configured ones
+	if len(state.Thresholds) != 0 {
+		return net.SetThresholds(state.Thresholds)
+	}
+	return nil
+}
+
 func keepManifest(src, dst, newname  string) (int64, error) {
         var nBytes int64
         newPath := dst +"/" + newname
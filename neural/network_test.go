@@ -1,6 +1,7 @@
 package neural
 
 import (
+	"context"
 	"io/ioutil"
 	"log"
 	"os"
@@ -61,6 +62,30 @@ func teardown() {
 	os.Remove(filepath.Join(os.TempDir(), fileName))
 }
 
+// inTrainingDir changes the working directory to a new temporary directory with trainingdata
+// subdirectory checkpoints are saved into. The returned function restores the working directory
+// and removes the temporary one.
+func inTrainingDir(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "trainingdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("trainingdata", 0755); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	}
+}
+
 func TestMain(m *testing.M) {
 	// set up tests
 	setup()
@@ -400,6 +425,16 @@ func TestValidateTrainConfig(t *testing.T) {
 	err = ValidateTrainConfig(c)
 	assert.Error(err)
 	c.Lambda = origLambda
+	// wrong number of workers
+	c.Workers = -1
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Workers = 0
+	// wrong label smoothing
+	c.Smoothing = 1.0
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Smoothing = 0
 	// unsupported Optimization method
 	origMethod := c.Optimize.Method
 	c.Optimize.Method = "foobar"
@@ -412,10 +447,132 @@ func TestValidateTrainConfig(t *testing.T) {
 	err = ValidateTrainConfig(c)
 	assert.Error(err)
 	c.Optimize.Iterations = origIters
+	// all optimization methods are available
+	for method := range optim {
+		c.Optimize.Method = method
+		err = ValidateTrainConfig(c)
+		assert.NoError(err)
+	}
+	// L-BFGS with strong Wolfe line search
+	c.Optimize.Method = "lbfgs"
+	c.Optimize.Store = 10
+	c.Optimize.Linesearch = "bisection"
+	err = ValidateTrainConfig(c)
+	assert.NoError(err)
+	// backtracking does not satisfy strong Wolfe conditions
+	c.Optimize.Linesearch = "backtracking"
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Linesearch = ""
+	// store is only used by L-BFGS
+	c.Optimize.Method = "bfgs"
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Store = 0
+	// CG variant and step size
+	c.Optimize.Method = "cg"
+	c.Optimize.Variant = "polakribiere"
+	c.Optimize.Stepsize = "firstorder"
+	err = ValidateTrainConfig(c)
+	assert.NoError(err)
+	// unsupported CG variant
+	c.Optimize.Variant = "foobar"
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Variant = ""
+	// constant step size must be positive
+	c.Optimize.Method = "gd"
+	c.Optimize.Stepsize = "constant"
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Step = 0.1
+	err = ValidateTrainConfig(c)
+	assert.NoError(err)
+	// step is only used by constant step size
+	c.Optimize.Stepsize = "quadratic"
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Step = 0
+	c.Optimize.Stepsize = ""
+	// Nelder-Mead does not use line search
+	c.Optimize.Method = "neldermead"
+	c.Optimize.Linesearch = "bisection"
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Linesearch = ""
+	// mini-batch options are not used by full batch methods
+	c.Optimize.Method = "bfgs"
+	c.Optimize.Momentum = 0.9
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Momentum = 0
+	c.Optimize.Method = origMethod
+	// unsupported training kind
+	c.Kind = "foobar"
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	// mini-batch training requires first order optimizer
+	c.Kind = "sgd"
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Method = "sgd"
+	c.Optimize.Batchsize = 10
+	c.Learningrate = 0.1
+	err = ValidateTrainConfig(c)
+	assert.NoError(err)
+	// learning rate must be positive
+	c.Learningrate = 0
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Learningrate = 0.1
+	// incorrect momentum
+	c.Optimize.Momentum = 1.0
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Momentum = 0
+	// incorrect batch size
+	c.Optimize.Batchsize = 0
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Batchsize = 10
+	// weight decay is only supported by adamw
+	c.Optimize.Method = "adam"
+	c.Optimize.Weightdecay = 0.01
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Method = "adamw"
+	err = ValidateTrainConfig(c)
+	assert.NoError(err)
+	c.Optimize.Weightdecay = 0
+	// decay rates must be in [0,1)
+	for _, rate := range []float64{-0.5, 1.0} {
+		r := rate
+		c.Optimize.Beta2 = &r
+		err = ValidateTrainConfig(c)
+		assert.Error(err)
+		c.Optimize.Beta2 = nil
+		c.Optimize.Rho = &r
+		err = ValidateTrainConfig(c)
+		assert.Error(err)
+		c.Optimize.Rho = nil
+	}
+	// full batch options are not used by first order optimizers
+	c.Optimize.Linesearch = "bisection"
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Linesearch = ""
+	c.Optimize.Store = 5
+	err = ValidateTrainConfig(c)
+	assert.Error(err)
+	c.Optimize.Store = 0
+	c.Optimize.Batchsize = 0
+	c.Kind = "backprop"
+	c.Optimize.Method = origMethod
 }
 
 func TestTrain(t *testing.T) {
 	assert := assert.New(t)
+	defer inTrainingDir(t)()
 	// basic configuration settings
 	tmpPath := path.Join(os.TempDir(), fileName)
 	conf, err := config.New(tmpPath)
@@ -428,17 +585,246 @@ func TestTrain(t *testing.T) {
 	assert.NoError(err)
 	// nil config causes error
 	trainConf := conf.Training
-	err = n.Train(nil, inMx, labelsVec)
+	err = n.Train(context.Background(), nil, inMx, labelsVec, nil, nil, "", nil)
 	assert.Error(err)
 	// nil input causes error
-	err = n.Train(trainConf, nil, labelsVec)
+	err = n.Train(context.Background(), trainConf, nil, labelsVec, nil, nil, "", nil)
 	assert.Error(err)
 	// nil labelsVec causes error
-	err = n.Train(trainConf, inMx, nil)
+	err = n.Train(context.Background(), trainConf, inMx, nil, nil, nil, "", nil)
 	assert.Error(err)
 	// calculate cost
-	err = n.Train(trainConf, inMx, labelsVec)
+	err = n.Train(context.Background(), trainConf, inMx, labelsVec, nil, nil, "", nil)
+	assert.NoError(err)
+	// all optimization methods can train the network
+	origMethod := trainConf.Optimize.Method
+	for method := range optim {
+		trainConf.Optimize.Method = method
+		err = n.Train(context.Background(), trainConf, inMx, labelsVec, nil, nil, "", nil)
+		assert.NoError(err)
+	}
+	trainConf.Optimize.Method = origMethod
+	// training fails if its checkpoint can't be saved
+	assert.NoError(os.RemoveAll("trainingdata"))
+	err = n.Train(context.Background(), trainConf, inMx, labelsVec, nil, nil, "", nil)
+	assert.Error(err)
+}
+
+func TestTrainSGD(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	// basic configuration settings
+	tmpPath := path.Join(os.TempDir(), fileName)
+	conf, err := config.New(tmpPath)
+	assert.NotNil(conf)
+	assert.NoError(err)
+	// create new network
+	n, err := NewNetwork(conf.Network)
+	assert.NotNil(n)
 	assert.NoError(err)
+	// mini-batch training configuration
+	trainConf := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "xentropy",
+		Learningrate: 0.5,
+		Epochs:       20,
+		Optimize: &config.OptimConfig{
+			Method:    "sgd",
+			Batchsize: 2,
+		},
+	}
+	initCost, err := n.getCost(trainConf, nil, inMx, labelsVec)
+	assert.NoError(err)
+	err = n.Train(context.Background(), trainConf, inMx, labelsVec, nil, nil, "", nil)
+	assert.NoError(err)
+	cost, err := n.getCost(trainConf, nil, inMx, labelsVec)
+	assert.NoError(err)
+	assert.True(cost < initCost)
+	// classical and Nesterov momentum
+	for _, nesterov := range []bool{false, true} {
+		trainConf.Optimize.Momentum = 0.9
+		trainConf.Optimize.Nesterov = nesterov
+		err = n.Train(context.Background(), trainConf, inMx, labelsVec, nil, nil, "", nil)
+		assert.NoError(err)
+		for _, layer := range n.Layers()[1:] {
+			assert.NotNil(layer.velocity)
+		}
+	}
+}
+
+func TestTrainAdaptive(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	// basic configuration settings
+	tmpPath := path.Join(os.TempDir(), fileName)
+	conf, err := config.New(tmpPath)
+	assert.NotNil(conf)
+	assert.NoError(err)
+	for _, method := range []string{"adam", "adamw", "rmsprop", "adagrad"} {
+		// create new network
+		n, err := NewNetwork(conf.Network)
+		assert.NotNil(n)
+		assert.NoError(err)
+		trainConf := &config.TrainConfig{
+			Kind:         "sgd",
+			Cost:         "xentropy",
+			Learningrate: 0.01,
+			Epochs:       20,
+			Optimize: &config.OptimConfig{
+				Method:    method,
+				Batchsize: 5,
+			},
+		}
+		if method == "adamw" {
+			trainConf.Optimize.Weightdecay = 0.01
+		}
+		initCost, err := n.getCost(trainConf, nil, inMx, labelsVec)
+		assert.NoError(err)
+		err = n.Train(context.Background(), trainConf, inMx, labelsVec, nil, nil, "", nil)
+		assert.NoError(err)
+		cost, err := n.getCost(trainConf, nil, inMx, labelsVec)
+		assert.NoError(err)
+		assert.True(cost < initCost, method)
+		// optimizer state is kept alongside layer weights
+		for _, layer := range n.Layers()[1:] {
+			assert.NotNil(layer.variance)
+			assert.Equal(layer.steps, 20)
+		}
+	}
+	// weight decay is only supported by adamw
+	trainConf := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "xentropy",
+		Learningrate: 0.01,
+		Optimize: &config.OptimConfig{
+			Method:      "adam",
+			Batchsize:   5,
+			Weightdecay: 0.01,
+		},
+	}
+	err = ValidateTrainConfig(trainConf)
+	assert.Error(err)
+	// incorrect moment decay rate
+	trainConf.Optimize.Weightdecay = 0
+	beta1 := 1.0
+	trainConf.Optimize.Beta1 = &beta1
+	err = ValidateTrainConfig(trainConf)
+	assert.Error(err)
+	trainConf.Optimize.Beta1 = nil
+	rho := 1.5
+	trainConf.Optimize.Rho = &rho
+	err = ValidateTrainConfig(trainConf)
+	assert.Error(err)
+}
+
+// cancelCallback cancels the training after the first optimization iteration
+type cancelCallback struct {
+	NopCallback
+	cancel context.CancelFunc
+}
+
+func (c cancelCallback) OnIteration(iter int, cost, gradNorm, clippedNorm float64) {
+	c.cancel()
+}
+
+func TestTrainCancel(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	conf, err := config.New(path.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	trainConf := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "xentropy",
+		Learningrate: 0.5,
+		Epochs:       5,
+		Optimize: &config.OptimConfig{
+			Method:    "sgd",
+			Batchsize: 2,
+		},
+	}
+	// cancelled context stops training before the first mini-batch
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = n.Train(ctx, trainConf, inMx, labelsVec, nil, nil, "", nil)
+	assert.Equal(context.Canceled, err)
+	assert.Equal(0, n.Epoch())
+	assert.Equal(0, n.step)
+	// training stops at the next mini-batch and the progress is saved
+	ctx, cancel = context.WithCancel(context.Background())
+	err = n.Train(ctx, trainConf, inMx, labelsVec, nil, nil, "", cancelCallback{cancel: cancel})
+	assert.Equal(context.Canceled, err)
+	assert.Equal(1, n.step)
+	resumed, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	assert.NoError(LoadFromFile(resumed))
+	assert.Equal(1, resumed.step)
+	for i, layer := range resumed.Layers()[1:] {
+		assert.True(mat64.EqualApprox(layer.Weights(), n.Layers()[i+1].Weights(), 1e-6))
+	}
+	// full batch optimization stops at the next function evaluation
+	trainConf = &config.TrainConfig{
+		Kind:   "backprop",
+		Cost:   "xentropy",
+		Epochs: 5,
+		Optimize: &config.OptimConfig{
+			Method:     "bfgs",
+			Iterations: 20,
+		},
+	}
+	n, err = NewNetwork(conf.Network)
+	assert.NoError(err)
+	ctx, cancel = context.WithCancel(context.Background())
+	err = n.Train(ctx, trainConf, inMx, labelsVec, nil, nil, "", cancelCallback{cancel: cancel})
+	assert.Equal(context.Canceled, err)
+	assert.Equal(0, n.Epoch())
+}
+
+func TestAdamUpdate(t *testing.T) {
+	assert := assert.New(t)
+	layer := &Layer{weights: mat64.NewDense(1, 2, []float64{1.0, 1.0})}
+	grad := mat64.NewDense(1, 2, []float64{0.5, -2.0})
+	// first bias corrected Adam step is lr * sign(grad)
+	adam := &Adam{}
+	adam.Update([]*Layer{layer}, []*mat64.Dense{grad}, 0.1)
+	assert.InDelta(layer.weights.At(0, 0), 0.9, 1e-6)
+	assert.InDelta(layer.weights.At(0, 1), 1.1, 1e-6)
+	assert.Equal(layer.steps, 1)
+	// configured decay rates are used as they are
+	beta1, rho := 0.0, 0.5
+	optimizer := firstOrder["adam"](&config.OptimConfig{Beta1: &beta1})
+	assert.Equal(&Adam{Beta1: 0, Beta2: defaultBeta2}, optimizer)
+	optimizer = firstOrder["rmsprop"](&config.OptimConfig{Rho: &rho})
+	assert.Equal(&RMSProp{Rho: 0.5}, optimizer)
+	optimizer = firstOrder["rmsprop"](&config.OptimConfig{})
+	assert.Equal(&RMSProp{Rho: defaultRho}, optimizer)
+}
+
+func TestGetGradient(t *testing.T) {
+	assert := assert.New(t)
+	// basic configuration settings
+	tmpPath := path.Join(os.TempDir(), fileName)
+	conf, err := config.New(tmpPath)
+	assert.NotNil(conf)
+	assert.NoError(err)
+	// create new network
+	n, err := NewNetwork(conf.Network)
+	assert.NotNil(n)
+	assert.NoError(err)
+	// gradient is available without regularization and does not accumulate across calls
+	conf.Training.Lambda = 0
+	grad, err := n.getGradient(conf.Training, nil, inMx, labelsVec)
+	assert.NoError(err)
+	acc := 0
+	for _, layer := range n.Layers()[1:] {
+		r, c := layer.Weights().Dims()
+		acc += r * c
+	}
+	assert.Equal(len(grad), acc)
+	gradAgain, err := n.getGradient(conf.Training, nil, inMx, labelsVec)
+	assert.NoError(err)
+	assert.Equal(grad, gradAgain)
 }
 
 func TestClassify(t *testing.T) {
@@ -538,3 +924,45 @@ func TestSetNetWeights(t *testing.T) {
 	err = setNetWeights(layers[1:], weights)
 	assert.Error(err)
 }
+
+func TestSeed(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	conf, err := config.New(path.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	conf.Network.Arch.Hidden[0].Dropout = 0.5
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "loglike",
+		Learningrate: 0.1,
+		Epochs:       2,
+		Optimize:     &config.OptimConfig{Method: "sgd", Batchsize: 2},
+	}
+	// random seed is picked if none is configured
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	assert.NotEqual(int64(0), n.Seed())
+	// networks trained with the same seed are identical
+	conf.Network.Seed = 42
+	var nets []*Network
+	for i := 0; i < 2; i++ {
+		n, err := NewNetwork(conf.Network)
+		assert.NoError(err)
+		assert.Equal(int64(42), n.Seed())
+		assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+		nets = append(nets, n)
+	}
+	assert.Equal(nets[0].ID(), nets[1].ID())
+	for i, layer := range nets[0].Layers()[1:] {
+		assert.Equal(layer.ID(), nets[1].Layers()[i+1].ID())
+		assert.True(mat64.Equal(layer.Weights(), nets[1].Layers()[i+1].Weights()))
+	}
+	// different seed creates different network
+	conf.Network.Seed = 43
+	n, err = NewNetwork(conf.Network)
+	assert.NoError(err)
+	assert.False(mat64.Equal(nets[0].Layers()[1].Weights(), n.Layers()[1].Weights()))
+	// the seed is saved with the network
+	assert.NoError(LoadFromFile(n))
+	assert.Equal(int64(42), n.Seed())
+}
@@ -0,0 +1,152 @@
+package neural
+
+import (
+	"fmt"
+
+	"github.com/gonum/optimize"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// optim maps optimization algorithm names to constructors of their actual implementations.
+// New optimization method instance is created for every training run as gonum methods are stateful.
+var optim = map[string]func(*config.OptimConfig) optimize.Method{
+	"bfgs": func(c *config.OptimConfig) optimize.Method {
+		return &optimize.BFGS{
+			Linesearcher: newLinesearcher(c.Linesearch),
+		}
+	},
+	"lbfgs": func(c *config.OptimConfig) optimize.Method {
+		return &optimize.LBFGS{
+			Linesearcher: newLinesearcher(c.Linesearch),
+			Store:        c.Store,
+		}
+	},
+	"cg": func(c *config.OptimConfig) optimize.Method {
+		return &optimize.CG{
+			Linesearcher: newLinesearcher(c.Linesearch),
+			Variant:      newCGVariant(c.Variant),
+			InitialStep:  newStepSizer(c.Stepsize, c.Step),
+		}
+	},
+	"gd": func(c *config.OptimConfig) optimize.Method {
+		return &optimize.GradientDescent{
+			Linesearcher: newLinesearcher(c.Linesearch),
+			StepSizer:    newStepSizer(c.Stepsize, c.Step),
+		}
+	},
+	"neldermead": func(c *config.OptimConfig) optimize.Method {
+		return &optimize.NelderMead{
+			SimplexSize: c.Simplex,
+		}
+	},
+}
+
+// linesearch maps line search algorithm names to their constructors
+var linesearch = map[string]func() optimize.Linesearcher{
+	"backtracking": func() optimize.Linesearcher { return &optimize.Backtracking{} },
+	"bisection":    func() optimize.Linesearcher { return &optimize.Bisection{} },
+	"morethuente":  func() optimize.Linesearcher { return &optimize.MoreThuente{} },
+}
+
+// wolfe contains line search algorithms which satisfy strong Wolfe conditions
+var wolfe = map[string]bool{
+	"bisection":   true,
+	"morethuente": true,
+}
+
+// cgVariant maps CG variant names to their constructors
+var cgVariant = map[string]func() optimize.CGVariant{
+	"fletcherreeves":  func() optimize.CGVariant { return &optimize.FletcherReeves{} },
+	"polakribiere":    func() optimize.CGVariant { return &optimize.PolakRibierePolyak{} },
+	"hestenesstiefel": func() optimize.CGVariant { return &optimize.HestenesStiefel{} },
+	"daiyuan":         func() optimize.CGVariant { return &optimize.DaiYuan{} },
+	"hagerzhang":      func() optimize.CGVariant { return &optimize.HagerZhang{} },
+}
+
+// stepSizer maps step size algorithm names to their constructors
+var stepSizer = map[string]func(float64) optimize.StepSizer{
+	"constant":   func(step float64) optimize.StepSizer { return optimize.ConstantStepSize{Size: step} },
+	"quadratic":  func(float64) optimize.StepSizer { return &optimize.QuadraticStepSize{} },
+	"firstorder": func(float64) optimize.StepSizer { return &optimize.FirstOrderStepSize{} },
+}
+
+// newLinesearcher returns the requested line search algorithm.
+// It returns nil if no line search is requested so that optimize picks its default.
+func newLinesearcher(name string) optimize.Linesearcher {
+	if newLs, ok := linesearch[name]; ok {
+		return newLs()
+	}
+	return nil
+}
+
+// newCGVariant returns the requested CG variant or nil if none is requested
+func newCGVariant(name string) optimize.CGVariant {
+	if newVariant, ok := cgVariant[name]; ok {
+		return newVariant()
+	}
+	return nil
+}
+
+// newStepSizer returns the requested step size algorithm or nil if none is requested
+func newStepSizer(name string, step float64) optimize.StepSizer {
+	if newStep, ok := stepSizer[name]; ok {
+		return newStep(step)
+	}
+	return nil
+}
+
+// validateOptimConfig validates method specific optimization options.
+// It returns error if any of the options is unknown or can't be used with the requested method.
+func validateOptimConfig(c *config.OptimConfig) error {
+	// line search must be known and methods other than gd require strong Wolfe conditions
+	if c.Linesearch != "" {
+		if _, ok := linesearch[c.Linesearch]; !ok {
+			return fmt.Errorf("Unsupported line search: %s\n", c.Linesearch)
+		}
+		if c.Method == "neldermead" {
+			return fmt.Errorf("Line search not supported by %s\n", c.Method)
+		}
+		if c.Method != "gd" && !wolfe[c.Linesearch] {
+			return fmt.Errorf("Line search %s not supported by %s\n", c.Linesearch, c.Method)
+		}
+	}
+	// step size is only used by cg and gd
+	if c.Stepsize != "" {
+		if _, ok := stepSizer[c.Stepsize]; !ok {
+			return fmt.Errorf("Unsupported step size: %s\n", c.Stepsize)
+		}
+		if c.Method != "cg" && c.Method != "gd" {
+			return fmt.Errorf("Step size not supported by %s\n", c.Method)
+		}
+		if c.Stepsize == "constant" && c.Step <= 0 {
+			return fmt.Errorf("Incorrect constant step size: %f\n", c.Step)
+		}
+	}
+	// step is only used by constant step size
+	if c.Step < 0 || (c.Step > 0 && c.Stepsize != "constant") {
+		return fmt.Errorf("Incorrect step for %s step size: %f\n", c.Stepsize, c.Step)
+	}
+	// variant is only used by cg
+	if c.Variant != "" {
+		if _, ok := cgVariant[c.Variant]; !ok {
+			return fmt.Errorf("Unsupported CG variant: %s\n", c.Variant)
+		}
+		if c.Method != "cg" {
+			return fmt.Errorf("CG variant not supported by %s\n", c.Method)
+		}
+	}
+	// L-BFGS history size
+	if c.Store < 0 || (c.Store > 0 && c.Method != "lbfgs") {
+		return fmt.Errorf("Incorrect L-BFGS store: %d\n", c.Store)
+	}
+	// Nelder-Mead simplex size
+	if c.Simplex < 0 || (c.Simplex > 0 && c.Method != "neldermead") {
+		return fmt.Errorf("Incorrect Nelder-Mead simplex size: %f\n", c.Simplex)
+	}
+	// mini-batch options are not used by full batch methods
+	if c.Momentum != 0 || c.Nesterov || c.Beta1 != nil || c.Beta2 != nil || c.Rho != nil ||
+		c.Epsilon != 0 || c.Weightdecay != 0 {
+		return fmt.Errorf("Mini-batch options not supported by %s\n", c.Method)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package neural
+
+import (
+	"sync"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// workerCount returns the number of goroutines used to process the given number of samples.
+// Single goroutine is used unless configured otherwise and there is never more goroutines than samples.
+func workerCount(c *config.TrainConfig, samples int) int {
+	workers := c.Workers
+	if workers > samples {
+		workers = samples
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// shard returns the range of samples [from, to) processed by the worker w.
+// Samples are split into contiguous shards of (almost) equal size.
+func shard(samples, workers, w int) (int, int) {
+	size, rem := samples/workers, samples%workers
+	// the first rem shards take one extra sample
+	if w < rem {
+		from := w * (size + 1)
+		return from, from + size + 1
+	}
+	from := rem*(size+1) + (w-rem)*size
+	return from, from + size
+}
+
+// parallel runs fn for every worker shard of the samples in a separate goroutine.
+// It waits for all the workers to finish and returns the error of the first failed worker.
+func parallel(samples, workers int, fn func(w, from, to int) error) error {
+	if workers == 1 {
+		return fn(0, 0, samples)
+	}
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		from, to := shard(samples, workers, w)
+		wg.Add(1)
+		go func(w, from, to int) {
+			defer wg.Done()
+			errs[w] = fn(w, from, to)
+		}(w, from, to)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forwardProp runs forward propagation of the input matrix through the whole network.
+// Input rows are sharded across the configured number of workers.
+func (n *Network) forwardProp(c *config.TrainConfig, inMx *mat64.Dense) (*mat64.Dense, error) {
+	layers := n.Layers()
+	samples, _ := inMx.Dims()
+	workers := workerCount(c, samples)
+	caches, err := n.forwardShards(inMx, workers, n.dropMasks(samples))
+	if err != nil {
+		return nil, err
+	}
+	if workers == 1 {
+		// forwardShards returns *mat64.Dense layer outputs
+		return caches[0].outs[len(layers)-1].(*mat64.Dense), nil
+	}
+	// stack the worker outputs
+	_, outCols := caches[0].outs[len(layers)-1].Dims()
+	outMx := mat64.NewDense(samples, outCols, nil)
+	for w, cache := range caches {
+		from, to := shard(samples, workers, w)
+		outMx.View(from, 0, to-from, outCols).(*mat64.Dense).Copy(cache.outs[len(layers)-1])
+	}
+	return outMx, nil
+}
@@ -0,0 +1,65 @@
+package neural
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+func TestShard(t *testing.T) {
+	assert := assert.New(t)
+	for _, samples := range []int{1, 5, 7, 100} {
+		for workers := 1; workers <= samples && workers <= 8; workers++ {
+			// shards are contiguous and cover all samples
+			next := 0
+			for w := 0; w < workers; w++ {
+				from, to := shard(samples, workers, w)
+				assert.Equal(next, from)
+				assert.True(to-from >= samples/workers)
+				assert.True(to-from <= samples/workers+1)
+				next = to
+			}
+			assert.Equal(samples, next)
+		}
+	}
+	c := &config.TrainConfig{}
+	assert.Equal(1, workerCount(c, 5))
+	c.Workers = 4
+	assert.Equal(4, workerCount(c, 5))
+	assert.Equal(2, workerCount(c, 2))
+	assert.Equal(1, workerCount(c, 0))
+}
+
+func TestParallelGradient(t *testing.T) {
+	assert := assert.New(t)
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	c := &config.TrainConfig{Cost: "loglike", Lambda: 1.0}
+	cost, err := n.getCost(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	grad, err := n.getGradient(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	for _, workers := range []int{2, 3, 5, 16} {
+		c.Workers = workers
+		parCost, err := n.getCost(c, nil, inMx, labelsVec)
+		assert.NoError(err)
+		assert.InDelta(cost, parCost, 1e-12)
+		parGrad, err := n.getGradient(c, nil, inMx, labelsVec)
+		assert.NoError(err)
+		assert.Len(parGrad, len(grad))
+		for i := range grad {
+			assert.InDelta(grad[i], parGrad[i], 1e-12)
+		}
+		// results are deterministic for a fixed number of workers
+		for i := 0; i < 5; i++ {
+			again, err := n.getGradient(c, nil, inMx, labelsVec)
+			assert.NoError(err)
+			assert.Equal(parGrad, again)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/matrix"
+)
+
+// RegressionMetrics contains metrics of PREDICT network outputs pooled over all network outputs
+type RegressionMetrics struct {
+	// RMSE is root mean squared error
+	RMSE float64
+	// MAE is mean absolute error
+	MAE float64
+	// R2 is coefficient of determination: the fraction of target variance explained by the network.
+	// Variance of every target is measured around its own mean
+	R2 float64
+}
+
+// String implements Stringer interface for pretty printing
+func (m RegressionMetrics) String() string {
+	return fmt.Sprintf("RMSE: %f, MAE: %f, R2: %f", m.RMSE, m.MAE, m.R2)
+}
+
+// targets returns the expected network output with given number of outputs for the supplied labels.
+// Labels of CLASS network are encoded into one-of-N matrix mixed with uniform distribution
+// over all classes per smoothing factor. Labels of PREDICT network are the expected outputs
+// and labels of MULTILABEL network are binary indicators of every output label.
+// It fails with error if the labels don't match the network output.
+func (n *Network) targets(labels mat64.Matrix, outputs int, smoothing float64) (*mat64.Dense, error) {
+	if n.task != CLASS {
+		if _, cols := labels.Dims(); cols != outputs {
+			return nil, fmt.Errorf("Dimension mismatch. Targets: %d, Outputs: %d\n", cols, outputs)
+		}
+		if n.task == MULTILABEL {
+			if err := checkIndicators(labels); err != nil {
+				return nil, err
+			}
+		}
+		return mat64.DenseCopyOf(labels), nil
+	}
+	labelsVec, err := classLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+	// labelsMx is one-of-N matrix for each output label
+	// i.e. 3rd label would be: 0 0 1 0 0 etc.
+	labelsMx, err := matrix.MakeLabelsMx(labelsVec, outputs)
+	if err != nil {
+		return nil, err
+	}
+	smoothLabels(labelsMx, smoothing)
+	return labelsMx, nil
+}
+
+// classLabels returns class labels stored in a single column of labels matrix as a vector
+// or fails with error if labels matrix has more than one column
+func classLabels(labels mat64.Matrix) (*mat64.Vector, error) {
+	if labelsVec, ok := labels.(*mat64.Vector); ok {
+		return labelsVec, nil
+	}
+	rows, cols := labels.Dims()
+	if cols != 1 {
+		return nil, fmt.Errorf("Class labels must be stored in a single column: %d\n", cols)
+	}
+	return mat64.NewVector(rows, mat64.Col(nil, 0, labels)), nil
+}
+
+// Predict runs forward propagation of PREDICT network on the supplied input.
+// It returns a matrix that contains predicted values of all network outputs per row.
+// It returns error if the network does not predict or if the forward propagation fails.
+func (n *Network) Predict(inMx mat64.Matrix) (*mat64.Dense, error) {
+	if inMx == nil {
+		return nil, fmt.Errorf("Can't predict %v\n", inMx)
+	}
+	if n.task != PREDICT {
+		return nil, fmt.Errorf("Prediction not supported by %s task\n", n.task)
+	}
+	return n.infer(inMx)
+}
+
+// Evaluate runs PREDICT network on the supplied data set and compares its outputs with the targets.
+// It returns regression metrics of the predictions or error. R2 is undefined if the targets are constant.
+func (n *Network) Evaluate(inMx *mat64.Dense, targets mat64.Matrix) (*RegressionMetrics, error) {
+	// data set can't be nil
+	if inMx == nil || targets == nil {
+		return nil, fmt.Errorf("Cant evaluate data set. In: %v, Out: %v\n", inMx, targets)
+	}
+	outMx, err := n.Predict(inMx)
+	if err != nil {
+		return nil, err
+	}
+	rows, cols := outMx.Dims()
+	if tRows, tCols := targets.Dims(); tRows != rows || tCols != cols {
+		return nil, fmt.Errorf("Dimension mismatch. Targets: %dx%d, Outputs: %dx%d\n", tRows, tCols, rows, cols)
+	}
+	var sqErr, absErr, variance float64
+	for j := 0; j < cols; j++ {
+		mean := 0.0
+		for i := 0; i < rows; i++ {
+			mean += targets.At(i, j) / float64(rows)
+		}
+		for i := 0; i < rows; i++ {
+			e := outMx.At(i, j) - targets.At(i, j)
+			sqErr += e * e
+			absErr += math.Abs(e)
+			d := targets.At(i, j) - mean
+			variance += d * d
+		}
+	}
+	count := float64(rows * cols)
+	return &RegressionMetrics{
+		RMSE: math.Sqrt(sqErr / count),
+		MAE:  absErr / count,
+		R2:   1 - sqErr/variance,
+	}, nil
+}
@@ -0,0 +1,142 @@
+package neural
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// predictConfig returns configuration of a small PREDICT network with 2 outputs
+func predictConfig(output string) *config.NetConfig {
+	return &config.NetConfig{
+		Kind: "feedfwd",
+		Task: "predict",
+		Seed: 5,
+		Arch: &config.NetArch{
+			Input: &config.LayerConfig{Kind: "input", Size: 3},
+			Hidden: []*config.LayerConfig{
+				{Kind: "hidden", Size: 8, NeurFn: &config.NeuronConfig{Activation: "tanh"}},
+			},
+			Output: &config.LayerConfig{Kind: "output", Size: 2, NeurFn: &config.NeuronConfig{Activation: output}},
+		},
+	}
+}
+
+// predictData returns random inputs generated by seeded generator and their targets:
+// linear and nonlinear function of the inputs
+func predictData(samples int, seed int64) (*mat64.Dense, *mat64.Dense) {
+	rng := rand.New(rand.NewSource(seed))
+	in := mat64.NewDense(samples, 3, nil)
+	targets := mat64.NewDense(samples, 2, nil)
+	for i := 0; i < samples; i++ {
+		a, b, c := 2*rng.Float64()-1, 2*rng.Float64()-1, 2*rng.Float64()-1
+		in.SetRow(i, []float64{a, b, c})
+		targets.SetRow(i, []float64{2*a - b + 0.5*c, a * b})
+	}
+	return in, targets
+}
+
+func TestPredictNetwork(t *testing.T) {
+	assert := assert.New(t)
+	n, err := NewNetwork(predictConfig("identity"))
+	assert.NoError(err)
+	assert.Equal(PREDICT, n.Task())
+	in, targets := predictData(20, 1)
+	// gradient of regression costs matches the numerical one
+	for _, cost := range []string{"mse", "mae", "huber"} {
+		c := &config.TrainConfig{Cost: cost, Lambda: 0.5, Huber: 0.2}
+		checks, err := n.CheckGradient(c, in, targets, 10, 20)
+		assert.NoError(err)
+		assert.Len(checks, 2)
+		for _, check := range checks {
+			assert.True(check.RelError < 1e-6, cost+": "+check.String())
+		}
+	}
+	// predictions and their metrics
+	outMx, err := n.Predict(in)
+	assert.NoError(err)
+	rows, cols := outMx.Dims()
+	assert.Equal(20, rows)
+	assert.Equal(2, cols)
+	metrics, err := n.Evaluate(in, outMx)
+	assert.NoError(err)
+	assert.Equal(RegressionMetrics{RMSE: 0, MAE: 0, R2: 1}, *metrics)
+	shifted := new(mat64.Dense)
+	shifted.Apply(func(i, j int, v float64) float64 { return v + 0.5 }, outMx)
+	metrics, err = n.Evaluate(in, shifted)
+	assert.NoError(err)
+	assert.InDelta(0.5, metrics.RMSE, 1e-12)
+	assert.InDelta(0.5, metrics.MAE, 1e-12)
+	// targets must match network outputs
+	_, err = n.Evaluate(in, mat64.NewDense(20, 1, nil))
+	assert.Error(err)
+	_, err = n.getCost(&config.TrainConfig{Cost: "mse"}, nil, in, mat64.NewDense(20, 3, nil))
+	assert.Error(err)
+	// classification is not supported
+	_, err = n.Classify(in)
+	assert.Error(err)
+	_, err = n.Validate(in, targets)
+	assert.Error(err)
+	// classification network does not predict
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	classNet, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	assert.Equal(CLASS, classNet.Task())
+	_, err = classNet.Predict(inMx)
+	assert.Error(err)
+	_, err = classNet.CheckGradient(&config.TrainConfig{Cost: "mse"}, inMx, labelsVec, 3, 10)
+	assert.Error(err)
+	// regression requires linear outputs
+	_, err = NewNetwork(predictConfig("sigmoid"))
+	assert.Error(err)
+	netConf := predictConfig("identity")
+	netConf.Task = "foo"
+	_, err = NewNetwork(netConf)
+	assert.Error(err)
+}
+
+func TestTrainPredict(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	n, err := NewNetwork(predictConfig("identity"))
+	assert.NoError(err)
+	in, targets := predictData(100, 1)
+	valIn, valTargets := predictData(20, 2)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "huber",
+		Learningrate: 0.01,
+		Epochs:       20,
+		Optimize:     &config.OptimConfig{Method: "adam", Batchsize: 10},
+		EarlyStop:    &config.EarlyStopConfig{Metric: "cost", Patience: 5},
+	}
+	before, err := n.Evaluate(valIn, valTargets)
+	assert.NoError(err)
+	rec := &recorder{}
+	assert.NoError(n.Train(context.Background(), c, in, targets, valIn, valTargets, "", rec))
+	// validation reports regression metrics instead of accuracy
+	last := rec.metrics[len(rec.metrics)-1]
+	assert.True(last.Validated)
+	assert.NotNil(last.Regression)
+	after, err := n.Evaluate(valIn, valTargets)
+	assert.NoError(err)
+	assert.True(after.RMSE < before.RMSE)
+	assert.True(after.R2 > 0.9, after.String())
+	// training configuration must suit the task
+	for _, bad := range []*config.TrainConfig{
+		{Kind: "sgd", Cost: "loglike", Optimize: c.Optimize},
+		{Kind: "sgd", Cost: "mse", Smoothing: 0.1, Optimize: c.Optimize},
+		{Kind: "sgd", Cost: "mse", Classes: &config.ClassConfig{Weights: []float64{1, 2}}, Optimize: c.Optimize},
+		{Kind: "sgd", Cost: "mse", EarlyStop: &config.EarlyStopConfig{Metric: "accuracy"}, Optimize: c.Optimize},
+		{Kind: "sgd", Cost: "huber", Huber: -1.0, Optimize: c.Optimize},
+	} {
+		assert.Error(n.Train(context.Background(), bad, in, targets, valIn, valTargets, "", nil))
+	}
+}
@@ -0,0 +1,111 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// Regularizer is a penalty of network weights added to the training cost
+type Regularizer interface {
+	// Penalty returns the penalty of the supplied weights
+	Penalty(weightsMx mat64.Matrix) float64
+	// Grad returns the gradient of the penalty of a single weight.
+	// It can be applied to weights matrix element-wise
+	Grad(i, j int, w float64) float64
+}
+
+// regularizers maps regularizer names to their constructors
+var regularizers = map[string]func(*config.TrainConfig) Regularizer{
+	"":           func(c *config.TrainConfig) Regularizer { return L2{} },
+	"l2":         func(c *config.TrainConfig) Regularizer { return L2{} },
+	"l1":         func(c *config.TrainConfig) Regularizer { return L1{} },
+	"elasticnet": func(c *config.TrainConfig) Regularizer { return ElasticNet{Ratio: c.L1ratio} },
+}
+
+// L2 implements Regularizer interface
+type L2 struct{}
+
+// Penalty implements L2 penalty: sum(w.^2)/2
+func (r L2) Penalty(weightsMx mat64.Matrix) float64 {
+	sqrMx := new(mat64.Dense)
+	sqrMx.MulElem(weightsMx, weightsMx)
+	return mat64.Sum(sqrMx) / 2
+}
+
+// Grad implements Regularizer interface
+func (r L2) Grad(i, j int, w float64) float64 {
+	return w
+}
+
+// L1 implements Regularizer interface
+type L1 struct{}
+
+// Penalty implements L1 penalty: sum(abs(w))
+func (r L1) Penalty(weightsMx mat64.Matrix) float64 {
+	rows, cols := weightsMx.Dims()
+	penalty := 0.0
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			penalty += math.Abs(weightsMx.At(i, j))
+		}
+	}
+	return penalty
+}
+
+// Grad implements Regularizer interface. Subgradient of zero weight is zero
+func (r L1) Grad(i, j int, w float64) float64 {
+	switch {
+	case w > 0:
+		return 1.0
+	case w < 0:
+		return -1.0
+	}
+	return 0.0
+}
+
+// ElasticNet implements Regularizer interface
+type ElasticNet struct {
+	// Ratio is the ratio of L1 penalty
+	Ratio float64
+}
+
+// Penalty implements elastic net penalty: Ratio*L1 + (1-Ratio)*L2
+func (r ElasticNet) Penalty(weightsMx mat64.Matrix) float64 {
+	return r.Ratio*L1{}.Penalty(weightsMx) + (1-r.Ratio)*L2{}.Penalty(weightsMx)
+}
+
+// Grad implements Regularizer interface
+func (r ElasticNet) Grad(i, j int, w float64) float64 {
+	return r.Ratio*L1{}.Grad(i, j, w) + (1-r.Ratio)*w
+}
+
+// layerLambda returns regularization parameter of the network layer with the given index
+func layerLambda(c *config.TrainConfig, layer int) float64 {
+	if lambda, ok := c.Lambdas[layer]; ok {
+		return lambda
+	}
+	return c.Lambda
+}
+
+// validateRegularizer validates regularization configuration
+func validateRegularizer(c *config.TrainConfig) error {
+	// Incorrect lambda supplied
+	if c.Lambda < 0 {
+		return fmt.Errorf("Incorrect regularizer supplied: %f\n", c.Lambda)
+	}
+	if _, ok := regularizers[c.Regularizer]; !ok {
+		return fmt.Errorf("Unsupported regularizer: %s\n", c.Regularizer)
+	}
+	if c.L1ratio < 0 || c.L1ratio > 1 {
+		return fmt.Errorf("Incorrect L1 ratio supplied: %f\n", c.L1ratio)
+	}
+	for layer, lambda := range c.Lambdas {
+		if layer < 1 || lambda < 0 {
+			return fmt.Errorf("Incorrect lambda of layer %d: %f\n", layer, lambda)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package neural
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+func TestRegularizers(t *testing.T) {
+	assert := assert.New(t)
+	weightsMx := mat64.NewDense(2, 2, []float64{1.0, -2.0, 0.0, 3.0})
+	assert.Equal(7.0, L2{}.Penalty(weightsMx))
+	assert.Equal(6.0, L1{}.Penalty(weightsMx))
+	assert.Equal(0.25*6.0+0.75*7.0, ElasticNet{Ratio: 0.25}.Penalty(weightsMx))
+	gradMx := new(mat64.Dense)
+	gradMx.Apply(L1{}.Grad, weightsMx)
+	assert.Equal([]float64{1.0, -1.0, 0.0, 1.0}, gradMx.RawMatrix().Data)
+	gradMx.Apply(ElasticNet{Ratio: 0.5}.Grad, weightsMx)
+	assert.Equal([]float64{1.0, -1.5, 0.0, 2.0}, gradMx.RawMatrix().Data)
+	// incorrect configuration
+	c := &config.TrainConfig{Regularizer: "l1", Lambda: 1.0}
+	assert.NoError(validateRegularizer(c))
+	c.Regularizer = "foobar"
+	assert.Error(validateRegularizer(c))
+	c.Regularizer = "elasticnet"
+	c.L1ratio = 2.0
+	assert.Error(validateRegularizer(c))
+	c.L1ratio = 0.5
+	c.Lambdas = map[int]float64{0: 1.0}
+	assert.Error(validateRegularizer(c))
+	c.Lambdas = map[int]float64{1: -1.0}
+	assert.Error(validateRegularizer(c))
+}
+
+func TestLayerLambda(t *testing.T) {
+	assert := assert.New(t)
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	for _, regularizer := range []string{"l2", "l1", "elasticnet"} {
+		c := &config.TrainConfig{Cost: "loglike", Regularizer: regularizer, L1ratio: 0.3, Lambda: 2.0}
+		// penalty gradient matches the penalty
+		checks, err := n.CheckGradient(c, inMx, labelsVec, 5, 100)
+		assert.NoError(err)
+		for _, check := range checks {
+			assert.True(check.RelError < 1e-6, regularizer, check.String())
+		}
+		// output layer is regularized differently from the hidden layer
+		c.Lambdas = map[int]float64{2: 0.0}
+		checks, err = n.CheckGradient(c, inMx, labelsVec, 5, 100)
+		assert.NoError(err)
+		for _, check := range checks {
+			assert.True(check.RelError < 1e-6, regularizer, check.String())
+		}
+		grad, err := n.getGradient(c, nil, inMx, labelsVec)
+		assert.NoError(err)
+		unreg, err := n.getGradient(&config.TrainConfig{Cost: "loglike"}, nil, inMx, labelsVec)
+		assert.NoError(err)
+		// the first 25 elements are hidden layer weights
+		assert.NotEqual(unreg[:25], grad[:25])
+		assert.Equal(unreg[25:], grad[25:])
+	}
+	// lambda of nonexistent layer
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "loglike",
+		Learningrate: 0.1,
+		Lambdas:      map[int]float64{3: 1.0},
+		Optimize:     &config.OptimConfig{Method: "sgd", Batchsize: 2},
+	}
+	assert.Error(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+}
@@ -0,0 +1,171 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// Schedule computes learning rate during training
+type Schedule interface {
+	// Rate returns learning rate for the supplied base learning rate and training progress
+	// measured in (fractional) epochs since the beginning of the training
+	Rate(base, epoch float64) float64
+}
+
+// schedules maps learning rate schedule names to their constructors
+var schedules = map[string]func(*config.ScheduleConfig) Schedule{
+	"constant": func(c *config.ScheduleConfig) Schedule {
+		return ConstantSchedule{}
+	},
+	"step": func(c *config.ScheduleConfig) Schedule {
+		return StepSchedule{Every: c.Every, Gamma: c.Gamma}
+	},
+	"exp": func(c *config.ScheduleConfig) Schedule {
+		return ExpSchedule{Gamma: c.Gamma}
+	},
+	"cosine": func(c *config.ScheduleConfig) Schedule {
+		return CosineSchedule{Period: c.Period, Mult: c.Mult, Minrate: c.Minrate}
+	},
+	"onecycle": func(c *config.ScheduleConfig) Schedule {
+		return OneCycleSchedule{Cycle: c.Cycle, Maxrate: c.Maxrate, Minrate: c.Minrate}
+	},
+}
+
+// ConstantSchedule keeps the learning rate constant
+type ConstantSchedule struct{}
+
+// Rate implements Schedule interface
+func (s ConstantSchedule) Rate(base, epoch float64) float64 {
+	return base
+}
+
+// StepSchedule decays learning rate by Gamma every Every epochs
+// lr = base * gamma^floor(epoch/every)
+type StepSchedule struct {
+	Every float64
+	Gamma float64
+}
+
+// Rate implements Schedule interface
+func (s StepSchedule) Rate(base, epoch float64) float64 {
+	return base * math.Pow(s.Gamma, math.Floor(epoch/s.Every))
+}
+
+// ExpSchedule decays learning rate exponentially
+// lr = base * gamma^epoch
+type ExpSchedule struct {
+	Gamma float64
+}
+
+// Rate implements Schedule interface
+func (s ExpSchedule) Rate(base, epoch float64) float64 {
+	return base * math.Pow(s.Gamma, epoch)
+}
+
+// CosineSchedule implements cosine annealing with warm restarts.
+// Learning rate is annealed from base to Minrate over Period epochs and then restarted.
+// Every restart the period is multiplied by Mult.
+// lr = minrate + 0.5 * (base - minrate) * (1 + cos(pi * t / period))
+type CosineSchedule struct {
+	Period  float64
+	Mult    float64
+	Minrate float64
+}
+
+// Rate implements Schedule interface
+func (s CosineSchedule) Rate(base, epoch float64) float64 {
+	period := s.Period
+	mult := withDefault(s.Mult, 1.0)
+	// find the position within the current restart period
+	for epoch >= period {
+		epoch -= period
+		period *= mult
+	}
+	return s.Minrate + 0.5*(base-s.Minrate)*(1+math.Cos(math.Pi*epoch/period))
+}
+
+// OneCycleSchedule implements one-cycle learning rate policy.
+// Learning rate linearly rises from base to Maxrate over the first 45% of the cycle,
+// falls back to base over the next 45% and is annealed to Minrate in the rest of the cycle.
+// Learning rate stays at Minrate once the cycle is over.
+type OneCycleSchedule struct {
+	Cycle   float64
+	Maxrate float64
+	Minrate float64
+}
+
+// Rate implements Schedule interface
+func (s OneCycleSchedule) Rate(base, epoch float64) float64 {
+	pos := epoch / s.Cycle
+	switch {
+	case pos < 0.45:
+		return base + (s.Maxrate-base)*pos/0.45
+	case pos < 0.9:
+		return s.Maxrate - (s.Maxrate-base)*(pos-0.45)/0.45
+	case pos < 1.0:
+		return base - (base-s.Minrate)*(pos-0.9)/0.1
+	}
+	return s.Minrate
+}
+
+// learningRate returns learning rate of the given mini-batch training step.
+// It applies linear warmup on top of the configured learning rate schedule.
+func learningRate(c *config.TrainConfig, step, batches int) float64 {
+	if c.Schedule == nil {
+		return c.Learningrate
+	}
+	kind := c.Schedule.Kind
+	if kind == "" {
+		kind = "constant"
+	}
+	// training progress in epochs
+	epoch := float64(step) / float64(batches)
+	lr := schedules[kind](c.Schedule).Rate(c.Learningrate, epoch)
+	// warmup reaches the scheduled learning rate in the last warmup step
+	warmup := float64(step+1) / (c.Schedule.Warmup * float64(batches))
+	if c.Schedule.Warmup > 0 && warmup < 1 {
+		lr *= warmup
+	}
+	return lr
+}
+
+// validateSchedule validates learning rate schedule configuration
+func validateSchedule(c *config.TrainConfig) error {
+	s := c.Schedule
+	if s == nil {
+		return nil
+	}
+	kind := s.Kind
+	if kind == "" {
+		kind = "constant"
+	}
+	if _, ok := schedules[kind]; !ok {
+		return fmt.Errorf("Unsupported learning rate schedule: %s\n", s.Kind)
+	}
+	if s.Warmup < 0 || s.Minrate < 0 {
+		return fmt.Errorf("Incorrect schedule parameters: warmup %f, minrate %f\n", s.Warmup, s.Minrate)
+	}
+	switch kind {
+	case "step":
+		if s.Every <= 0 {
+			return fmt.Errorf("Incorrect step decay period: %f\n", s.Every)
+		}
+		fallthrough
+	case "exp":
+		if s.Gamma <= 0 || s.Gamma > 1 {
+			return fmt.Errorf("Incorrect decay rate: %f\n", s.Gamma)
+		}
+	case "cosine":
+		if s.Period <= 0 || (s.Mult != 0 && s.Mult < 1) {
+			return fmt.Errorf("Incorrect cosine period: %f, multiplier: %f\n", s.Period, s.Mult)
+		}
+	case "onecycle":
+		// cycle length must not depend on the number of epochs of a resumed run
+		if s.Maxrate < c.Learningrate || s.Cycle <= 0 {
+			return fmt.Errorf("Incorrect one-cycle parameters: maxrate %f, cycle %f\n", s.Maxrate, s.Cycle)
+		}
+	}
+	return nil
+}
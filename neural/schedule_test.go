@@ -0,0 +1,120 @@
+package neural
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+func TestSchedules(t *testing.T) {
+	assert := assert.New(t)
+	// step decay
+	step := StepSchedule{Every: 2, Gamma: 0.5}
+	assert.InDelta(step.Rate(1.0, 1.9), 1.0, 1e-9)
+	assert.InDelta(step.Rate(1.0, 2.0), 0.5, 1e-9)
+	assert.InDelta(step.Rate(1.0, 4.5), 0.25, 1e-9)
+	// exponential decay
+	exp := ExpSchedule{Gamma: 0.5}
+	assert.InDelta(exp.Rate(1.0, 3.0), 0.125, 1e-9)
+	// cosine annealing with restarts
+	cos := CosineSchedule{Period: 2, Mult: 2, Minrate: 0.1}
+	assert.InDelta(cos.Rate(1.0, 0), 1.0, 1e-9)
+	assert.InDelta(cos.Rate(1.0, 1.0), 0.55, 1e-9)
+	// first restart after 2 epochs, second period is 4 epochs long
+	assert.InDelta(cos.Rate(1.0, 2.0), 1.0, 1e-9)
+	assert.InDelta(cos.Rate(1.0, 4.0), 0.55, 1e-9)
+	assert.InDelta(cos.Rate(1.0, 6.0), 1.0, 1e-9)
+	// one-cycle
+	one := OneCycleSchedule{Cycle: 10, Maxrate: 1.0, Minrate: 0.01}
+	assert.InDelta(one.Rate(0.1, 0), 0.1, 1e-9)
+	assert.InDelta(one.Rate(0.1, 4.5), 1.0, 1e-9)
+	assert.InDelta(one.Rate(0.1, 9.0), 0.1, 1e-9)
+	assert.InDelta(one.Rate(0.1, 12.0), 0.01, 1e-9)
+}
+
+func TestLearningRate(t *testing.T) {
+	assert := assert.New(t)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "xentropy",
+		Learningrate: 0.1,
+		Epochs:       10,
+		Optimize: &config.OptimConfig{
+			Method:    "sgd",
+			Batchsize: 10,
+		},
+	}
+	// no schedule keeps learning rate constant
+	assert.Equal(learningRate(c, 100, 10), 0.1)
+	// linear warmup over 2 epochs of 10 mini-batches
+	c.Schedule = &config.ScheduleConfig{Kind: "step", Every: 5, Gamma: 0.1, Warmup: 2}
+	assert.NoError(ValidateTrainConfig(c))
+	assert.InDelta(learningRate(c, 0, 10), 0.005, 1e-9)
+	assert.InDelta(learningRate(c, 9, 10), 0.05, 1e-9)
+	assert.InDelta(learningRate(c, 19, 10), 0.1, 1e-9)
+	assert.InDelta(learningRate(c, 49, 10), 0.1, 1e-9)
+	assert.InDelta(learningRate(c, 50, 10), 0.01, 1e-9)
+	// unsupported schedule
+	c.Schedule.Kind = "foobar"
+	assert.Error(ValidateTrainConfig(c))
+	// step decay requires decay period
+	c.Schedule = &config.ScheduleConfig{Kind: "step", Gamma: 0.1}
+	assert.Error(ValidateTrainConfig(c))
+	// cosine annealing requires period
+	c.Schedule = &config.ScheduleConfig{Kind: "cosine"}
+	assert.Error(ValidateTrainConfig(c))
+	// one-cycle maximum learning rate can't be smaller than base learning rate
+	c.Schedule = &config.ScheduleConfig{Kind: "onecycle", Maxrate: 0.01, Cycle: 10}
+	assert.Error(ValidateTrainConfig(c))
+	// one-cycle requires cycle length
+	c.Schedule = &config.ScheduleConfig{Kind: "onecycle", Maxrate: 1.0}
+	assert.Error(ValidateTrainConfig(c))
+	c.Schedule.Cycle = 10
+	assert.NoError(ValidateTrainConfig(c))
+	// schedules are only supported by mini-batch training
+	c.Kind = "backprop"
+	c.Optimize.Method = "bfgs"
+	c.Optimize.Iterations = 10
+	c.Schedule = &config.ScheduleConfig{Kind: "exp", Gamma: 0.9}
+	assert.Error(ValidateTrainConfig(c))
+}
+
+func TestScheduleResume(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	// create new network
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "xentropy",
+		Learningrate: 0.1,
+		Epochs:       2,
+		Optimize: &config.OptimConfig{
+			Method:    "sgd",
+			Batchsize: 2,
+		},
+		Schedule: &config.ScheduleConfig{Kind: "exp", Gamma: 0.5},
+	}
+	assert.NoError(n.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+	assert.Equal(n.Epoch(), 2)
+	// 5 samples in batches of 2 is 3 steps per epoch
+	assert.InDelta(n.LearningRate(), 0.1*math.Pow(0.5, 5.0/3.0), 1e-9)
+	// resumed network continues the schedule
+	resumed, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	assert.NoError(LoadFromFile(resumed))
+	assert.Equal(resumed.Epoch(), 2)
+	assert.Equal(resumed.step, 6)
+	assert.InDelta(resumed.LearningRate(), n.LearningRate(), 1e-6)
+	assert.NoError(resumed.Train(context.Background(), c, inMx, labelsVec, nil, nil, "", nil))
+	assert.Equal(resumed.Epoch(), 4)
+	assert.InDelta(resumed.LearningRate(), 0.1*math.Pow(0.5, 11.0/3.0), 1e-9)
+}
@@ -0,0 +1,212 @@
+package neural
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+	"github.com/vstoianovici/nngoclassify/pkg/matrix"
+)
+
+// Optimizer is a first order optimization algorithm used by mini-batch training
+type Optimizer interface {
+	// Update updates weights of the supplied layers using their gradient matrices and learning rate.
+	// Any per parameter state the optimizer needs is kept alongside the layer weights.
+	Update(layers []*Layer, grads []*mat64.Dense, lr float64)
+}
+
+// firstOrder maps first order optimization algorithm names to their constructors
+var firstOrder = map[string]func(*config.OptimConfig) Optimizer{
+	"sgd": func(c *config.OptimConfig) Optimizer {
+		return &SGD{Momentum: c.Momentum, Nesterov: c.Nesterov}
+	},
+	"adam": func(c *config.OptimConfig) Optimizer {
+		return &Adam{Beta1: valueOr(c.Beta1, defaultBeta1), Beta2: valueOr(c.Beta2, defaultBeta2), Epsilon: c.Epsilon}
+	},
+	"adamw": func(c *config.OptimConfig) Optimizer {
+		return &Adam{Beta1: valueOr(c.Beta1, defaultBeta1), Beta2: valueOr(c.Beta2, defaultBeta2),
+			Epsilon: c.Epsilon, WeightDecay: c.Weightdecay}
+	},
+	"rmsprop": func(c *config.OptimConfig) Optimizer {
+		return &RMSProp{Rho: valueOr(c.Rho, defaultRho), Epsilon: c.Epsilon}
+	},
+	"adagrad": func(c *config.OptimConfig) Optimizer {
+		return &Adagrad{Epsilon: c.Epsilon}
+	},
+}
+
+// SGD implements stochastic gradient descent with optional classical or Nesterov momentum
+type SGD struct {
+	// Momentum is momentum coefficient. Zero disables momentum
+	Momentum float64
+	// Nesterov enables Nesterov accelerated gradient
+	Nesterov bool
+}
+
+// Update implements Optimizer interface.
+// v = momentum * v - lr * grad
+// w = w + v                          (classical)
+// w = w + momentum * v - lr * grad   (Nesterov)
+func (s *SGD) Update(layers []*Layer, grads []*mat64.Dense, lr float64) {
+	for i, layer := range layers {
+		stepMx := new(mat64.Dense)
+		stepMx.Scale(-lr, grads[i])
+		if s.Momentum > 0 {
+			velocity := layer.optimState(&layer.velocity)
+			velocity.Scale(s.Momentum, velocity)
+			velocity.Add(velocity, stepMx)
+			if s.Nesterov {
+				nMx := new(mat64.Dense)
+				nMx.Scale(s.Momentum, velocity)
+				stepMx.Add(stepMx, nMx)
+			} else {
+				stepMx.Clone(velocity)
+			}
+		}
+		layer.weights.Add(layer.weights, stepMx)
+	}
+}
+
+// validateMiniBatchConfig validates mini-batch training configuration
+func validateMiniBatchConfig(c *config.TrainConfig) error {
+	// if the optimization method is not supported
+	if _, ok := firstOrder[c.Optimize.Method]; !ok {
+		return fmt.Errorf("Unsupported mini-batch optimization method: %s\n", c.Optimize.Method)
+	}
+	// learning rate must be positive
+	if c.Learningrate <= 0 {
+		return fmt.Errorf("Incorrect learning rate: %f\n", c.Learningrate)
+	}
+	// batch size must be positive
+	if c.Optimize.Batchsize <= 0 {
+		return fmt.Errorf("Incorrect batch size: %d\n", c.Optimize.Batchsize)
+	}
+	// momentum must be in [0,1)
+	if c.Optimize.Momentum < 0 || c.Optimize.Momentum >= 1 {
+		return fmt.Errorf("Incorrect momentum: %f\n", c.Optimize.Momentum)
+	}
+	// decay rates must be in [0,1)
+	for name, rate := range map[string]*float64{"beta1": c.Optimize.Beta1, "beta2": c.Optimize.Beta2, "rho": c.Optimize.Rho} {
+		if rate != nil && (*rate < 0 || *rate >= 1) {
+			return fmt.Errorf("Incorrect %s decay rate: %f\n", name, *rate)
+		}
+	}
+	// epsilon can't be negative
+	if c.Optimize.Epsilon < 0 {
+		return fmt.Errorf("Incorrect epsilon: %f\n", c.Optimize.Epsilon)
+	}
+	// decoupled weight decay is only supported by adamw
+	if c.Optimize.Weightdecay < 0 || (c.Optimize.Weightdecay > 0 && c.Optimize.Method != "adamw") {
+		return fmt.Errorf("Incorrect weight decay for %s: %f\n", c.Optimize.Method, c.Optimize.Weightdecay)
+	}
+	// full batch options are not used by first order optimizers
+	if c.Optimize.Linesearch != "" || c.Optimize.Stepsize != "" || c.Optimize.Step != 0 ||
+		c.Optimize.Variant != "" || c.Optimize.Store != 0 || c.Optimize.Simplex != 0 {
+		return fmt.Errorf("Full batch options not supported by %s\n", c.Optimize.Method)
+	}
+	return nil
+}
+
+// trainSGD runs a single mini-batch training epoch.
+// Training samples are shuffled at the beginning of the epoch and split into mini-batches.
+// If class balanced sampling is configured, minority classes are over-sampled before shuffling.
+// Network weights are updated after every mini-batch by the configured first order optimizer.
+// It stops before the next mini-batch once ctx is cancelled.
+func (n *Network) trainSGD(ctx context.Context, c *config.TrainConfig, inMx *mat64.Dense, labels mat64.Matrix, cb Callback) error {
+	// neuron outputs are dropped during training steps only
+	defer n.SetMode(n.mode)
+	n.SetMode(TRAINING)
+	optimizer := firstOrder[c.Optimize.Method](c.Optimize)
+	params := n.params()
+	samples, _ := inMx.Dims()
+	// shuffle the training samples
+	var perm []int
+	if c.Classes != nil && c.Classes.Balance == "sampling" {
+		// minority classes are over-sampled so the epoch is longer than the data set
+		perm = n.balancedPerm(labels)
+		samples = len(perm)
+	} else {
+		perm = n.rng.Perm(samples)
+	}
+	batchSize := c.Optimize.Batchsize
+	if batchSize > samples {
+		batchSize = samples
+	}
+	// number of mini-batches per epoch
+	batches := (samples + batchSize - 1) / batchSize
+	for from, iter := 0, 1; from < samples; from, iter = from+batchSize, iter+1 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		to := from + batchSize
+		if to > samples {
+			to = samples
+		}
+		batchMx, batchLabels := makeBatch(inMx, labels, perm[from:to])
+		if err := n.sgdStep(c, optimizer, params, batchMx, batchLabels, iter, batches, cb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sgdStep updates network parameters by the gradient of a single mini-batch and reports
+// the step to callback as iteration iter of an epoch with the given number of mini-batches.
+// Running statistics of batch normalized layers are updated with the mini-batch statistics.
+// If data augmentation is configured, mini-batch features are augmented in place.
+func (n *Network) sgdStep(c *config.TrainConfig, optimizer Optimizer, params []*Layer,
+	batchMx *mat64.Dense, batchLabels mat64.Matrix, iter, batches int, cb Callback) error {
+	// training images are augmented anew in every step
+	if err := n.augment(c.Augment, batchMx); err != nil {
+		return err
+	}
+	// the reported cost comes from the forward pass of the gradient
+	grad, cost, norms, err := n.gradient(c, nil, batchMx, batchLabels)
+	if err != nil {
+		return err
+	}
+	gradNorm, clippedNorm := clipGradient(c.Clip, grad)
+	cb.OnIteration(iter, cost, gradNorm, clippedNorm)
+	grads, err := layerGrads(params, grad)
+	if err != nil {
+		return err
+	}
+	n.rate = learningRate(c, n.step, batches)
+	optimizer.Update(params, grads, n.rate)
+	n.updateStats(norms, normMomentum)
+	n.step++
+	return nil
+}
+
+// makeBatch copies the rows of input and labels matrices selected by idx into a new mini-batch
+func makeBatch(inMx *mat64.Dense, labels mat64.Matrix, idx []int) (*mat64.Dense, *mat64.Dense) {
+	_, cols := inMx.Dims()
+	_, labelCols := labels.Dims()
+	batchMx := mat64.NewDense(len(idx), cols, nil)
+	batchLabels := mat64.NewDense(len(idx), labelCols, nil)
+	for i, row := range idx {
+		batchMx.SetRow(i, inMx.RawRowView(row))
+		batchLabels.SetRow(i, mat64.Row(nil, row, labels))
+	}
+	return batchMx, batchLabels
+}
+
+// layerGrads rolls the gradient slice returned by getGradient into per layer gradient matrices.
+// It fails with error if the gradient slice does not contain enough elements.
+func layerGrads(layers []*Layer, grad []float64) ([]*mat64.Dense, error) {
+	grads := make([]*mat64.Dense, len(layers))
+	acc := 0
+	for i, layer := range layers {
+		r, c := layer.Weights().Dims()
+		if len(grad)-acc < r*c {
+			return nil, fmt.Errorf("Insufficient number of gradients supplied %d\n", len(grad))
+		}
+		grads[i] = mat64.NewDense(r, c, nil)
+		if err := matrix.SetMx2Vec(grads[i], grad[acc:(acc+r*c)], false); err != nil {
+			return nil, err
+		}
+		acc += r * c
+	}
+	return grads, nil
+}
@@ -0,0 +1,132 @@
+package neural
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// BatchSource provides labeled training data in mini-batches so that the whole
+// data set does not have to be loaded into memory. It is implemented by dataset.Stream
+type BatchSource interface {
+	// Samples returns the number of samples in the source
+	Samples() int
+	// Reset rewinds the source to the first sample. Samples are shuffled by rng
+	// or read in order if rng is nil
+	Reset(rng *rand.Rand) error
+	// Next returns features and labels of at most size following samples or io.EOF
+	// once all the samples have been read
+	Next(size int) (*mat64.Dense, *mat64.Vector, error)
+}
+
+// TrainStream trains feedforward neural network by mini-batches read from the supplied data source.
+// It behaves like Train with sgd training kind, but it only keeps a single mini-batch in memory.
+// The data source is read twice every epoch: once to train the network and once to calculate
+// the training cost over the whole data set. Class balancing is not supported as it requires
+// the labels of the whole data set. Only CLASS networks can be trained by streaming since
+// the source scales all the features as image pixels and provides a single label per sample.
+// It returns error if either the training configuration is invalid ot the training fails.
+func (n *Network) TrainStream(ctx context.Context, c *config.TrainConfig, src BatchSource,
+	valInMx *mat64.Dense, valLabels mat64.Matrix, manifest string, cb Callback) error {
+	// validate the supplied configuration
+	if err := ValidateTrainConfig(c); err != nil {
+		return err
+	}
+	if err := n.validateTask(c); err != nil {
+		return err
+	}
+	// streamed samples are scaled images labeled by a single class
+	if n.task != CLASS {
+		return fmt.Errorf("Streamed training not supported by %s task\n", n.task)
+	}
+	// data source can't be nil or empty
+	if src == nil || src.Samples() == 0 {
+		return fmt.Errorf("Incorrect data source supplied: %v\n", src)
+	}
+	// full batch training requires the whole data set
+	if c.Kind != "sgd" {
+		return fmt.Errorf("Streamed training not supported by %s training\n", c.Kind)
+	}
+	if c.Classes != nil && c.Classes.Balance != "" {
+		return fmt.Errorf("Class %s balancing not supported by streamed training\n", c.Classes.Balance)
+	}
+	// only the configured class weights can be used
+	if err := n.setClassWeights(c.Classes, nil); err != nil {
+		return err
+	}
+	defer n.clearClassWeights()
+	epoch := func(cb Callback) error {
+		return n.trainStreamEpoch(ctx, c, src, cb)
+	}
+	cost := func() (float64, error) {
+		return n.streamCost(c, src)
+	}
+	return n.train(ctx, c, epoch, cost, valInMx, valLabels, manifest, cb)
+}
+
+// trainStreamEpoch runs a single mini-batch training epoch over the data source.
+// It stops before the next mini-batch once ctx is cancelled.
+func (n *Network) trainStreamEpoch(ctx context.Context, c *config.TrainConfig, src BatchSource, cb Callback) error {
+	// neuron outputs are dropped during training steps only
+	defer n.SetMode(n.mode)
+	n.SetMode(TRAINING)
+	optimizer := firstOrder[c.Optimize.Method](c.Optimize)
+	params := n.params()
+	samples := src.Samples()
+	batchSize := c.Optimize.Batchsize
+	if batchSize > samples {
+		batchSize = samples
+	}
+	// number of mini-batches per epoch
+	batches := (samples + batchSize - 1) / batchSize
+	// samples are shuffled by the network random generator
+	if err := src.Reset(n.rng); err != nil {
+		return err
+	}
+	for iter := 1; ; iter++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batchMx, batchVec, err := src.Next(batchSize)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := n.sgdStep(c, optimizer, params, batchMx, batchVec, iter, batches, cb); err != nil {
+			return err
+		}
+	}
+}
+
+// streamCost calculates the training cost over all samples of the data source.
+// The cost is accumulated mini-batch by mini-batch so it equals the cost of the whole data set.
+func (n *Network) streamCost(c *config.TrainConfig, src BatchSource) (float64, error) {
+	// samples are read in order so the network random generator is not consumed
+	if err := src.Reset(nil); err != nil {
+		return -1.0, err
+	}
+	cost, samples := 0.0, 0
+	for {
+		batchMx, batchVec, err := src.Next(c.Optimize.Batchsize)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return -1.0, err
+		}
+		batchCost, err := n.dataCost(c, batchMx, batchVec)
+		if err != nil {
+			return -1.0, err
+		}
+		rows, _ := batchMx.Dims()
+		cost += batchCost * float64(rows)
+		samples += rows
+	}
+	return cost/float64(samples) + n.penalty(c, samples), nil
+}
@@ -0,0 +1,116 @@
+package neural
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+	"github.com/vstoianovici/nngoclassify/pkg/config"
+)
+
+// memSource implements BatchSource over in-memory data set
+type memSource struct {
+	inMx      *mat64.Dense
+	labelsVec *mat64.Vector
+	perm      []int
+	resets    int
+}
+
+func (s *memSource) Samples() int {
+	return s.labelsVec.Len()
+}
+
+func (s *memSource) Reset(rng *rand.Rand) error {
+	s.resets++
+	if rng != nil {
+		s.perm = rng.Perm(s.Samples())
+		return nil
+	}
+	s.perm = make([]int, s.Samples())
+	for i := range s.perm {
+		s.perm[i] = i
+	}
+	return nil
+}
+
+func (s *memSource) Next(size int) (*mat64.Dense, *mat64.Vector, error) {
+	if len(s.perm) == 0 {
+		return nil, nil, io.EOF
+	}
+	if size > len(s.perm) {
+		size = len(s.perm)
+	}
+	idx := s.perm[:size]
+	s.perm = s.perm[size:]
+	batchMx, batchLabels := makeBatch(s.inMx, s.labelsVec, idx)
+	return batchMx, batchLabels.ColView(0), nil
+}
+
+func TestTrainStream(t *testing.T) {
+	assert := assert.New(t)
+	defer inTrainingDir(t)()
+	conf, err := config.New(filepath.Join(os.TempDir(), fileName))
+	assert.NoError(err)
+	n, err := NewNetwork(conf.Network)
+	assert.NoError(err)
+	c := &config.TrainConfig{
+		Kind:         "sgd",
+		Cost:         "loglike",
+		Lambda:       1.0,
+		Learningrate: 0.1,
+		Epochs:       2,
+		Optimize:     &config.OptimConfig{Method: "sgd", Batchsize: 2},
+	}
+	// training cost accumulated over mini-batches equals the cost of the whole data set
+	src := &memSource{inMx: inMx, labelsVec: labelsVec}
+	cost, err := n.streamCost(c, src)
+	assert.NoError(err)
+	expected, err := n.getCost(c, nil, inMx, labelsVec)
+	assert.NoError(err)
+	assert.InDelta(expected, cost, 1e-12)
+	// 5 samples in batches of 2 is 3 iterations per epoch
+	rec := &recorder{}
+	assert.NoError(n.TrainStream(context.Background(), c, src, inMx, labelsVec, "", rec))
+	assert.Len(rec.iters, 6)
+	assert.Len(rec.metrics, 2)
+	assert.True(rec.metrics[1].Cost < expected)
+	assert.True(rec.metrics[1].Validated)
+	// every epoch reads the source for training and for the cost
+	assert.Equal(5, src.resets)
+	assert.Equal(2, n.epoch)
+	// streamed training with the same seed is reproducible
+	conf.Network.Seed = 7
+	var weights []*mat64.Dense
+	for i := 0; i < 2; i++ {
+		n, err := NewNetwork(conf.Network)
+		assert.NoError(err)
+		src := &memSource{inMx: inMx, labelsVec: labelsVec}
+		assert.NoError(n.TrainStream(context.Background(), c, src, nil, nil, "", nil))
+		weights = append(weights, n.Layers()[1].Weights())
+	}
+	assert.True(mat64.Equal(weights[0], weights[1]))
+	// streamed training is only supported by mini-batch training
+	c.Kind = "backprop"
+	c.Optimize = &config.OptimConfig{Method: "bfgs", Iterations: 2}
+	assert.Error(n.TrainStream(context.Background(), c, src, nil, nil, "", nil))
+	c.Kind = "sgd"
+	c.Optimize = &config.OptimConfig{Method: "sgd", Batchsize: 2}
+	// class balancing requires the whole data set
+	c.Classes = &config.ClassConfig{Balance: "weights"}
+	assert.Error(n.TrainStream(context.Background(), c, src, nil, nil, "", nil))
+	c.Classes = nil
+	// empty data source
+	assert.Error(n.TrainStream(context.Background(), c, nil, nil, nil, "", nil))
+	empty := &memSource{inMx: inMx, labelsVec: mat64.NewVector(0, nil)}
+	assert.Error(n.TrainStream(context.Background(), c, empty, nil, nil, "", nil))
+	// only classification networks can be trained by streaming
+	predNet, err := NewNetwork(predictConfig("identity"))
+	assert.NoError(err)
+	c.Cost = "mse"
+	assert.Error(predNet.TrainStream(context.Background(), c, src, nil, nil, "", nil))
+}
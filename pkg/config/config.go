@@ -8,39 +8,140 @@ import (
 	"gopkg.in/yaml.v1"
 )
 
+// Hidden is a data structure used to decode hidden layers configuration. It is decoded
+// either from the compact form which configures all hidden layers at once or from a list
+// of hidden layer objects each configuring a single layer.
+type Hidden struct {
+	// Size contains sizes of all hidden layers
+	Size []int `yaml:"size"`
+	// Activation is neuron activation function
+	Activation string `yaml:"activation"`
+	// Param is activation function parameter
+	Param float64 `yaml:"param,omitempty"`
+	// Dropout contains dropout rates of all hidden layers
+	Dropout []float64 `yaml:"dropout,omitempty"`
+	// Batchnorm enables batch normalization of all hidden layers
+	Batchnorm bool `yaml:"batchnorm,omitempty"`
+	// Init contains weights initialization of all hidden layers
+	Init struct {
+		// Kind is weights initialization scheme
+		Kind string `yaml:"kind"`
+		// Value is the value of constant weights
+		Value float64 `yaml:"value,omitempty"`
+		// Bias is the initial value of bias weights
+		Bias float64 `yaml:"bias,omitempty"`
+	} `yaml:"init,omitempty"`
+	// Layers contains configuration of particular hidden layers decoded from the list form
+	Layers []HiddenLayer `yaml:"-"`
+	// err is error of decoding the hidden layers configuration
+	err error
+}
+
+// HiddenLayer is a data structure used to decode configuration of a single hidden layer
+type HiddenLayer struct {
+	// Type is hidden layer type: dense, conv, maxpool, avgpool, flatten. Default is dense
+	Type string `yaml:"type,omitempty"`
+	// Size is number of layer neurons
+	Size int `yaml:"size"`
+	// Filters is number of convolution filters
+	Filters int `yaml:"filters,omitempty"`
+	// Kernel is side of square convolution kernel or pooling window
+	Kernel int `yaml:"kernel,omitempty"`
+	// Stride is step of convolution kernel or pooling window
+	Stride int `yaml:"stride,omitempty"`
+	// Padding is number of zero pixels added to every side of input images
+	Padding int `yaml:"padding,omitempty"`
+	// Activation is neuron activation function
+	Activation string `yaml:"activation"`
+	// Param is activation function parameter
+	Param float64 `yaml:"param,omitempty"`
+	// Dropout is dropout rate of the layer
+	Dropout float64 `yaml:"dropout,omitempty"`
+	// Batchnorm enables batch normalization of the layer
+	Batchnorm bool `yaml:"batchnorm,omitempty"`
+	// Init contains weights initialization of the layer
+	Init struct {
+		// Kind is weights initialization scheme
+		Kind string `yaml:"kind"`
+		// Value is the value of constant weights
+		Value float64 `yaml:"value,omitempty"`
+		// Bias is the initial value of bias weights
+		Bias float64 `yaml:"bias,omitempty"`
+	} `yaml:"init,omitempty"`
+	// Lambda overrides regularization parameter of the layer
+	Lambda *float64 `yaml:"lambda,omitempty"`
+}
+
+// compactHidden decodes the compact form of hidden layers configuration
+type compactHidden Hidden
+
+// SetYAML implements yaml.Setter interface. It decodes hidden layers configuration
+// from either a mapping in compact form or a sequence of hidden layer objects.
+func (h *Hidden) SetYAML(tag string, value interface{}) bool {
+	if tag == "!!null" {
+		return true
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		h.err = err
+		return false
+	}
+	switch tag {
+	case "!!map":
+		h.err = yaml.Unmarshal(data, (*compactHidden)(h))
+	case "!!seq":
+		h.err = yaml.Unmarshal(data, &h.Layers)
+	default:
+		h.err = fmt.Errorf("Incorrect hidden layers configuration: %v\n", value)
+	}
+	return h.err == nil
+}
+
 // Manifest is a data structure used to decode neural network configuration manifest
 type Manifest struct {
 	// Kind holds neural network Kind: feedfwd
 	Kind string `yaml:"kind"`
-	// Task is neural network task: class, [cluster, predict]
+	// Task is neural network task: class, predict, multilabel, [cluster]
 	Task string `yaml:"task"`
+	// Seed seeds the random number generator of the network
+	Seed int64 `yaml:"seed,omitempty"`
 	// Network provides neural network layer config and topology
 	Network struct {
 		// Input layer configuration
 		Input struct {
 			// Size represents number of input neurons
 			Size int `yaml:"size"`
+			// Shape is shape of input images: height, width and channels
+			Shape []int `yaml:"shape,omitempty"`
 		} `yaml:"input"`
 		// Hidden layers configuration
-		Hidden struct {
-			// Size contains sizes of all hidden layers
-			Size []int `yaml:"size"`
-			// Activation is neuron activation function
-			Activation string `yaml:"activation"`
-		} `yaml:"hidden,omitempty"`
+		Hidden Hidden `yaml:"hidden,omitempty"`
 		// Output layer configuration
 		Output struct {
 			// Size represents number of input neurons
 			Size int `yaml:"size"`
 			// Activation is neuron activation function
 			Activation string `yaml:"activation"`
+			// Param is activation function parameter
+			Param float64 `yaml:"param,omitempty"`
+			// Init contains weights initialization of output layer
+			Init struct {
+				// Kind is weights initialization scheme
+				Kind string `yaml:"kind"`
+				// Value is the value of constant weights
+				Value float64 `yaml:"value,omitempty"`
+				// Bias is the initial value of bias weights
+				Bias float64 `yaml:"bias,omitempty"`
+			} `yaml:"init,omitempty"`
+			// Thresholds are decision thresholds of multilabel network outputs
+			Thresholds []float64 `yaml:"thresholds,omitempty"`
 		} `yaml:"output"`
 	} `yaml:"network"`
 	// Training holds neural network training configuration
 	Training struct {
 		// Kind holds kind of neural network training
 		Kind string `yaml:"kind"`
-		// Cost allows to specify cost function: xentropy, loglike
+		// Cost allows to specify cost function: xentropy, loglike, mse, mae, huber
 		Cost string `yaml:"cost"`
 		// Params contains parameters of neural training
 		Params struct {
@@ -50,6 +151,20 @@ type Manifest struct {
 			Epochs int `yaml:"epochs"`
 			// Lambda is regualarization parameter
 			Lambda float64 `yaml:"lambda"`
+			// Workers is a number of goroutines used to calculate cost and gradient
+			Workers int `yaml:"workers,omitempty"`
+			// Regularizer is weights penalty: l2, l1, elasticnet
+			Regularizer string `yaml:"regularizer,omitempty"`
+			// L1ratio is the ratio of L1 penalty in elasticnet regularization
+			L1ratio float64 `yaml:"l1ratio,omitempty"`
+			// Lambdas overrides lambda of particular layers indexed from the first hidden layer
+			Lambdas map[int]float64 `yaml:"lambdas,omitempty"`
+			// Smoothing is label smoothing factor of classification targets
+			Smoothing float64 `yaml:"smoothing,omitempty"`
+			// Huber is the error threshold of huber cost
+			Huber float64 `yaml:"huber,omitempty"`
+			// Tune enables tuning of multilabel decision thresholds on validation data set
+			Tune bool `yaml:"tune,omitempty"`
 		} `yaml:"params"`
 		// Optimize contains configuration for training optimization
 		Optimize struct {
@@ -57,32 +172,167 @@ type Manifest struct {
 			Method string `yaml:"method"`
 			// Iterations is a number of major optimization iterations
 			Iterations int `yaml:"iterations,omitempty"`
+			// Linesearch is a line search algorithm: backtracking, bisection, morethuente
+			Linesearch string `yaml:"linesearch,omitempty"`
+			// Stepsize is an initial step size algorithm: constant, quadratic, firstorder
+			Stepsize string `yaml:"stepsize,omitempty"`
+			// Step is the step size used by constant step size algorithm
+			Step float64 `yaml:"step,omitempty"`
+			// Store is the size of L-BFGS history
+			Store int `yaml:"store,omitempty"`
+			// Variant is CG variant: fletcherreeves, polakribiere, hestenesstiefel, daiyuan, hagerzhang
+			Variant string `yaml:"variant,omitempty"`
+			// Simplex is the size of the initial Nelder-Mead simplex
+			Simplex float64 `yaml:"simplex,omitempty"`
+			// Batchsize is the number of samples in a mini-batch
+			Batchsize int `yaml:"batchsize,omitempty"`
+			// Momentum is the momentum coefficient of mini-batch optimizers
+			Momentum float64 `yaml:"momentum,omitempty"`
+			// Nesterov enables Nesterov momentum
+			Nesterov bool `yaml:"nesterov,omitempty"`
+			// Beta1 is exponential decay rate of the first moment estimates
+			Beta1 *float64 `yaml:"beta1,omitempty"`
+			// Beta2 is exponential decay rate of the second moment estimates
+			Beta2 *float64 `yaml:"beta2,omitempty"`
+			// Rho is decay rate of RMSProp squared gradient moving average
+			Rho *float64 `yaml:"rho,omitempty"`
+			// Epsilon is numerical stability constant of adaptive optimizers
+			Epsilon float64 `yaml:"epsilon,omitempty"`
+			// Weightdecay is decoupled weight decay coefficient
+			Weightdecay float64 `yaml:"weightdecay,omitempty"`
 		} `yaml:"optimize,omitempty"`
+		// Schedule contains configuration of learning rate schedule
+		Schedule struct {
+			// Kind is learning rate schedule: constant, step, exp, cosine, onecycle
+			Kind string `yaml:"kind,omitempty"`
+			// Warmup is the number of epochs of linear learning rate warmup
+			Warmup float64 `yaml:"warmup,omitempty"`
+			// Every is step decay period in epochs
+			Every float64 `yaml:"every,omitempty"`
+			// Gamma is learning rate decay factor
+			Gamma float64 `yaml:"gamma,omitempty"`
+			// Period is cosine annealing period in epochs
+			Period float64 `yaml:"period,omitempty"`
+			// Mult multiplies cosine annealing period after every restart
+			Mult float64 `yaml:"mult,omitempty"`
+			// Minrate is minimum learning rate
+			Minrate float64 `yaml:"minrate,omitempty"`
+			// Maxrate is maximum learning rate of one-cycle schedule
+			Maxrate float64 `yaml:"maxrate,omitempty"`
+			// Cycle is one-cycle length in epochs
+			Cycle float64 `yaml:"cycle,omitempty"`
+		} `yaml:"schedule,omitempty"`
+		// Earlystop contains configuration of early stopping on validation data set
+		Earlystop struct {
+			// Metric is monitored validation metric: accuracy, cost
+			Metric string `yaml:"metric,omitempty"`
+			// Patience is the number of epochs without improvement after which training stops
+			Patience int `yaml:"patience,omitempty"`
+			// Mindelta is minimum change of the metric considered an improvement
+			Mindelta float64 `yaml:"mindelta,omitempty"`
+		} `yaml:"earlystop,omitempty"`
+		// Clip contains configuration of gradient clipping
+		Clip struct {
+			// Value is maximum absolute value of a gradient element
+			Value float64 `yaml:"value,omitempty"`
+			// Norm is maximum L2 norm of the gradient of all network layers
+			Norm float64 `yaml:"norm,omitempty"`
+		} `yaml:"clip,omitempty"`
+		// Classes contains configuration of handling imbalanced classes
+		Classes struct {
+			// Weights contains cost weights of all classes
+			Weights []float64 `yaml:"weights,omitempty"`
+			// Balance is class balancing mode: weights, sampling
+			Balance string `yaml:"balance,omitempty"`
+		} `yaml:"classes,omitempty"`
+		// Augment contains configuration of image data augmentation
+		Augment struct {
+			// Translate is maximum shift of images in pixels
+			Translate float64 `yaml:"translate,omitempty"`
+			// Rotate is maximum rotation of images in degrees
+			Rotate float64 `yaml:"rotate,omitempty"`
+			// Scale is maximum relative change of image size
+			Scale float64 `yaml:"scale,omitempty"`
+			// Elastic contains configuration of elastic distortion
+			Elastic struct {
+				// Alpha is intensity of the distortion in pixels
+				Alpha float64 `yaml:"alpha,omitempty"`
+				// Sigma is smoothness of the distortion in pixels
+				Sigma float64 `yaml:"sigma,omitempty"`
+			} `yaml:"elastic,omitempty"`
+			// Noise is std deviation of additive gaussian noise
+			Noise float64 `yaml:"noise,omitempty"`
+		} `yaml:"augment,omitempty"`
 	} `yaml:"training"`
 }
 
 // network maps supported training and optimization parameters to a particular neural network
 var network = map[string]map[string][]string{
 	"feedfwd": {
-		"training": {"backprop"},
-		"optim":    {"bfgs"},
+		"training": {"backprop", "sgd"},
+		"optim":    {"bfgs", "lbfgs", "cg", "gd", "neldermead", "sgd", "adam", "adamw", "rmsprop", "adagrad"},
 	},
 }
 
+// training maps training kinds to optimization methods they can use
+var training = map[string][]string{
+	"backprop": {"bfgs", "lbfgs", "cg", "gd", "neldermead"},
+	"sgd":      {"sgd", "adam", "adamw", "rmsprop", "adagrad"},
+}
+
 // NeuronConfig allows to specify neuron configuration
 type NeuronConfig struct {
 	// Activation is a neuron activation function
 	Activation string
+	// Param is activation function parameter, e.g. negative slope of leaky ReLU.
+	// Activation functions with a parameter use its default value if Param is zero
+	Param float64
 }
 
 // LayerConfig allows to specify neural network layer configuration
 type LayerConfig struct {
 	// Kind is neural network layer kind: input, output, hidden
 	Kind string
-	// Size represents a number of neurons in the network layer
+	// Type is hidden layer type: dense, conv, maxpool, avgpool, flatten. Empty type is dense
+	Type string
+	// Size represents a number of neurons in the network layer.
+	// Size of conv, pooling and flatten layers is given by the shape of their input
 	Size int
+	// Shape is shape of input layer images: height, width and channels. Images are stored
+	// in input rows pixel by pixel, row after row, with the channels of every pixel next to each other
+	Shape []int
+	// Conv holds convolution or pooling configuration of conv and pooling layers
+	Conv *ConvConfig
 	// NeurFn holds neuron configuration
 	NeurFn *NeuronConfig
+	// Dropout is the probability of dropping layer neuron output during training
+	Dropout float64
+	// Batchnorm enables batch normalization of layer activation inputs
+	Batchnorm bool
+	// Init holds weights initialization. Weights are initialized by the default scheme if nil
+	Init *InitConfig
+}
+
+// ConvConfig allows to specify convolution and pooling layers
+type ConvConfig struct {
+	// Filters is number of convolution filters i.e. output channels of conv layer
+	Filters int
+	// Kernel is side of square convolution kernel or pooling window
+	Kernel int
+	// Stride is step of the kernel. Zero stride defaults to 1 for conv and to Kernel for pooling
+	Stride int
+	// Padding is number of zero pixels added to every side of input images
+	Padding int
+}
+
+// InitConfig allows to specify layer weights initialization
+type InitConfig struct {
+	// Kind is weights initialization scheme
+	Kind string
+	// Value is the value of all weights initialized by constant scheme
+	Value float64
+	// Bias is the initial value of bias weights
+	Bias float64
 }
 
 // NetArch specifies neural network architecture
@@ -99,22 +349,124 @@ type NetArch struct {
 type NetConfig struct {
 	// Kind is Neural Network type
 	Kind string
+	// Task is neural network task: class, predict or multilabel. Classification is used if it is not set
+	Task string
 	// Arch specifies network architecture
 	Arch *NetArch
+	// Thresholds contains decision thresholds of every output of multilabel network.
+	// Outputs are thresholded at 0.5 if they are not set
+	Thresholds []float64
+	// Seed seeds the random number generator used for weights initialization, shuffling
+	// and dropout. Random seed is picked if it is 0
+	Seed int64
 }
 
 // OptimConfig allows to specify advanced optimization configuration
 type OptimConfig struct {
-	// Method is an advanced optimization method
-	// Currently only bfgs algorithm is supported
+	// Method is an advanced optimization method: bfgs, lbfgs, cg, gd, neldermead
 	Method string
 	// Iterations specifies the number of optimization iterations
 	Iterations int
+	// Linesearch is a line search algorithm used by gradient based methods
+	Linesearch string
+	// Stepsize is an initial step size algorithm used by cg and gd methods
+	Stepsize string
+	// Step is the step size used by constant Stepsize
+	Step float64
+	// Store is the size of L-BFGS history
+	Store int
+	// Variant is CG variant
+	Variant string
+	// Simplex is the size of the initial Nelder-Mead simplex
+	Simplex float64
+	// Batchsize is the number of samples in a mini-batch
+	Batchsize int
+	// Momentum is the momentum coefficient of mini-batch optimizers
+	Momentum float64
+	// Nesterov enables Nesterov momentum
+	Nesterov bool
+	// Beta1 is exponential decay rate of the first moment estimates. Default is used if nil
+	Beta1 *float64
+	// Beta2 is exponential decay rate of the second moment estimates. Default is used if nil
+	Beta2 *float64
+	// Rho is decay rate of RMSProp squared gradient moving average. Default is used if nil
+	Rho *float64
+	// Epsilon is numerical stability constant of adaptive optimizers
+	Epsilon float64
+	// Weightdecay is decoupled weight decay coefficient used by adamw
+	Weightdecay float64
+}
+
+// ScheduleConfig allows to specify learning rate schedule
+type ScheduleConfig struct {
+	// Kind is learning rate schedule: constant, step, exp, cosine, onecycle
+	Kind string
+	// Warmup is the number of epochs of linear learning rate warmup
+	Warmup float64
+	// Every is step decay period in epochs
+	Every float64
+	// Gamma is learning rate decay factor used by step and exp schedules
+	Gamma float64
+	// Period is cosine annealing period in epochs
+	Period float64
+	// Mult multiplies cosine annealing period after every restart
+	Mult float64
+	// Minrate is minimum learning rate used by cosine and onecycle schedules
+	Minrate float64
+	// Maxrate is maximum learning rate of onecycle schedule
+	Maxrate float64
+	// Cycle is onecycle length in epochs. It is required by onecycle schedule
+	Cycle float64
+}
+
+// EarlyStopConfig allows to specify early stopping on validation data set
+type EarlyStopConfig struct {
+	// Metric is monitored validation metric: accuracy, cost
+	Metric string
+	// Patience is the number of epochs without improvement after which training stops
+	Patience int
+	// Mindelta is minimum change of the metric considered an improvement.
+	// Accuracy is measured in percents.
+	Mindelta float64
+}
+
+// ClipConfig allows to specify gradient clipping
+type ClipConfig struct {
+	// Value is maximum absolute value of a gradient element. Values are not clipped if 0
+	Value float64
+	// Norm is maximum L2 norm of the gradient of all network layers. Norm is not clipped if 0
+	Norm float64
+}
+
+// ClassConfig allows to specify handling of imbalanced classes
+type ClassConfig struct {
+	// Weights contains cost weights of all classes indexed by class label
+	Weights []float64
+	// Balance is class balancing mode. "weights" calculates class weights from label frequencies
+	// of training data, "sampling" over-samples minority classes when creating mini-batches
+	Balance string
+}
+
+// AugmentConfig allows to specify random augmentation of training images.
+// Transformations with zero parameters are not applied
+type AugmentConfig struct {
+	// Translate is maximum shift of images in pixels
+	Translate float64
+	// Rotate is maximum rotation of images in degrees
+	Rotate float64
+	// Scale is maximum relative change of image size
+	Scale float64
+	// Alpha is intensity of elastic distortion in pixels
+	Alpha float64
+	// Sigma is std deviation of gaussian filter smoothing elastic distortion in pixels
+	Sigma float64
+	// Noise is std deviation of additive gaussian noise
+	Noise float64
 }
 
 // TrainConfig allows to specify neural network training configuration
 type TrainConfig struct {
-	// Kind is a neural network training type: backprop
+	// Kind is a neural network training type: backprop, sgd
 	Kind string
 	// Cost is a neural network cost function
 	Cost string
@@ -124,8 +476,37 @@ type TrainConfig struct {
 	Epochs int
 	// Lambda is regularizer parameter
 	Lambda float64
+	// Regularizer is weights penalty: l2, l1, elasticnet. L2 penalty is used if it is not set
+	Regularizer string
+	// L1ratio is the ratio of L1 penalty in elasticnet regularization
+	L1ratio float64
+	// Lambdas overrides regularizer parameter of particular layers.
+	// Layers are indexed from 1 which is the first hidden layer
+	Lambdas map[int]float64
+	// Workers is a number of goroutines used to calculate cost and gradient.
+	// Single goroutine is used if it is not set
+	Workers int
+	// Smoothing is label smoothing factor in range [0, 1). One-of-N training targets
+	// are mixed with uniform distribution over all classes. Targets are not smoothed if it is not set
+	Smoothing float64
+	// Huber is the error threshold of huber cost above which the errors are penalized
+	// linearly instead of quadratically. Threshold of 1.0 is used if it is not set
+	Huber float64
+	// Tune tunes decision thresholds of multilabel network outputs on validation data set
+	// once the training finishes. Thresholds are not tuned if it is not set
+	Tune bool
 	// Optimize holds training optimization parameters
 	Optimize *OptimConfig
+	// Schedule holds learning rate schedule. Learning rate is constant if nil
+	Schedule *ScheduleConfig
+	// EarlyStop holds early stopping configuration. Early stopping is disabled if nil
+	EarlyStop *EarlyStopConfig
+	// Clip holds gradient clipping configuration. Gradient is not clipped if nil
+	Clip *ClipConfig
+	// Classes holds handling of imbalanced classes. All classes are treated equally if nil
+	Classes *ClassConfig
+	// Augment holds augmentation of training images. Training data is not augmented if nil
+	Augment *AugmentConfig
 }
 
 // Config allows to specify neural network architecture and training configuration
@@ -169,6 +550,10 @@ func ParseManifest(m *Manifest) (*Config, error) {
 	if _, ok := network[m.Kind]; !ok {
 		return nil, fmt.Errorf("Unsupported network kind: %s\n", m.Kind)
 	}
+	// check if the requested network task is supported
+	if m.Task != "" && m.Task != "class" && m.Task != "predict" && m.Task != "multilabel" {
+		return nil, fmt.Errorf("Unsupported network task: %s\n", m.Task)
+	}
 	// parse neural network layer configuration parameters
 	netConfig, err := parseNetConfig(m)
 	if err != nil {
@@ -193,37 +578,56 @@ func parseNetConfig(m *Manifest) (*NetConfig, error) {
 		return nil, fmt.Errorf("Incorrect input layer size: %d\n", m.Network.Input.Size)
 	}
 	inputLayer := &LayerConfig{Kind: "input", Size: m.Network.Input.Size}
-	// HIDDEN network layer configuration
-	var hiddenLayers []*LayerConfig
-	if len(m.Network.Hidden.Size) != 0 {
-		hiddenLayers = make([]*LayerConfig, len(m.Network.Hidden.Size))
-		for i, size := range m.Network.Hidden.Size {
-			if size <= 0 {
-				return nil, fmt.Errorf("Incorrect hidden layer size: %d\n", size)
-			}
-			hiddenLayers[i] = &LayerConfig{
-				Kind: "hidden",
-				Size: size,
-				NeurFn: &NeuronConfig{
-					Activation: m.Network.Hidden.Activation,
-				},
-			}
+	// input images must be 3 dimensional and match the input size
+	if shape := m.Network.Input.Shape; len(shape) != 0 {
+		if len(shape) != 3 || shape[0] <= 0 || shape[1] <= 0 || shape[2] <= 0 ||
+			shape[0]*shape[1]*shape[2] != inputLayer.Size {
+			return nil, fmt.Errorf("Incorrect input layer shape: %v\n", shape)
 		}
+		inputLayer.Shape = shape
+	}
+	// HIDDEN network layer configuration
+	hiddenLayers, err := parseHiddenConfig(&m.Network.Hidden)
+	if err != nil {
+		return nil, err
 	}
 	// OUTPUT layer configuration
 	if m.Network.Output.Size <= 0 {
 		return nil, fmt.Errorf("Incorrect output layer size: %d\n", m.Network.Output.Size)
 	}
+	init := m.Network.Output.Init
+	outputInit, err := parseInitConfig(init.Kind, init.Value, init.Bias)
+	if err != nil {
+		return nil, err
+	}
 	outputLayer := &LayerConfig{
 		Kind: "output",
 		Size: m.Network.Output.Size,
 		NeurFn: &NeuronConfig{
 			Activation: m.Network.Output.Activation,
+			Param:      m.Network.Output.Param,
 		},
+		Init: outputInit,
+	}
+
+	// decision thresholds are only used by multilabel networks
+	thresholds := m.Network.Output.Thresholds
+	if len(thresholds) != 0 {
+		if m.Task != "multilabel" || len(thresholds) != outputLayer.Size {
+			return nil, fmt.Errorf("Incorrect output thresholds: %v\n", thresholds)
+		}
+		for _, threshold := range thresholds {
+			if threshold <= 0 || threshold >= 1 {
+				return nil, fmt.Errorf("Incorrect output threshold: %f\n", threshold)
+			}
+		}
 	}
 
 	return &NetConfig{
-		Kind: m.Kind,
+		Kind:       m.Kind,
+		Task:       m.Task,
+		Seed:       m.Seed,
+		Thresholds: thresholds,
 		Arch: &NetArch{
 			Input:  inputLayer,
 			Hidden: hiddenLayers,
@@ -232,6 +636,130 @@ func parseNetConfig(m *Manifest) (*NetConfig, error) {
 	}, nil
 }
 
+// parseHiddenConfig returns configuration of every hidden layer decoded from either
+// the compact form or the list of hidden layer objects. The two forms can't be combined.
+func parseHiddenConfig(h *Hidden) ([]*LayerConfig, error) {
+	// hidden layers configuration could not be decoded
+	if h.err != nil {
+		return nil, h.err
+	}
+	if len(h.Layers) == 0 {
+		return parseCompactHidden(h)
+	}
+	if len(h.Size) != 0 || h.Activation != "" || h.Param != 0 || len(h.Dropout) != 0 || h.Batchnorm ||
+		h.Init.Kind != "" || h.Init.Value != 0 || h.Init.Bias != 0 {
+		return nil, fmt.Errorf("Hidden layers list can't be combined with compact hidden layers configuration\n")
+	}
+	hiddenLayers := make([]*LayerConfig, len(h.Layers))
+	for i, layer := range h.Layers {
+		init, err := parseInitConfig(layer.Init.Kind, layer.Init.Value, layer.Init.Bias)
+		if err != nil {
+			return nil, err
+		}
+		if layer.Type != "" && layer.Type != "dense" {
+			hiddenLayers[i], err = spatialLayerConfig(&h.Layers[i], init)
+		} else if layer.Filters != 0 || layer.Kernel != 0 || layer.Stride != 0 || layer.Padding != 0 {
+			err = fmt.Errorf("Convolution parameters not supported by dense hidden layer\n")
+		} else {
+			hiddenLayers[i], err = hiddenLayerConfig(layer.Size, layer.Activation, layer.Param, layer.Dropout, layer.Batchnorm, init)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return hiddenLayers, nil
+}
+
+// parseCompactHidden returns configuration of hidden layers which share all the parameters but size
+func parseCompactHidden(h *Hidden) ([]*LayerConfig, error) {
+	var hiddenLayers []*LayerConfig
+	// dropout rate is specified for every hidden layer
+	if len(h.Dropout) != 0 && len(h.Dropout) != len(h.Size) {
+		return nil, fmt.Errorf("Incorrect number of hidden layer dropout rates: %d\n", len(h.Dropout))
+	}
+	// weights initialization of hidden layers
+	hiddenInit, err := parseInitConfig(h.Init.Kind, h.Init.Value, h.Init.Bias)
+	if err != nil {
+		return nil, err
+	}
+	for i, size := range h.Size {
+		dropout := 0.0
+		if len(h.Dropout) != 0 {
+			dropout = h.Dropout[i]
+		}
+		layer, err := hiddenLayerConfig(size, h.Activation, h.Param, dropout, h.Batchnorm, hiddenInit)
+		if err != nil {
+			return nil, err
+		}
+		hiddenLayers = append(hiddenLayers, layer)
+	}
+	return hiddenLayers, nil
+}
+
+// hiddenLayerConfig validates hidden layer parameters and returns its configuration
+func hiddenLayerConfig(size int, activation string, param, dropout float64, batchnorm bool, init *InitConfig) (*LayerConfig, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("Incorrect hidden layer size: %d\n", size)
+	}
+	if dropout < 0 || dropout >= 1 {
+		return nil, fmt.Errorf("Incorrect hidden layer dropout rate: %f\n", dropout)
+	}
+	return &LayerConfig{
+		Kind: "hidden",
+		Size: size,
+		NeurFn: &NeuronConfig{
+			Activation: activation,
+			Param:      param,
+		},
+		Dropout:   dropout,
+		Batchnorm: batchnorm,
+		Init:      init,
+	}, nil
+}
+
+// spatialLayerConfig validates parameters of conv, pooling and flatten hidden layers and returns
+// their configuration. Only conv layers have weights and activation function. Neither of
+// the layers supports dropout and batch normalization and their size is given by their input.
+func spatialLayerConfig(layer *HiddenLayer, init *InitConfig) (*LayerConfig, error) {
+	if layer.Size != 0 || layer.Dropout != 0 || layer.Batchnorm {
+		return nil, fmt.Errorf("Size, dropout and batch normalization not supported by %s layer\n", layer.Type)
+	}
+	c := &LayerConfig{Kind: "hidden", Type: layer.Type}
+	switch layer.Type {
+	case "conv":
+		if layer.Filters <= 0 {
+			return nil, fmt.Errorf("Incorrect number of conv layer filters: %d\n", layer.Filters)
+		}
+		c.NeurFn = &NeuronConfig{Activation: layer.Activation, Param: layer.Param}
+		c.Init = init
+	case "maxpool", "avgpool", "flatten":
+		if layer.Filters != 0 || layer.Activation != "" || layer.Param != 0 || init != nil || layer.Lambda != nil {
+			return nil, fmt.Errorf("Filters, activation, weights initialization and lambda not supported by %s layer\n",
+				layer.Type)
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported hidden layer type: %s\n", layer.Type)
+	}
+	if layer.Type == "flatten" {
+		if layer.Kernel != 0 || layer.Stride != 0 || layer.Padding != 0 {
+			return nil, fmt.Errorf("Kernel, stride and padding not supported by flatten layer\n")
+		}
+		return c, nil
+	}
+	// padding wider than the kernel would produce outputs which don't see any input pixel
+	if layer.Kernel <= 0 || layer.Stride < 0 || layer.Padding < 0 || layer.Padding >= layer.Kernel {
+		return nil, fmt.Errorf("Incorrect %s layer kernel %d, stride %d or padding %d\n",
+			layer.Type, layer.Kernel, layer.Stride, layer.Padding)
+	}
+	c.Conv = &ConvConfig{
+		Filters: layer.Filters,
+		Kernel:  layer.Kernel,
+		Stride:  layer.Stride,
+		Padding: layer.Padding,
+	}
+	return c, nil
+}
+
 func parseOptimConfig(m *Manifest) (*OptimConfig, error) {
 	// optimize Method can't be empty
 	if m.Training.Optimize.Method == "" {
@@ -249,6 +777,11 @@ func parseOptimConfig(m *Manifest) (*OptimConfig, error) {
 		return nil, fmt.Errorf("Unsupported optimization method: %s\n",
 			m.Training.Optimize.Method)
 	}
+	// check if the optimization method can be used by the requested training
+	if !validOpt(training[m.Training.Kind], m.Training.Optimize.Method) {
+		return nil, fmt.Errorf("Optimization method %s not supported by %s training\n",
+			m.Training.Optimize.Method, m.Training.Kind)
+	}
 	// check number of iterations
 	var iters int
 	if m.Training.Optimize.Iterations <= 0 {
@@ -256,13 +789,297 @@ func parseOptimConfig(m *Manifest) (*OptimConfig, error) {
 	} else {
 		iters = m.Training.Optimize.Iterations
 	}
+	// method specific options are validated by the neural package
+	opt := m.Training.Optimize
+	step := opt.Step
+	if opt.Stepsize == "constant" && step == 0 {
+		step = 1.0
+	}
+	batchSize := opt.Batchsize
+	if batchSize == 0 {
+		batchSize = 32
+	}
 
 	return &OptimConfig{
-		Method:     m.Training.Optimize.Method,
-		Iterations: iters,
+		Method:      opt.Method,
+		Iterations:  iters,
+		Linesearch:  opt.Linesearch,
+		Stepsize:    opt.Stepsize,
+		Step:        step,
+		Store:       opt.Store,
+		Variant:     opt.Variant,
+		Simplex:     opt.Simplex,
+		Batchsize:   batchSize,
+		Momentum:    opt.Momentum,
+		Nesterov:    opt.Nesterov,
+		Beta1:       opt.Beta1,
+		Beta2:       opt.Beta2,
+		Rho:         opt.Rho,
+		Epsilon:     opt.Epsilon,
+		Weightdecay: opt.Weightdecay,
+	}, nil
+}
+
+// schedules contains supported learning rate schedules
+var schedules = []string{"constant", "step", "exp", "cosine", "onecycle"}
+
+func parseScheduleConfig(m *Manifest) (*ScheduleConfig, error) {
+	sched := m.Training.Schedule
+	// no schedule requested
+	if sched.Kind == "" && sched.Warmup == 0 {
+		return nil, nil
+	}
+	kind := sched.Kind
+	if kind == "" {
+		kind = "constant"
+	}
+	// check if the requested schedule is supported
+	if !validOpt(schedules, kind) {
+		return nil, fmt.Errorf("Unsupported learning rate schedule: %s\n", sched.Kind)
+	}
+	// learning rate schedules are only used by mini-batch training
+	if m.Training.Kind != "sgd" {
+		return nil, fmt.Errorf("Learning rate schedule not supported by %s training\n", m.Training.Kind)
+	}
+	// check schedule parameters
+	if sched.Warmup < 0 || sched.Every < 0 || sched.Gamma < 0 || sched.Period < 0 ||
+		sched.Mult < 0 || sched.Minrate < 0 || sched.Maxrate < 0 || sched.Cycle < 0 {
+		return nil, fmt.Errorf("Incorrect learning rate schedule parameters: %v\n", sched)
+	}
+
+	return &ScheduleConfig{
+		Kind:    kind,
+		Warmup:  sched.Warmup,
+		Every:   sched.Every,
+		Gamma:   sched.Gamma,
+		Period:  sched.Period,
+		Mult:    sched.Mult,
+		Minrate: sched.Minrate,
+		Maxrate: sched.Maxrate,
+		Cycle:   sched.Cycle,
+	}, nil
+}
+
+// regularizers contains supported weights penalties
+var regularizers = []string{"l2", "l1", "elasticnet"}
+
+func parseRegularizer(m *Manifest) (string, float64, error) {
+	params := m.Training.Params
+	regularizer := params.Regularizer
+	if regularizer == "" {
+		regularizer = "l2"
+	}
+	// check if the requested regularizer is supported
+	if !validOpt(regularizers, regularizer) {
+		return "", 0, fmt.Errorf("Unsupported regularizer: %s\n", params.Regularizer)
+	}
+	// L1 ratio is only used by elasticnet
+	if params.L1ratio < 0 || params.L1ratio > 1 || (params.L1ratio > 0 && regularizer != "elasticnet") {
+		return "", 0, fmt.Errorf("Incorrect l1ratio parameter for %s regularizer: %f\n", regularizer, params.L1ratio)
+	}
+	l1ratio := params.L1ratio
+	if regularizer == "elasticnet" && l1ratio == 0 {
+		l1ratio = 0.5
+	}
+	// check per layer regularization parameters
+	for layer, lambda := range params.Lambdas {
+		if layer < 1 || lambda < 0 {
+			return "", 0, fmt.Errorf("Incorrect lambda of layer %d: %f\n", layer, lambda)
+		}
+	}
+	return regularizer, l1ratio, nil
+}
+
+// parseLambdas merges lambdas of hidden layers configured in the hidden layers list
+// into per layer lambdas of training parameters. Layers are indexed from the first hidden layer.
+func parseLambdas(m *Manifest) (map[int]float64, error) {
+	var lambdas map[int]float64
+	// copy lambdas so the manifest is not modified
+	for layer, lambda := range m.Training.Params.Lambdas {
+		if lambdas == nil {
+			lambdas = make(map[int]float64)
+		}
+		lambdas[layer] = lambda
+	}
+	for i, layer := range m.Network.Hidden.Layers {
+		if layer.Lambda == nil {
+			continue
+		}
+		if _, ok := lambdas[i+1]; ok {
+			return nil, fmt.Errorf("Lambda of layer %d set both in hidden layer and training parameters\n", i+1)
+		}
+		if *layer.Lambda < 0 {
+			return nil, fmt.Errorf("Incorrect lambda of layer %d: %f\n", i+1, *layer.Lambda)
+		}
+		if lambdas == nil {
+			lambdas = make(map[int]float64)
+		}
+		lambdas[i+1] = *layer.Lambda
+	}
+	return lambdas, nil
+}
+
+// earlyStopMetrics contains validation metrics which can be monitored by early stopping
+var earlyStopMetrics = []string{"accuracy", "cost"}
+
+func parseEarlyStopConfig(m *Manifest) (*EarlyStopConfig, error) {
+	stop := m.Training.Earlystop
+	// early stopping not requested
+	if stop.Metric == "" && stop.Patience == 0 && stop.Mindelta == 0 {
+		return nil, nil
+	}
+	metric := stop.Metric
+	if metric == "" {
+		metric = "accuracy"
+	}
+	// check if the requested metric is supported
+	if !validOpt(earlyStopMetrics, metric) {
+		return nil, fmt.Errorf("Unsupported early stopping metric: %s\n", stop.Metric)
+	}
+	// check early stopping parameters
+	if stop.Patience < 0 || stop.Mindelta < 0 {
+		return nil, fmt.Errorf("Incorrect early stopping parameters: patience %d, mindelta %f\n",
+			stop.Patience, stop.Mindelta)
+	}
+	patience := stop.Patience
+	if patience == 0 {
+		patience = 5
+	}
+
+	return &EarlyStopConfig{
+		Metric:   metric,
+		Patience: patience,
+		Mindelta: stop.Mindelta,
+	}, nil
+}
+
+// initKinds contains supported weights initialization schemes
+var initKinds = []string{"uniform", "xavieruniform", "xaviernormal", "heuniform", "henormal",
+	"lecun", "orthogonal", "constant"}
+
+func parseInitConfig(kind string, value, bias float64) (*InitConfig, error) {
+	// weights initialization not requested
+	if kind == "" && value == 0 && bias == 0 {
+		return nil, nil
+	}
+	if kind == "" {
+		kind = "uniform"
+	}
+	// check if the requested scheme is supported
+	if !validOpt(initKinds, kind) {
+		return nil, fmt.Errorf("Unsupported weights initialization: %s\n", kind)
+	}
+	// only constant weights have value
+	if value != 0 && kind != "constant" {
+		return nil, fmt.Errorf("Value not supported by %s weights initialization\n", kind)
+	}
+
+	return &InitConfig{
+		Kind:  kind,
+		Value: value,
+		Bias:  bias,
+	}, nil
+}
+
+// classBalance contains supported class balancing modes
+var classBalance = []string{"weights", "sampling"}
+
+func parseClassConfig(m *Manifest) (*ClassConfig, error) {
+	classes := m.Training.Classes
+	// all classes are treated equally
+	if len(classes.Weights) == 0 && classes.Balance == "" {
+		return nil, nil
+	}
+	// class weights are either supplied or balanced
+	if len(classes.Weights) != 0 && classes.Balance != "" {
+		return nil, fmt.Errorf("Class weights can't be combined with %s balancing\n", classes.Balance)
+	}
+	if classes.Balance != "" && !validOpt(classBalance, classes.Balance) {
+		return nil, fmt.Errorf("Unsupported class balancing: %s\n", classes.Balance)
+	}
+	// over-sampling is only used by mini-batch training
+	if classes.Balance == "sampling" && m.Training.Kind != "sgd" {
+		return nil, fmt.Errorf("Class balanced sampling not supported by %s training\n", m.Training.Kind)
+	}
+	// every class must have a weight
+	if len(classes.Weights) != 0 && len(classes.Weights) != m.Network.Output.Size {
+		return nil, fmt.Errorf("Incorrect number of class weights: %d\n", len(classes.Weights))
+	}
+	for _, weight := range classes.Weights {
+		if weight < 0 {
+			return nil, fmt.Errorf("Incorrect class weight: %f\n", weight)
+		}
+	}
+
+	return &ClassConfig{
+		Weights: classes.Weights,
+		Balance: classes.Balance,
 	}, nil
 }
 
+func parseAugmentConfig(m *Manifest) (*AugmentConfig, error) {
+	augment := m.Training.Augment
+	c := &AugmentConfig{
+		Translate: augment.Translate,
+		Rotate:    augment.Rotate,
+		Scale:     augment.Scale,
+		Alpha:     augment.Elastic.Alpha,
+		Sigma:     augment.Elastic.Sigma,
+		Noise:     augment.Noise,
+	}
+	// augmentation not requested
+	if *c == (AugmentConfig{}) {
+		return nil, nil
+	}
+	// training data is augmented for every mini-batch
+	if m.Training.Kind != "sgd" {
+		return nil, fmt.Errorf("Data augmentation not supported by %s training\n", m.Training.Kind)
+	}
+	if c.Translate < 0 || c.Noise < 0 {
+		return nil, fmt.Errorf("Incorrect augmentation parameters: translate %f, noise %f\n", c.Translate, c.Noise)
+	}
+	if c.Rotate < 0 || c.Rotate > 180 {
+		return nil, fmt.Errorf("Incorrect augmentation rotation: %f\n", c.Rotate)
+	}
+	if c.Scale < 0 || c.Scale >= 1 {
+		return nil, fmt.Errorf("Incorrect augmentation scale: %f\n", c.Scale)
+	}
+	// elastic distortion must be smoothed
+	if c.Alpha < 0 || c.Sigma < 0 || (c.Alpha > 0 && c.Sigma == 0) {
+		return nil, fmt.Errorf("Incorrect elastic distortion parameters: alpha %f, sigma %f\n", c.Alpha, c.Sigma)
+	}
+
+	return c, nil
+}
+
+func parseClipConfig(m *Manifest) (*ClipConfig, error) {
+	clip := m.Training.Clip
+	// gradient clipping not requested
+	if clip.Value == 0 && clip.Norm == 0 {
+		return nil, nil
+	}
+	// check clipping parameters
+	if clip.Value < 0 || clip.Norm < 0 {
+		return nil, fmt.Errorf("Incorrect gradient clipping parameters: value %f, norm %f\n",
+			clip.Value, clip.Norm)
+	}
+
+	return &ClipConfig{
+		Value: clip.Value,
+		Norm:  clip.Norm,
+	}, nil
+}
+
+// validOpt returns true if opt is one of the valid options
+func validOpt(valid []string, opt string) bool {
+	for _, v := range valid {
+		if v == opt {
+			return true
+		}
+	}
+	return false
+}
+
 func parseTrainConfig(m *Manifest) (*TrainConfig, error) {
 	// training kind can't be empty
 	if m.Training.Kind == "" {
@@ -292,7 +1109,7 @@ func parseTrainConfig(m *Manifest) (*TrainConfig, error) {
 
 	// check epochs parameter
 	if m.Training.Params.Epochs < 0 {
-		return nil, fmt.Errorf("Incorrect Epochs parameter: %f\n", m.Training.Params.Epochs)
+		return nil, fmt.Errorf("Incorrect Epochs parameter: %d\n", m.Training.Params.Epochs)
 	}
 
 	// check lambda parameter
@@ -300,12 +1117,80 @@ func parseTrainConfig(m *Manifest) (*TrainConfig, error) {
 		return nil, fmt.Errorf("Incorrect reg parameter: %f\n", m.Training.Params.Lambda)
 	}
 
+	// check label smoothing parameter
+	if m.Training.Params.Smoothing < 0 || m.Training.Params.Smoothing >= 1 {
+		return nil, fmt.Errorf("Incorrect Smoothing parameter: %f\n", m.Training.Params.Smoothing)
+	}
+
+	// check huber cost parameter
+	if m.Training.Params.Huber < 0 {
+		return nil, fmt.Errorf("Incorrect Huber parameter: %f\n", m.Training.Params.Huber)
+	}
+
+	// parse regularization parameters
+	regularizer, l1ratio, err := parseRegularizer(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// dropout makes the cost stochastic so it can only be used by mini-batch training
+	dropout := m.Network.Hidden.Dropout
+	for _, layer := range m.Network.Hidden.Layers {
+		dropout = append(dropout, layer.Dropout)
+	}
+	for _, rate := range dropout {
+		if rate > 0 && m.Training.Kind != "sgd" {
+			return nil, fmt.Errorf("Dropout not supported by %s training\n", m.Training.Kind)
+		}
+	}
+
+	// per layer lambda is set either in the hidden layer or in training parameters
+	lambdas, err := parseLambdas(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// check workers parameter
+	if m.Training.Params.Workers < 0 {
+		return nil, fmt.Errorf("Incorrect Workers parameter: %d\n", m.Training.Params.Workers)
+	}
+
 	// parse optimization config
 	optimize, err := parseOptimConfig(m)
 	if err != nil {
 		return nil, err
 	}
 
+	// parse learning rate schedule config
+	schedule, err := parseScheduleConfig(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse early stopping config
+	earlyStop, err := parseEarlyStopConfig(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse gradient clipping config
+	clip, err := parseClipConfig(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse imbalanced classes config
+	classes, err := parseClassConfig(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse data augmentation config
+	augment, err := parseAugmentConfig(m)
+	if err != nil {
+		return nil, err
+	}
+
 	// return train config
 	return &TrainConfig{
 		Kind:     m.Training.Kind,
@@ -313,6 +1198,18 @@ func parseTrainConfig(m *Manifest) (*TrainConfig, error) {
 		Learningrate:   m.Training.Params.Learningrate,
 		Epochs:   m.Training.Params.Epochs,
 		Lambda:   m.Training.Params.Lambda,
+		Regularizer: regularizer,
+		L1ratio:  l1ratio,
+		Lambdas:  lambdas,
+		Workers:  m.Training.Params.Workers,
+		Smoothing: m.Training.Params.Smoothing,
+		Huber:     m.Training.Params.Huber,
+		Tune:      m.Training.Params.Tune,
 		Optimize: optimize,
+		Schedule: schedule,
+		EarlyStop: earlyStop,
+		Clip:      clip,
+		Classes:   classes,
+		Augment:   augment,
 	}, nil
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	yaml "gopkg.in/yaml.v1"
@@ -148,6 +149,40 @@ func TestParseNetConfig(t *testing.T) {
 	assert.Nil(c)
 	assert.Error(err)
 	m.Network.Input.Size = origInSize
+	// network task
+	origTask := m.Task
+	m.Task = "predict"
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal("predict", c.Network.Task)
+	m.Task = "foobar"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	// decision thresholds of every multilabel output
+	m.Task = "multilabel"
+	thresholds := make([]float64, m.Network.Output.Size)
+	for i := range thresholds {
+		thresholds[i] = 0.3
+	}
+	m.Network.Output.Thresholds = thresholds
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal("multilabel", c.Network.Task)
+	assert.Equal(thresholds, c.Network.Thresholds)
+	for _, incorrect := range [][]float64{{0.3}, append([]float64{1.0}, thresholds[1:]...)} {
+		m.Network.Output.Thresholds = incorrect
+		c, err = ParseManifest(&m)
+		assert.Nil(c)
+		assert.Error(err)
+	}
+	// thresholds are only used by multilabel task
+	m.Network.Output.Thresholds = thresholds
+	m.Task = origTask
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Output.Thresholds = nil
 	// incorrect hidden layer size
 	origHidSize := m.Network.Hidden.Size[0]
 	m.Network.Hidden.Size[0] = 0
@@ -155,6 +190,66 @@ func TestParseNetConfig(t *testing.T) {
 	assert.Nil(c)
 	assert.Error(err)
 	m.Network.Hidden.Size[0] = origHidSize
+	// dropout rate of every hidden layer
+	m.Network.Hidden.Dropout = []float64{0.5}
+	m.Training.Kind = "sgd"
+	m.Training.Optimize.Method = "sgd"
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Network.Arch.Hidden[0].Dropout, 0.5)
+	// dropout is only supported by mini-batch training
+	m.Training.Kind = "backprop"
+	m.Training.Optimize.Method = "bfgs"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	// incorrect dropout rates
+	m.Network.Hidden.Dropout = []float64{1.0}
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Hidden.Dropout = []float64{0.5, 0.5}
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Hidden.Dropout = nil
+	// batch normalization of all hidden layers
+	m.Network.Hidden.Batchnorm = true
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.True(c.Network.Arch.Hidden[0].Batchnorm)
+	assert.False(c.Network.Arch.Output.Batchnorm)
+	m.Network.Hidden.Batchnorm = false
+	// default weights initialization
+	assert.Nil(c.Network.Arch.Hidden[0].Init)
+	assert.Nil(c.Network.Arch.Output.Init)
+	// weights initialization of hidden and output layers
+	m.Network.Hidden.Init.Kind = "henormal"
+	m.Network.Hidden.Init.Bias = 0.1
+	m.Network.Output.Init.Kind = "constant"
+	m.Network.Output.Init.Value = 0.5
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(&InitConfig{Kind: "henormal", Bias: 0.1}, c.Network.Arch.Hidden[0].Init)
+	assert.Equal(&InitConfig{Kind: "constant", Value: 0.5}, c.Network.Arch.Output.Init)
+	// bias initialization alone uses the default scheme
+	m.Network.Hidden.Init.Kind = ""
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal("uniform", c.Network.Arch.Hidden[0].Init.Kind)
+	// unsupported scheme
+	m.Network.Hidden.Init.Kind = "foo"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	// only constant weights have value
+	m.Network.Hidden.Init.Kind = "henormal"
+	m.Network.Hidden.Init.Value = 1.0
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Hidden.Init.Kind, m.Network.Hidden.Init.Value, m.Network.Hidden.Init.Bias = "", 0, 0
+	m.Network.Output.Init.Kind, m.Network.Output.Init.Value = "", 0
 	// incorrect output size
 	origOutSize := m.Network.Output.Size
 	m.Network.Output.Size = 0
@@ -164,6 +259,243 @@ func TestParseNetConfig(t *testing.T) {
 	m.Network.Output.Size = origOutSize
 }
 
+func TestParseHiddenLayers(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest := `kind: feedfwd
+network:
+  input:
+    size: 4
+  hidden:
+    - size: 8
+      activation: leakyrelu
+      param: 0.2
+      init:
+        kind: henormal
+      lambda: 0.5
+    - size: 6
+      activation: tanh
+      dropout: 0.2
+      batchnorm: true
+  output:
+    size: 3
+    activation: softmax
+training:
+  kind: sgd
+  cost: loglike
+  params:
+    lambda: 1.0
+    lambdas:
+      3: 0.1
+  optimize:
+    method: sgd`
+	var m Manifest
+	assert.NoError(yaml.Unmarshal([]byte(manifest), &m))
+	assert.Len(m.Network.Hidden.Layers, 2)
+	c, err := ParseManifest(&m)
+	assert.NoError(err)
+	// every hidden layer has its own configuration
+	hidden := c.Network.Arch.Hidden
+	assert.Len(hidden, 2)
+	assert.Equal(&LayerConfig{
+		Kind:   "hidden",
+		Size:   8,
+		NeurFn: &NeuronConfig{Activation: "leakyrelu", Param: 0.2},
+		Init:   &InitConfig{Kind: "henormal"},
+	}, hidden[0])
+	assert.Equal(&LayerConfig{
+		Kind:      "hidden",
+		Size:      6,
+		NeurFn:    &NeuronConfig{Activation: "tanh"},
+		Dropout:   0.2,
+		Batchnorm: true,
+	}, hidden[1])
+	// hidden layer lambdas are merged with per layer lambdas of training parameters
+	assert.Equal(map[int]float64{1: 0.5, 3: 0.1}, c.Training.Lambdas)
+	assert.Equal(map[int]float64{3: 0.1}, m.Training.Params.Lambdas)
+	// lambda can't be set twice
+	m.Training.Params.Lambdas[1] = 0.1
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	delete(m.Training.Params.Lambdas, 1)
+	// incorrect layer parameters
+	lambda := -1.0
+	m.Network.Hidden.Layers[0].Lambda = &lambda
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Hidden.Layers[0].Lambda = nil
+	m.Network.Hidden.Layers[1].Dropout = 1.0
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Hidden.Layers[1].Dropout = 0.2
+	m.Network.Hidden.Layers[1].Size = 0
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Hidden.Layers[1].Size = 6
+	m.Network.Hidden.Layers[0].Init.Kind = "foo"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Hidden.Layers[0].Init.Kind = ""
+	// dropout is only supported by mini-batch training
+	m.Training.Kind = "backprop"
+	m.Training.Optimize.Method = "bfgs"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Kind = "sgd"
+	m.Training.Optimize.Method = "sgd"
+	// hidden layers list can't be combined with compact form
+	m.Network.Hidden.Activation = "relu"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Hidden.Activation = ""
+	m.Network.Hidden.Param = 0.1
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Hidden.Param = 0
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+
+	// compact form configures all hidden layers the same way
+	m = Manifest{}
+	compact := strings.Replace(manifest, `    - size: 8
+      activation: leakyrelu
+      param: 0.2
+      init:
+        kind: henormal
+      lambda: 0.5
+    - size: 6
+      activation: tanh
+      dropout: 0.2
+      batchnorm: true`, `    size: [8, 6]
+    activation: relu
+    dropout: [0.1, 0.2]`, 1)
+	assert.NoError(yaml.Unmarshal([]byte(compact), &m))
+	assert.Empty(m.Network.Hidden.Layers)
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	hidden = c.Network.Arch.Hidden
+	assert.Len(hidden, 2)
+	for i, size := range []int{8, 6} {
+		assert.Equal(size, hidden[i].Size)
+		assert.Equal("relu", hidden[i].NeurFn.Activation)
+		assert.Equal(0.1*float64(i+1), hidden[i].Dropout)
+	}
+
+	// network without hidden layers
+	m = Manifest{}
+	assert.NoError(yaml.Unmarshal([]byte(strings.Replace(compact, "    size: [8, 6]\n", "", 1)), &m))
+	m.Network.Hidden = Hidden{}
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Empty(c.Network.Arch.Hidden)
+
+	// hidden layers configuration must be either mapping or sequence
+	m = Manifest{}
+	malformed := strings.Replace(compact, "    size: [8, 6]\n    activation: relu\n    dropout: [0.1, 0.2]", "    relu", 1)
+	assert.NoError(yaml.Unmarshal([]byte(malformed), &m))
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+}
+
+func TestParseConvLayers(t *testing.T) {
+	assert := assert.New(t)
+
+	manifest := `kind: feedfwd
+network:
+  input:
+    size: 784
+    shape: [28, 28, 1]
+  hidden:
+    - type: conv
+      filters: 8
+      kernel: 3
+      padding: 1
+      activation: relu
+      init:
+        kind: henormal
+      lambda: 0.1
+    - type: maxpool
+      kernel: 2
+    - type: flatten
+    - size: 32
+      activation: relu
+  output:
+    size: 10
+    activation: softmax
+training:
+  kind: sgd
+  cost: loglike
+  params:
+    learningrate: 0.1
+  optimize:
+    method: sgd`
+	var m Manifest
+	assert.NoError(yaml.Unmarshal([]byte(manifest), &m))
+	c, err := ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal([]int{28, 28, 1}, c.Network.Arch.Input.Shape)
+	hidden := c.Network.Arch.Hidden
+	assert.Len(hidden, 4)
+	assert.Equal(&LayerConfig{
+		Kind:   "hidden",
+		Type:   "conv",
+		NeurFn: &NeuronConfig{Activation: "relu"},
+		Init:   &InitConfig{Kind: "henormal"},
+		Conv:   &ConvConfig{Filters: 8, Kernel: 3, Padding: 1},
+	}, hidden[0])
+	assert.Equal(&LayerConfig{Kind: "hidden", Type: "maxpool", Conv: &ConvConfig{Kernel: 2}}, hidden[1])
+	assert.Equal(&LayerConfig{Kind: "hidden", Type: "flatten"}, hidden[2])
+	assert.Equal(32, hidden[3].Size)
+	assert.Equal(map[int]float64{1: 0.1}, c.Training.Lambdas)
+	// input shape must match input size
+	m.Network.Input.Shape = []int{28, 28, 3}
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Input.Shape = []int{28, 28}
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Input.Shape = []int{28, 28, 1}
+	// incorrect hidden layers
+	layers := m.Network.Hidden.Layers
+	for _, layer := range []HiddenLayer{
+		{Type: "foo"},
+		{Type: "conv", Kernel: 3, Activation: "relu"},
+		{Type: "conv", Filters: 8, Kernel: 3, Activation: "relu", Size: 10},
+		{Type: "conv", Filters: 8, Kernel: 3, Activation: "relu", Dropout: 0.5},
+		{Type: "conv", Filters: 8, Kernel: 3, Padding: 3, Activation: "relu"},
+		{Type: "maxpool"},
+		{Type: "maxpool", Kernel: 2, Stride: -1},
+		{Type: "avgpool", Kernel: 2, Activation: "relu"},
+		{Type: "flatten", Kernel: 2},
+		{Size: 10, Activation: "relu", Kernel: 2},
+	} {
+		m.Network.Hidden.Layers = []HiddenLayer{layer}
+		c, err = ParseManifest(&m)
+		assert.Nil(c)
+		assert.Error(err)
+	}
+	// pooling layers have no weights to regularize
+	lambda := 0.1
+	m.Network.Hidden.Layers = []HiddenLayer{{Type: "maxpool", Kernel: 2, Lambda: &lambda}}
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Network.Hidden.Layers = layers
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+}
+
 func TestParseOptimize(t *testing.T) {
 	assert := assert.New(t)
 
@@ -192,6 +524,99 @@ func TestParseOptimize(t *testing.T) {
 	assert.Nil(c)
 	assert.Error(err)
 	m.Training.Optimize.Method = origOptimMethod
+	// all gonum optimization methods are supported
+	for _, method := range []string{"bfgs", "lbfgs", "cg", "gd", "neldermead"} {
+		m.Training.Optimize.Method = method
+		c, err = ParseManifest(&m)
+		assert.NotNil(c)
+		assert.NoError(err)
+		assert.Equal(c.Training.Optimize.Method, method)
+	}
+	// L-BFGS history size
+	m.Training.Optimize.Method = "lbfgs"
+	m.Training.Optimize.Store = 5
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Optimize.Store, 5)
+	m.Training.Optimize.Store = 0
+	// CG variant, line search and step size
+	m.Training.Optimize.Method = "cg"
+	m.Training.Optimize.Variant = "hagerzhang"
+	m.Training.Optimize.Linesearch = "morethuente"
+	m.Training.Optimize.Stepsize = "constant"
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Optimize.Variant, "hagerzhang")
+	assert.Equal(c.Training.Optimize.Linesearch, "morethuente")
+	// constant step size defaults to 1.0
+	assert.Equal(c.Training.Optimize.Step, 1.0)
+	m.Training.Optimize.Variant = ""
+	m.Training.Optimize.Linesearch = ""
+	m.Training.Optimize.Stepsize = ""
+	// sgd can only be used by mini-batch training
+	m.Training.Optimize.Method = "sgd"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	origTrKind := m.Training.Kind
+	m.Training.Kind = "sgd"
+	m.Training.Optimize.Momentum = 0.9
+	m.Training.Optimize.Nesterov = true
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Kind, "sgd")
+	assert.Equal(c.Training.Optimize.Momentum, 0.9)
+	assert.True(c.Training.Optimize.Nesterov)
+	// default batch size
+	assert.Equal(c.Training.Optimize.Batchsize, 32)
+	m.Training.Optimize.Momentum = 0
+	m.Training.Optimize.Nesterov = false
+	// adaptive optimizers
+	for _, method := range []string{"adam", "adamw", "rmsprop", "adagrad"} {
+		m.Training.Optimize.Method = method
+		c, err = ParseManifest(&m)
+		assert.NoError(err)
+		assert.Equal(c.Training.Optimize.Method, method)
+	}
+	// decay rates default to nil
+	assert.Nil(c.Training.Optimize.Beta1)
+	assert.Nil(c.Training.Optimize.Rho)
+	m.Training.Optimize.Method = "adamw"
+	beta1, beta2 := 0.8, 0.99
+	m.Training.Optimize.Beta1 = &beta1
+	m.Training.Optimize.Beta2 = &beta2
+	m.Training.Optimize.Epsilon = 1e-6
+	m.Training.Optimize.Weightdecay = 0.01
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(*c.Training.Optimize.Beta1, 0.8)
+	assert.Equal(*c.Training.Optimize.Beta2, 0.99)
+	assert.Equal(c.Training.Optimize.Epsilon, 1e-6)
+	assert.Equal(c.Training.Optimize.Weightdecay, 0.01)
+	m.Training.Optimize.Weightdecay = 0
+	m.Training.Optimize.Beta2 = nil
+	// zero beta1 disables momentum
+	beta1 = 0
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(*c.Training.Optimize.Beta1, 0.0)
+	m.Training.Optimize.Beta1 = nil
+	// rmsprop decay rate
+	m.Training.Optimize.Method = "rmsprop"
+	rho := 0.95
+	m.Training.Optimize.Rho = &rho
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(*c.Training.Optimize.Rho, 0.95)
+	m.Training.Optimize.Rho = nil
+	m.Training.Optimize.Epsilon = 0
+	// bfgs can't be used by mini-batch training
+	m.Training.Optimize.Method = "bfgs"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Kind = origTrKind
+	m.Training.Optimize.Method = origOptimMethod
 }
 
 func TestParseTraining(t *testing.T) {
@@ -243,6 +668,255 @@ func TestParseTraining(t *testing.T) {
 	assert.Nil(c)
 	assert.Error(err)
 	m.Training.Params.Lambda = origLambda
+	// incorrect workers
+	m.Training.Params.Workers = -1
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Params.Workers = 4
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Workers, 4)
+	m.Training.Params.Workers = 0
+	// label smoothing
+	m.Training.Params.Smoothing = 0.1
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Smoothing, 0.1)
+	for _, smoothing := range []float64{-0.1, 1.0} {
+		m.Training.Params.Smoothing = smoothing
+		c, err = ParseManifest(&m)
+		assert.Nil(c)
+		assert.Error(err)
+	}
+	m.Training.Params.Smoothing = 0
+	// huber cost threshold
+	m.Training.Params.Huber = 0.5
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Huber, 0.5)
+	m.Training.Params.Huber = -1.0
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Params.Huber = 0
+	// multilabel thresholds tuning
+	m.Training.Params.Tune = true
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.True(c.Training.Tune)
+	m.Training.Params.Tune = false
+	// L2 regularization by default
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Regularizer, "l2")
+	assert.Equal(c.Training.L1ratio, 0.0)
+	// elasticnet has default l1 ratio
+	m.Training.Params.Regularizer = "elasticnet"
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Regularizer, "elasticnet")
+	assert.Equal(c.Training.L1ratio, 0.5)
+	m.Training.Params.L1ratio = 1.5
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	// l1 ratio is only used by elasticnet
+	m.Training.Params.Regularizer = "l1"
+	m.Training.Params.L1ratio = 0.2
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Params.L1ratio = 0
+	// unsupported regularizer
+	m.Training.Params.Regularizer = "foobar"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Params.Regularizer = ""
+	// per layer lambda
+	m.Training.Params.Lambdas = map[int]float64{2: 0.1}
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Lambdas[2], 0.1)
+	m.Training.Params.Lambdas = map[int]float64{0: 0.1}
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Params.Lambdas = map[int]float64{1: -0.1}
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Params.Lambdas = nil
+	// no schedule by default
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Nil(c.Training.Schedule)
+	// schedules are only supported by mini-batch training
+	m.Training.Schedule.Kind = "cosine"
+	m.Training.Schedule.Period = 5
+	m.Training.Schedule.Warmup = 1
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	origOptim := m.Training.Optimize.Method
+	m.Training.Kind = "sgd"
+	m.Training.Optimize.Method = "sgd"
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Schedule.Kind, "cosine")
+	assert.Equal(c.Training.Schedule.Period, 5.0)
+	assert.Equal(c.Training.Schedule.Warmup, 1.0)
+	// warmup only schedule
+	m.Training.Schedule.Kind = ""
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Schedule.Kind, "constant")
+	// unsupported schedule
+	m.Training.Schedule.Kind = "foobar"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Schedule.Kind = ""
+	m.Training.Schedule.Period = 0
+	m.Training.Schedule.Warmup = 0
+	m.Training.Kind = origTrAlg
+	m.Training.Optimize.Method = origOptim
+	// early stopping is disabled by default
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Nil(c.Training.EarlyStop)
+	// default early stopping parameters
+	m.Training.Earlystop.Mindelta = 0.5
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.EarlyStop.Metric, "accuracy")
+	assert.Equal(c.Training.EarlyStop.Patience, 5)
+	assert.Equal(c.Training.EarlyStop.Mindelta, 0.5)
+	// validation cost can be monitored
+	m.Training.Earlystop.Metric = "cost"
+	m.Training.Earlystop.Patience = 2
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.EarlyStop.Metric, "cost")
+	assert.Equal(c.Training.EarlyStop.Patience, 2)
+	// unsupported metric
+	m.Training.Earlystop.Metric = "foobar"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Earlystop.Metric = ""
+	// incorrect patience
+	m.Training.Earlystop.Patience = -1
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Earlystop.Patience = 0
+	m.Training.Earlystop.Mindelta = 0
+	// gradient clipping is disabled by default
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Nil(c.Training.Clip)
+	// clipping by value and norm
+	m.Training.Clip.Value = 5.0
+	m.Training.Clip.Norm = 1.0
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(c.Training.Clip.Value, 5.0)
+	assert.Equal(c.Training.Clip.Norm, 1.0)
+	// incorrect clipping parameters
+	m.Training.Clip.Norm = -1.0
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Clip.Value = 0
+	m.Training.Clip.Norm = 0
+	// all classes are treated equally by default
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Nil(c.Training.Classes)
+	// class weights
+	m.Training.Classes.Weights = []float64{1, 2, 1, 1, 1, 1, 1, 1, 1, 0.5}
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(m.Training.Classes.Weights, c.Training.Classes.Weights)
+	// weights can't be combined with balancing
+	m.Training.Classes.Balance = "weights"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	// negative weight
+	m.Training.Classes.Balance = ""
+	m.Training.Classes.Weights[0] = -1.0
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	// weight of every output class is required
+	m.Training.Classes.Weights = []float64{1, 2}
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Classes.Weights = nil
+	// balancing by weights
+	m.Training.Classes.Balance = "weights"
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal("weights", c.Training.Classes.Balance)
+	// balanced sampling requires mini-batch training
+	m.Training.Classes.Balance = "sampling"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	// unsupported balancing
+	m.Training.Classes.Balance = "foobar"
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Classes.Balance = ""
+	// data augmentation is disabled by default
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Nil(c.Training.Augment)
+	// augmentation requires mini-batch training
+	m.Training.Augment.Translate = 2
+	m.Training.Augment.Rotate = 10
+	m.Training.Augment.Elastic.Alpha = 1.0
+	m.Training.Augment.Elastic.Sigma = 3.0
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	origKind, origMethod := m.Training.Kind, m.Training.Optimize.Method
+	m.Training.Kind = "sgd"
+	m.Training.Optimize.Method = "sgd"
+	c, err = ParseManifest(&m)
+	assert.NoError(err)
+	assert.Equal(&AugmentConfig{Translate: 2, Rotate: 10, Alpha: 1.0, Sigma: 3.0}, c.Training.Augment)
+	// elastic distortion must be smoothed
+	m.Training.Augment.Elastic.Sigma = 0
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Augment.Elastic.Sigma = 3.0
+	// incorrect scale, rotation and noise
+	m.Training.Augment.Scale = 1.0
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Augment.Scale = 0
+	m.Training.Augment.Rotate = 270
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Augment.Rotate = 0
+	m.Training.Augment.Noise = -0.1
+	c, err = ParseManifest(&m)
+	assert.Nil(c)
+	assert.Error(err)
+	m.Training.Augment.Noise = 0
+	m.Training.Augment.Translate = 0
+	m.Training.Augment.Elastic.Alpha = 0
+	m.Training.Augment.Elastic.Sigma = 0
+	m.Training.Kind, m.Training.Optimize.Method = origKind, origMethod
 	// correct parameters
 	c, err = ParseManifest(&m)
 	assert.NotNil(c)
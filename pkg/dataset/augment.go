@@ -0,0 +1,146 @@
+package dataset
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// Augmenter randomly transforms square grayscale images stored in matrix rows pixel by pixel,
+// row after row, such as MNIST digits. Every image is transformed by a random affine
+// transformation and elastic distortion and additive noise is added to its pixels.
+// Pixels are expected to be scaled the same way as DataSet features. Transformations
+// with zero parameters are not applied.
+type Augmenter struct {
+	// Translate is the maximum shift of the image in pixels in both directions
+	Translate float64
+	// Rotate is the maximum rotation of the image in degrees in both directions
+	Rotate float64
+	// Scale is the maximum relative change of the image size, e.g. 0.1 scales the image by 0.9 to 1.1
+	Scale float64
+	// Alpha is the intensity of elastic distortion in pixels
+	Alpha float64
+	// Sigma is the std deviation of gaussian filter smoothing elastic distortion in pixels
+	Sigma float64
+	// Noise is the std deviation of gaussian noise added to every pixel
+	Noise float64
+}
+
+// Augment transforms every image row of the matrix in place using the supplied random generator.
+// It fails with error if the matrix rows are not square images.
+func (a *Augmenter) Augment(mx *mat64.Dense, rng *rand.Rand) error {
+	rows, cols := mx.Dims()
+	side := int(math.Sqrt(float64(cols)) + 0.5)
+	if side*side != cols {
+		return fmt.Errorf("Incorrect image size: %d\n", cols)
+	}
+	geometric := a.Translate > 0 || a.Rotate > 0 || a.Scale > 0 || a.Alpha > 0
+	out := make([]float64, cols)
+	for i := 0; i < rows; i++ {
+		img := mx.RawRowView(i)
+		if geometric {
+			a.transform(img, out, side, rng)
+			copy(img, out)
+		}
+		if a.Noise > 0 {
+			for j := range img {
+				img[j] = math.Max(scaleInput(0), math.Min(scaleInput(255), img[j]+rng.NormFloat64()*a.Noise))
+			}
+		}
+	}
+	return nil
+}
+
+// transform writes randomly transformed image img of the given side into out.
+// Every output pixel is sampled from the input image by bilinear interpolation at the location
+// given by the inverse affine transformation about the image center and elastic displacement.
+func (a *Augmenter) transform(img, out []float64, side int, rng *rand.Rand) {
+	angle := (2*rng.Float64() - 1) * a.Rotate * math.Pi / 180
+	scale := 1 + (2*rng.Float64()-1)*a.Scale
+	tx := (2*rng.Float64() - 1) * a.Translate
+	ty := (2*rng.Float64() - 1) * a.Translate
+	var dx, dy []float64
+	if a.Alpha > 0 {
+		dx, dy = a.displacement(side, rng), a.displacement(side, rng)
+	}
+	sin, cos := math.Sincos(angle)
+	center := float64(side-1) / 2
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			// undo the translation, rotation and scaling in this order
+			u, v := float64(x)-center-tx, float64(y)-center-ty
+			srcX := (cos*u+sin*v)/scale + center
+			srcY := (-sin*u+cos*v)/scale + center
+			if dx != nil {
+				srcX += dx[y*side+x]
+				srcY += dy[y*side+x]
+			}
+			out[y*side+x] = interpolate(img, side, srcX, srcY)
+		}
+	}
+}
+
+// displacement returns random displacement field of elastic distortion: uniformly distributed
+// random values smoothed by gaussian filter with Sigma std deviation and scaled by Alpha
+func (a *Augmenter) displacement(side int, rng *rand.Rand) []float64 {
+	field := make([]float64, side*side)
+	for i := range field {
+		field[i] = 2*rng.Float64() - 1
+	}
+	// separable gaussian filter truncated at 3 sigma
+	radius := int(math.Ceil(3 * a.Sigma))
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * a.Sigma * a.Sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	tmp := make([]float64, len(field))
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			for k, w := range kernel {
+				if xx := x + k - radius; xx >= 0 && xx < side {
+					tmp[y*side+x] += w * field[y*side+xx]
+				}
+			}
+		}
+	}
+	for i := range field {
+		field[i] = 0
+	}
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			for k, w := range kernel {
+				if yy := y + k - radius; yy >= 0 && yy < side {
+					field[y*side+x] += w * tmp[yy*side+x]
+				}
+			}
+		}
+	}
+	for i := range field {
+		field[i] *= a.Alpha
+	}
+	return field
+}
+
+// interpolate returns bilinear interpolation of image pixels at location (x, y).
+// Pixels outside of the image have the background value.
+func interpolate(img []float64, side int, x, y float64) float64 {
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	pixel := func(x, y int) float64 {
+		if x < 0 || y < 0 || x >= side || y >= side {
+			return scaleInput(0)
+		}
+		return img[y*side+x]
+	}
+	top := (1-fx)*pixel(x0, y0) + fx*pixel(x0+1, y0)
+	bottom := (1-fx)*pixel(x0, y0+1) + fx*pixel(x0+1, y0+1)
+	return (1-fy)*top + fy*bottom
+}
@@ -0,0 +1,65 @@
+package dataset
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+)
+
+// newImage returns 9x9 image with a single white pixel at (x, y)
+func newImage(x, y int) []float64 {
+	img := make([]float64, 81)
+	for i := range img {
+		img[i] = scaleInput(0)
+	}
+	img[y*9+x] = scaleInput(255)
+	return img
+}
+
+func TestAugment(t *testing.T) {
+	assert := assert.New(t)
+	rng := rand.New(rand.NewSource(1))
+
+	// no transformation keeps the images
+	mx := mat64.NewDense(2, 81, append(newImage(4, 4), newImage(1, 2)...))
+	orig := mat64.DenseCopyOf(mx)
+	a := &Augmenter{}
+	assert.NoError(a.Augment(mx, rng))
+	assert.True(mat64.Equal(orig, mx))
+
+	// images are shifted by at most the maximum translation
+	a = &Augmenter{Translate: 2}
+	for i := 0; i < 20; i++ {
+		mx := mat64.NewDense(1, 81, newImage(4, 4))
+		assert.NoError(a.Augment(mx, rng))
+		// image intensity is preserved by bilinear interpolation of the shifted image
+		assert.InDelta(mat64.Sum(orig.RowView(0)), mat64.Sum(mx), 1e-9)
+		for y := 0; y < 9; y++ {
+			for x := 0; x < 9; x++ {
+				if mx.At(0, y*9+x) > scaleInput(0)+1e-9 {
+					assert.True(math.Abs(float64(x-4)) <= 2 && math.Abs(float64(y-4)) <= 2)
+				}
+			}
+		}
+	}
+
+	// rotation about the image center keeps the center pixel
+	a = &Augmenter{Rotate: 90, Scale: 0.2}
+	mx = mat64.NewDense(1, 81, newImage(4, 4))
+	assert.NoError(a.Augment(mx, rng))
+	assert.Equal(scaleInput(255), mx.At(0, 40))
+
+	// elastic distortion and noise change the image but keep pixel range
+	a = &Augmenter{Alpha: 2, Sigma: 1, Noise: 0.1}
+	mx = mat64.NewDense(2, 81, append(newImage(4, 4), newImage(1, 2)...))
+	assert.NoError(a.Augment(mx, rng))
+	assert.False(mat64.Equal(orig, mx))
+	assert.True(mat64.Min(mx) >= scaleInput(0))
+	assert.True(mat64.Max(mx) <= scaleInput(255))
+
+	// images must be square
+	assert.Error(a.Augment(mat64.NewDense(1, 80, nil), rng))
+}
@@ -85,15 +85,18 @@ func (ds DataSet) Features() mat64.Matrix {
 	tempMx := mat64.NewDense(rows, cols, nil)
 	for i := 0; i < rows; i++ {	
 		for j := 0; j < cols; j++ {
-			val := dataMx.At(i, j)
-			input := (val / 255.0 * 0.999) + 0.001
-			//input := (val / 255.0)
-			tempMx.Set(i, j, input)
+			tempMx.Set(i, j, scaleInput(dataMx.At(i, j)))
 		}
 	}
 	return tempMx.View(0, 1, rows, cols-1)
 }
 
+// scaleInput scales raw pixel value into interval [0.001, 1.0]
+func scaleInput(val float64) float64 {
+	return (val / 255.0 * 0.999) + 0.001
+	//return (val / 255.0)
+}
+
 // Labels returns data labels from the raw data.
 // If the data set is not labeled or if it only contains one columne it returns nil
 func (ds DataSet) Labels() mat64.Matrix {
@@ -108,6 +111,67 @@ func (ds DataSet) Labels() mat64.Matrix {
 	return dataMx.ColView(0)
 }
 
+// Targets returns regression targets or multilabel indicators stored in the first cols columns of the raw data.
+// Unlike features, targets are not scaled. It returns nil if the data set is not labeled
+// or if it does not contain any other column.
+func (ds DataSet) Targets(cols int) mat64.Matrix {
+	rows, dataCols := ds.mx.Dims()
+	if !(ds.labeled) || cols <= 0 || cols >= dataCols {
+		return nil
+	}
+	return ds.mx.(*mat64.Dense).View(0, 0, rows, cols)
+}
+
+// Inputs returns the raw data columns which follow the first cols target columns.
+// Unlike features of classified images, inputs are not scaled. It returns nil
+// if the data set does not contain any input columns.
+func (ds DataSet) Inputs(cols int) mat64.Matrix {
+	if !(ds.labeled) {
+		return ds.mx
+	}
+	rows, dataCols := ds.mx.Dims()
+	if cols < 0 || cols >= dataCols {
+		return nil
+	}
+	return ds.mx.(*mat64.Dense).View(0, cols, rows, dataCols-cols)
+}
+
+// ClassWeights returns weights of all data set classes calculated from label frequencies.
+// It fails with error if the data set is not labeled or if any of its labels is not a valid class.
+func (ds DataSet) ClassWeights(classes int) ([]float64, error) {
+	labels := ds.Labels()
+	if labels == nil {
+		return nil, fmt.Errorf("Data set does not contain any labels\n")
+	}
+	return ClassWeights(labels, classes)
+}
+
+// ClassWeights returns weights of classes inversely proportional to their frequency
+// in labels column vector: samples / (classes * count). Classes with no samples have weight 1.
+// It fails with error if any of the labels is not in range [0, classes).
+func ClassWeights(labels mat64.Matrix, classes int) ([]float64, error) {
+	if classes <= 0 {
+		return nil, fmt.Errorf("Number of classes must be positive: %d\n", classes)
+	}
+	counts := make([]int, classes)
+	samples, _ := labels.Dims()
+	for i := 0; i < samples; i++ {
+		label := int(labels.At(i, 0))
+		if label < 0 || label >= classes {
+			return nil, fmt.Errorf("Incorrect label: %f\n", labels.At(i, 0))
+		}
+		counts[label]++
+	}
+	weights := make([]float64, classes)
+	for i, count := range counts {
+		weights[i] = 1.0
+		if count > 0 {
+			weights[i] = float64(samples) / float64(classes*count)
+		}
+	}
+	return weights, nil
+}
+
 // LoadCSV loads training set from the path supplied as a parameter.
 // It returns data matrix that contains particular CSV fields in columns.
 // It returns error if the supplied data set contains corrrupted data or
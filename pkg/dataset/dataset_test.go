@@ -123,6 +123,60 @@ func TestFeaturesLabels(t *testing.T) {
 	assert.Nil(labels)
 }
 
+func TestTargetsInputs(t *testing.T) {
+	assert := assert.New(t)
+
+	content := []byte("1.0,2.0,3.0,255\n4.0,5.0,6.0,0")
+	tmpPath := filepath.Join(os.TempDir(), "targets.csv")
+	assert.NoError(ioutil.WriteFile(tmpPath, content, 0666))
+	defer os.Remove(tmpPath)
+	ds, err := NewDataSet(tmpPath, true)
+	assert.NoError(err)
+	// first columns are the targets and the rest are unscaled inputs
+	targets := ds.Targets(2)
+	assert.True(mat64.Equal(mat64.NewDense(2, 2, []float64{1, 2, 4, 5}), targets))
+	inputs := ds.Inputs(2)
+	assert.True(mat64.Equal(mat64.NewDense(2, 2, []float64{3, 255, 6, 0}), inputs))
+	// data set must contain both targets and inputs
+	assert.Nil(ds.Targets(0))
+	assert.Nil(ds.Targets(4))
+	assert.Nil(ds.Inputs(4))
+	// unlabeled data set only contains inputs
+	ds, err = NewDataSet(tmpPath, false)
+	assert.NoError(err)
+	assert.Nil(ds.Targets(2))
+	assert.True(mat64.Equal(ds.Data(), ds.Inputs(2)))
+}
+
+func TestClassWeights(t *testing.T) {
+	assert := assert.New(t)
+
+	labels := mat64.NewVector(6, []float64{0, 0, 0, 1, 1, 3})
+	weights, err := ClassWeights(labels, 4)
+	assert.NoError(err)
+	// samples / (classes * count), classes with no samples have weight 1
+	assert.Equal([]float64{0.5, 0.75, 1.0, 1.5}, weights)
+	// labels must be valid classes
+	_, err = ClassWeights(labels, 3)
+	assert.Error(err)
+	_, err = ClassWeights(labels, 0)
+	assert.Error(err)
+
+	// labels are read from the first column of data set
+	tmpPath := path.Join(os.TempDir(), fileName)
+	ds, err := NewDataSet(tmpPath, true)
+	assert.NoError(err)
+	weights, err = ds.ClassWeights(8)
+	assert.NoError(err)
+	assert.Equal(3.0/8.0, weights[2])
+	assert.Equal(1.0, weights[3])
+	// unlabeled data set has no classes
+	ds, err = NewDataSet(tmpPath, false)
+	assert.NoError(err)
+	_, err = ds.ClassWeights(8)
+	assert.Error(err)
+}
+
 func TestScale(t *testing.T) {
 	assert := assert.New(t)
 
@@ -0,0 +1,191 @@
+package dataset
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gonum/matrix/mat64"
+)
+
+// Stream reads labeled data set from CSV file in mini-batches so that the whole data set
+// never has to fit into memory. Labels are in the first column of the file, features
+// in the remaining columns are scaled the same way as DataSet features.
+// Rows are optionally shuffled through a shuffle buffer: every returned row is drawn
+// randomly from the buffer which is then refilled with the next row read from the file.
+type Stream struct {
+	path    string
+	samples int
+	cols    int
+	// buffer is the size of the shuffle buffer, rows are not shuffled if it is 0
+	buffer int
+	file   *os.File
+	reader *csv.Reader
+	rng    *rand.Rand
+	// eof is set once all rows of the file were read
+	eof bool
+	// rows contains the rows read ahead into the shuffle buffer
+	rows [][]float64
+}
+
+// NewStream returns new data stream reading CSV file at path with the given shuffle buffer size.
+// The file is read through once to count the samples without keeping them in memory.
+// It fails with error if the file does not exist, if it is not a CSV file or
+// if its rows don't have the same number of fields.
+func NewStream(path string, buffer int) (*Stream, error) {
+	// Check if the supplied file type is supported
+	if fileType := filepath.Ext(path); fileType != ".csv" {
+		return nil, fmt.Errorf("Unsupported file type: %s\n", fileType)
+	}
+	if buffer < 0 {
+		return nil, fmt.Errorf("Incorrect shuffle buffer size: %d\n", buffer)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	s := &Stream{path: path, buffer: buffer}
+	csvReader := csv.NewReader(file)
+	csvReader.ReuseRecord = true
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.samples == 0 {
+			s.cols = len(record)
+		}
+		if s.cols != len(record) {
+			return nil, fmt.Errorf("Inconsistent number of features: %d\n", len(record))
+		}
+		s.samples++
+	}
+	// labeled data set contains at least one feature
+	if s.cols < 2 {
+		return nil, fmt.Errorf("Data set does not contain any labels\n")
+	}
+	return s, nil
+}
+
+// Samples returns the number of samples in the stream
+func (s *Stream) Samples() int {
+	return s.samples
+}
+
+// Features returns the number of features of every sample
+func (s *Stream) Features() int {
+	return s.cols - 1
+}
+
+// Reset rewinds the stream to the beginning of the file. Rows are shuffled using
+// the supplied random generator or read in the file order if it is nil.
+func (s *Stream) Reset(rng *rand.Rand) error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+	file, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	s.file, s.reader, s.rng = file, csv.NewReader(file), rng
+	s.eof, s.rows = false, s.rows[:0]
+	return nil
+}
+
+// Next returns features matrix and labels vector of at most size following samples.
+// It returns io.EOF once all the samples have been read.
+// It fails with error if the stream was not reset or if the data can not be converted to float numbers.
+func (s *Stream) Next(size int) (*mat64.Dense, *mat64.Vector, error) {
+	if s.file == nil {
+		return nil, nil, fmt.Errorf("Stream must be reset before reading\n")
+	}
+	if size <= 0 {
+		return nil, nil, fmt.Errorf("Incorrect batch size: %d\n", size)
+	}
+	var data, labels []float64
+	for len(labels) < size {
+		row, err := s.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		labels = append(labels, row[0])
+		for _, val := range row[1:] {
+			data = append(data, scaleInput(val))
+		}
+	}
+	if len(labels) == 0 {
+		return nil, nil, io.EOF
+	}
+	return mat64.NewDense(len(labels), s.cols-1, data), mat64.NewVector(len(labels), labels), nil
+}
+
+// next returns the next row of the stream drawn from the shuffle buffer
+func (s *Stream) next() ([]float64, error) {
+	// fill the shuffle buffer, it only holds the current row if not shuffling
+	buffer := s.buffer
+	if s.rng == nil {
+		buffer = 0
+	}
+	for !s.eof && len(s.rows) <= buffer {
+		row, err := s.read()
+		if err == io.EOF {
+			s.eof = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.rows = append(s.rows, row)
+	}
+	if len(s.rows) == 0 {
+		return nil, io.EOF
+	}
+	// pick random row and fill its place with the last row
+	i := 0
+	if buffer > 0 {
+		i = s.rng.Intn(len(s.rows))
+	}
+	row := s.rows[i]
+	s.rows[i] = s.rows[len(s.rows)-1]
+	s.rows = s.rows[:len(s.rows)-1]
+	return row, nil
+}
+
+// read reads and converts a single row of the CSV file
+func (s *Stream) read() ([]float64, error) {
+	record, err := s.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(record) != s.cols {
+		return nil, fmt.Errorf("Inconsistent number of features: %d\n", len(record))
+	}
+	row := make([]float64, len(record))
+	for i, field := range record {
+		if row[i], err = strconv.ParseFloat(field, 64); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+// Close closes the underlying file
+func (s *Stream) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file, s.reader = nil, nil
+	return err
+}
@@ -0,0 +1,107 @@
+package dataset
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/gonum/matrix/mat64"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	assert := assert.New(t)
+
+	content := []byte("0,0,255\n1,255,0\n2,0,0\n3,255,255\n4,0,255")
+	tmpPath := filepath.Join(os.TempDir(), "stream.csv")
+	assert.NoError(ioutil.WriteFile(tmpPath, content, 0666))
+	defer os.Remove(tmpPath)
+
+	s, err := NewStream(tmpPath, 0)
+	assert.NoError(err)
+	assert.Equal(5, s.Samples())
+	assert.Equal(2, s.Features())
+	// stream must be reset before reading
+	_, _, err = s.Next(2)
+	assert.Error(err)
+	// rows are read in order in mini-batches
+	for epoch := 0; epoch < 2; epoch++ {
+		assert.NoError(s.Reset(nil))
+		var labels []float64
+		for {
+			features, batch, err := s.Next(2)
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(err)
+			r, c := features.Dims()
+			assert.True(r <= 2)
+			assert.Equal(2, c)
+			// features are scaled the same way as in DataSet
+			for i := 0; i < r; i++ {
+				label := int(batch.At(i, 0))
+				assert.Equal(scaleInput(float64(255*(label%2))), features.At(i, 0))
+			}
+			labels = append(labels, mat64.Col(nil, 0, batch)...)
+		}
+		assert.Equal([]float64{0, 1, 2, 3, 4}, labels)
+	}
+	_, _, err = s.Next(0)
+	assert.Error(err)
+	assert.NoError(s.Close())
+
+	// rows are shuffled through the shuffle buffer
+	s, err = NewStream(tmpPath, 3)
+	assert.NoError(err)
+	defer s.Close()
+	var orders [][]float64
+	for _, seed := range []int64{1, 1, 2} {
+		assert.NoError(s.Reset(rand.New(rand.NewSource(seed))))
+		_, labels, err := s.Next(10)
+		assert.NoError(err)
+		order := mat64.Col(nil, 0, labels)
+		orders = append(orders, append([]float64(nil), order...))
+		sort.Float64s(order)
+		assert.Equal([]float64{0, 1, 2, 3, 4}, order)
+		_, _, err = s.Next(10)
+		assert.Equal(io.EOF, err)
+	}
+	// the same random generator seed shuffles the rows the same way
+	assert.Equal(orders[0], orders[1])
+	assert.NotEqual(orders[0], orders[2])
+	// rows are read in order without random generator
+	assert.NoError(s.Reset(nil))
+	_, labels, err := s.Next(10)
+	assert.NoError(err)
+	assert.Equal([]float64{0, 1, 2, 3, 4}, mat64.Col(nil, 0, labels))
+
+	// unsupported file
+	_, err = NewStream(path.Join(os.TempDir(), "stream.txt"), 0)
+	assert.Error(err)
+	// nonexistent file
+	_, err = NewStream(path.Join(os.TempDir(), "nonexistent.csv"), 0)
+	assert.Error(err)
+	// negative buffer
+	_, err = NewStream(tmpPath, -1)
+	assert.Error(err)
+	// inconsistent data
+	assert.NoError(ioutil.WriteFile(tmpPath, []byte("1,2,3\n4,5"), 0666))
+	_, err = NewStream(tmpPath, 0)
+	assert.Error(err)
+	// no labels
+	assert.NoError(ioutil.WriteFile(tmpPath, []byte("1\n2"), 0666))
+	_, err = NewStream(tmpPath, 0)
+	assert.Error(err)
+	// corrupted data is reported when reading
+	assert.NoError(ioutil.WriteFile(tmpPath, []byte("1,2\n4,sdfsdfd"), 0666))
+	s, err = NewStream(tmpPath, 0)
+	assert.NoError(err)
+	assert.NoError(s.Reset(nil))
+	_, _, err = s.Next(2)
+	assert.Error(err)
+}
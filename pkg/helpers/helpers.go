@@ -8,10 +8,15 @@ import (
 )
 
 // PseudoRandString generates a pseudoandom string of specified size
-func PseudoRandString(size int) string {
+// Random bytes are drawn from the supplied generator or from the global source if it is nil.
+func PseudoRandString(size int, rng *rand.Rand) string {
 	alphanum := "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 	bytes := make([]byte, size)
-	rand.Read(bytes)
+	if rng != nil {
+		rng.Read(bytes)
+	} else {
+		rand.Read(bytes)
+	}
 	// iterate through all alphanum bytes
 	for i, b := range bytes {
 		bytes[i] = alphanum[b%byte(len(alphanum))]
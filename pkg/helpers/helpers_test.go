@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,14 +10,17 @@ import (
 func TestPseudoRandString(t *testing.T) {
 	assert := assert.New(t)
 	length := 10
-	prev := PseudoRandString(length)
+	prev := PseudoRandString(length, nil)
 	assert.Len(prev, length)
 	for i := 0; i < 10; i++ {
-		new := PseudoRandString(length)
+		new := PseudoRandString(length, nil)
 		assert.Len(new, length)
 		assert.NotEqual(prev, new)
 		prev = new
 	}
+	// the same seed generates the same string
+	seeded := PseudoRandString(length, rand.New(rand.NewSource(1)))
+	assert.Equal(seeded, PseudoRandString(length, rand.New(rand.NewSource(1))))
 }
 
 func TestParseParams(t *testing.T) {
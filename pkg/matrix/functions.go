@@ -84,3 +84,109 @@ func ReluGradMx(i, j int, x float64) float64 {
 	}
 	return 0.1
 }
+
+// LeakyReluMx allows to apply leaky Relu with the given negative slope to all matrix elements
+func LeakyReluMx(slope float64) func(int, int, float64) float64 {
+	return func(i, j int, x float64) float64 {
+		if x > 0 {
+			return x
+		}
+		return slope * x
+	}
+}
+
+// LeakyReluGradMx provides leaky Relu derivation with the given negative slope
+func LeakyReluGradMx(slope float64) func(int, int, float64) float64 {
+	return func(i, j int, x float64) float64 {
+		if x > 0 {
+			return 1.0
+		}
+		return slope
+	}
+}
+
+// EluMx allows to apply exponential linear unit with the given alpha to all matrix elements
+func EluMx(alpha float64) func(int, int, float64) float64 {
+	return func(i, j int, x float64) float64 {
+		if x > 0 {
+			return x
+		}
+		return alpha * math.Expm1(x)
+	}
+}
+
+// EluGradMx provides exponential linear unit derivation with the given alpha
+func EluGradMx(alpha float64) func(int, int, float64) float64 {
+	return func(i, j int, x float64) float64 {
+		if x > 0 {
+			return 1.0
+		}
+		return alpha * math.Exp(x)
+	}
+}
+
+const (
+	// seluAlpha and seluScale are self-normalizing constants of SELU
+	seluAlpha = 1.6732632423543772848170429916717
+	seluScale = 1.0507009873554804934193349852946
+)
+
+// SeluMx allows to apply scaled exponential linear unit to all matrix elements
+func SeluMx(i, j int, x float64) float64 {
+	return seluScale * EluMx(seluAlpha)(i, j, x)
+}
+
+// SeluGradMx provides scaled exponential linear unit derivation
+func SeluGradMx(i, j int, x float64) float64 {
+	return seluScale * EluGradMx(seluAlpha)(i, j, x)
+}
+
+// SoftplusMx allows to apply softplus func log(1+exp(x)) to all matrix elements
+func SoftplusMx(i, j int, x float64) float64 {
+	// avoid overflow of exp for large inputs
+	if x > 30 {
+		return x
+	}
+	return math.Log1p(math.Exp(x))
+}
+
+// SoftplusGradMx provides softplus derivation which is sigmoid
+func SoftplusGradMx(i, j int, x float64) float64 {
+	return Sigmoid(x)
+}
+
+// GeluMx allows to apply gaussian error linear unit x*Phi(x) to all matrix elements,
+// where Phi is standard normal cumulative distribution function
+func GeluMx(i, j int, x float64) float64 {
+	return x * 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// GeluGradMx provides gaussian error linear unit derivation Phi(x)+x*phi(x)
+func GeluGradMx(i, j int, x float64) float64 {
+	return 0.5*math.Erfc(-x/math.Sqrt2) + x*math.Exp(-x*x/2)/math.Sqrt(2*math.Pi)
+}
+
+// SwishMx allows to apply swish func x*sigmoid(beta*x) to all matrix elements
+func SwishMx(beta float64) func(int, int, float64) float64 {
+	return func(i, j int, x float64) float64 {
+		return x * Sigmoid(beta*x)
+	}
+}
+
+// SwishGradMx provides swish derivation with the given beta
+func SwishGradMx(beta float64) func(int, int, float64) float64 {
+	return func(i, j int, x float64) float64 {
+		s := Sigmoid(beta * x)
+		return s + beta*x*s*(1-s)
+	}
+}
+
+// IdentityMx keeps all matrix elements unchanged
+func IdentityMx(i, j int, x float64) float64 {
+	return x
+}
+
+// IdentityGradMx provides identity derivation
+func IdentityGradMx(i, j int, x float64) float64 {
+	return 1.0
+}
@@ -247,3 +247,61 @@ func TestReluGradMx(t *testing.T) {
 		assert.True(tc.expected == mat64.Equal(reluGradMx, tstMx))
 	}
 }
+
+func TestActivations(t *testing.T) {
+	assert := assert.New(t)
+
+	inData := []float64{-2.0, 0.0, 3.0}
+	inMx := mat64.NewDense(1, len(inData), inData)
+	// test cases
+	testCases := []struct {
+		act  func(int, int, float64) float64
+		data []float64
+	}{
+		{LeakyReluMx(0.01), []float64{-0.02, 0.0, 3.0}},
+		{EluMx(1.0), []float64{math.Exp(-2) - 1, 0.0, 3.0}},
+		{SeluMx, []float64{seluScale * seluAlpha * (math.Exp(-2) - 1), 0.0, seluScale * 3.0}},
+		{SoftplusMx, []float64{math.Log(1 + math.Exp(-2)), math.Log(2), math.Log(1 + math.Exp(3))}},
+		{GeluMx, []float64{-0.045500, 0.0, 2.995950}},
+		{SwishMx(1.0), []float64{-2 * Sigmoid(-2), 0.0, 3 * Sigmoid(3)}},
+		{IdentityMx, []float64{-2.0, 0.0, 3.0}},
+	}
+
+	for _, tc := range testCases {
+		tstMx := mat64.NewDense(1, len(tc.data), tc.data)
+		actMx := new(mat64.Dense)
+		actMx.Apply(tc.act, inMx)
+		assert.True(mat64.EqualApprox(actMx, tstMx, 1e-6))
+	}
+	// large inputs don't overflow
+	assert.Equal(1000.0, SoftplusMx(0, 0, 1000))
+}
+
+func TestActivationGrads(t *testing.T) {
+	assert := assert.New(t)
+
+	// derivations match numerical gradient away from the kinks at zero
+	testCases := []struct {
+		act  func(int, int, float64) float64
+		grad func(int, int, float64) float64
+	}{
+		{SigmoidMx, SigmoidGradMx},
+		{TanhMx, TanhGradMx},
+		{ReluMx, ReluGradMx},
+		{LeakyReluMx(0.2), LeakyReluGradMx(0.2)},
+		{EluMx(0.5), EluGradMx(0.5)},
+		{SeluMx, SeluGradMx},
+		{SoftplusMx, SoftplusGradMx},
+		{GeluMx, GeluGradMx},
+		{SwishMx(1.5), SwishGradMx(1.5)},
+		{IdentityMx, IdentityGradMx},
+	}
+
+	eps := 1e-6
+	for i, tc := range testCases {
+		for _, x := range []float64{-3.0, -0.7, -0.1, 0.2, 1.1, 4.0} {
+			numGrad := (tc.act(0, 0, x+eps) - tc.act(0, 0, x-eps)) / (2 * eps)
+			assert.InDelta(numGrad, tc.grad(0, 0, x), 1e-6, "case %d at %f", i, x)
+		}
+	}
+}
@@ -58,19 +58,23 @@ func MakeLabelsMx(labels *mat64.Vector, expLabels int) (*mat64.Dense, error) {
 
 // MakeRandMx creates a new matrix with of size rows x cols that is initialized
 // to random number uniformly distributed in interval (min, max)
-func MakeRandMx(rows, cols int, min, max float64) (*mat64.Dense, error) {
+// Random numbers are drawn from the supplied generator or from the global source if it is nil.
+func MakeRandMx(rows, cols int, min, max float64, rng *rand.Rand) (*mat64.Dense, error) {
 	if rows <= 0 || cols <= 0 {
 		return nil, fmt.Errorf("Incorrect dimensions supplied: %d x %dd\n", rows, cols)
 	}
-	// set random seed
-	rand.Seed(55)
+	// use global source unless the random generator is supplied
+	randFloat := rand.Float64
+	if rng != nil {
+		randFloat = rng.Float64
+	}
 	// empirically this is supposed to be the best value
 	epsilon := math.Sqrt(6.0) / math.Sqrt(float64(rows+cols))
 	// allocate data slice
 	randVals := make([]float64, rows*cols)
 	for i := range randVals {
 		// we need value between 0 and 1.0
-		randVals[i] = rand.Float64()*(max-min) + min
+		randVals[i] = randFloat()*(max-min) + min
 		randVals[i] = randVals[i]*(2*epsilon) - epsilon
 	}
 	return mat64.NewDense(rows, cols, randVals), nil
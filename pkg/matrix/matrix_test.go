@@ -1,6 +1,7 @@
 package matrix
 
 import (
+	"math/rand"
 	"testing"
 
 	"github.com/gonum/matrix/mat64"
@@ -68,7 +69,7 @@ func TestMakeRandMx(t *testing.T) {
 	// create new matrix
 	rows, cols := 2, 3
 	min, max := 0.0, 1.0
-	randMx, err := MakeRandMx(rows, cols, min, max)
+	randMx, err := MakeRandMx(rows, cols, min, max, nil)
 	assert.NotNil(randMx)
 	assert.NoError(err)
 	r, c := randMx.Dims()
@@ -79,8 +80,15 @@ func TestMakeRandMx(t *testing.T) {
 		assert.True(max >= mat64.Max(col))
 	}
 
+	// the same seed generates the same matrix
+	randMx, err = MakeRandMx(rows, cols, min, max, rand.New(rand.NewSource(1)))
+	assert.NoError(err)
+	seededMx, err := MakeRandMx(rows, cols, min, max, rand.New(rand.NewSource(1)))
+	assert.NoError(err)
+	assert.True(mat64.Equal(randMx, seededMx))
+
 	// Can't create new matrix
-	randMx, err = MakeRandMx(rows, -6, min, max)
+	randMx, err = MakeRandMx(rows, -6, min, max, nil)
 	assert.Nil(randMx)
 	assert.Error(err)
 }